@@ -16,26 +16,54 @@ package check
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/garyburd/staticsite/common"
 	"github.com/garyburd/staticsite/site"
 )
 
 var (
-	flagSet = flag.NewFlagSet("check", flag.ExitOnError)
-	Command = &common.Command{
+	flagSet      = flag.NewFlagSet("check", flag.ExitOnError)
+	verifyFlag   = flagSet.Bool("verify", false, "Build the site twice and report resources with nondeterministic output.")
+	unusedFlag   = flagSet.Bool("unused", false, "Report static files and layout templates not used by any page.")
+	manifestFlag = flagSet.String("manifest", "", "With -verify, compare the build to the manifest in `file` instead of a second build. The manifest is created if it does not exist.")
+	Command      = &common.Command{
 		Name:    "check",
-		Usage:   "check [directory]",
+		Usage:   "check [-unused] [-verify [-manifest file]] [directory]",
 		FlagSet: flagSet,
 		Run:     run,
 	}
 )
 
 func run() {
-	err := site.Visit(flagSet.Arg(0), os.Stderr, func(r *site.Resource) error { return nil })
+	if *verifyFlag {
+		n, err := verify(flagSet.Arg(0), *manifestFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if n > 0 {
+			log.Fatalf("%d resources differ", n)
+		}
+		return
+	}
+	start := time.Now()
+	n := 0
+	var timings site.Timings
+	err := site.Visit(flagSet.Arg(0), common.ErrorWriter(os.Stderr), func(r *site.Resource) error { n++; return nil },
+		site.WithEnv(common.EnvOr("development")), site.WithTimings(&timings), site.WithMaxErrors(common.MaxErrors), site.WithWarningsAsErrors(common.WarningsAsErrors),
+		site.WithUnusedWarnings(*unusedFlag))
 	if err != nil {
 		log.Fatal(err)
 	}
+	common.LogTiming("walk", timings.Walk)
+	common.LogTiming("render", timings.Render)
+	common.LogTiming("minify", timings.Minify)
+	common.LogEvent(&common.Event{
+		Event:    "check",
+		Duration: common.Since(start),
+		Message:  fmt.Sprintf("Checked %d resources.", n),
+	})
 }
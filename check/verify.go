@@ -0,0 +1,127 @@
+package check
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/garyburd/staticsite/common"
+	"github.com/garyburd/staticsite/site"
+)
+
+// manifest maps resource paths to the SHA-256 hash of the resource data.
+type manifest map[string]string
+
+// buildManifest generates the site in dir and returns the manifest.
+func buildManifest(dir string) (manifest, error) {
+	m := make(manifest)
+	err := site.Visit(dir, common.ErrorWriter(os.Stderr), func(r *site.Resource) error {
+		h := sha256.New()
+		if _, err := r.WriteTo(h); err != nil {
+			return err
+		}
+		m[r.Path] = fmt.Sprintf("%x", h.Sum(nil))
+		return nil
+	}, site.WithEnv(common.EnvOr("development")), site.WithDeferredPages(), site.WithMaxErrors(common.MaxErrors), site.WithWarningsAsErrors(common.WarningsAsErrors))
+	return m, err
+}
+
+// readManifest reads a manifest written by writeManifest.
+func readManifest(fpath string) (manifest, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m := make(manifest)
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		// Split at the first space only. Paths can contain spaces.
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		if len(fields) != 2 || fields[1] == "" {
+			return nil, fmt.Errorf("%s:%d: expected hash and path", fpath, line)
+		}
+		m[fields[1]] = fields[0]
+	}
+	return m, scanner.Err()
+}
+
+// writeManifest writes the manifest to fpath with one sorted hash and path
+// pair per line.
+func writeManifest(fpath string, m manifest) error {
+	var b strings.Builder
+	for _, p := range m.paths() {
+		fmt.Fprintf(&b, "%s %s\n", m[p], p)
+	}
+	return ioutil.WriteFile(fpath, []byte(b.String()), 0666)
+}
+
+func (m manifest) paths() []string {
+	paths := make([]string, 0, len(m))
+	for p := range m {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// diffManifests returns the sorted paths of the resources that differ
+// between a and b.
+func diffManifests(a, b manifest) []string {
+	var paths []string
+	for p, h := range a {
+		if b[p] != h {
+			paths = append(paths, p)
+		}
+	}
+	for p := range b {
+		if _, ok := a[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// verify generates the site in dir and compares the output to a second
+// build or to the manifest in file fpath. If fpath does not exist, the
+// manifest is written to fpath. The number of differing resources is
+// returned.
+func verify(dir string, fpath string) (int, error) {
+	m, err := buildManifest(dir)
+	if err != nil {
+		return 0, err
+	}
+	var prev manifest
+	reason := "differs between builds"
+	if fpath != "" {
+		prev, err = readManifest(fpath)
+		if os.IsNotExist(err) {
+			return 0, writeManifest(fpath, m)
+		} else if err != nil {
+			return 0, err
+		}
+		reason = "differs from manifest"
+	} else {
+		prev, err = buildManifest(dir)
+		if err != nil {
+			return 0, err
+		}
+	}
+	diff := diffManifests(prev, m)
+	for _, p := range diff {
+		common.LogEvent(&common.Event{
+			Event:   "nondeterministic",
+			Path:    p,
+			Reason:  reason,
+			Message: fmt.Sprintf("%s %s", p, reason),
+		})
+	}
+	return len(diff), nil
+}
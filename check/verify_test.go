@@ -0,0 +1,56 @@
+package check
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffManifests(t *testing.T) {
+	a := manifest{"/": "1", "/a": "2", "/b": "3"}
+	b := manifest{"/": "1", "/a": "x", "/c": "4"}
+	got := diffManifests(a, b)
+	want := []string{"/a", "/b", "/c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fpath := filepath.Join(dir, "manifest.txt")
+	want := manifest{"/": "1", "/a b/": "2", "/c d.pdf": "3"}
+	if err := writeManifest(fpath, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readManifest(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestVerifyWarning(t *testing.T) {
+	done := make(chan error, 1)
+	go func() {
+		_, err := verify("testdata/warning", "")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("verify did not return for page with warning")
+	}
+}
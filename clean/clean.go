@@ -0,0 +1,56 @@
+package clean
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+var (
+	flagSet = flag.NewFlagSet("clean", flag.ExitOnError)
+	dryRun  = flagSet.Bool("n", false, "Dry run. Print the files to remove.")
+	Command = &common.Command{
+		Name:    "clean",
+		Usage:   "clean [directory]",
+		FlagSet: flagSet,
+		Run:     run,
+		Help: `
+Remove generated files from the site directory.
+
+The generated files are the cache directory. The cache directory contains
+fetched remote resources and the state used by build hooks.
+`,
+	}
+)
+
+func run() {
+	dir := flagSet.Arg(0)
+	if dir == "" {
+		dir = "."
+	}
+	cacheDir := filepath.Join(dir, common.CacheDir)
+	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
+		return
+	}
+	if *dryRun {
+		err := filepath.Walk(cacheDir, func(fpath string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.IsDir() {
+				log.Println(fpath)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := os.RemoveAll(cacheDir); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -3,17 +3,24 @@
 // A action consists of <%, optional whitespace, a action name, zero or more
 // arguments, optional whitespace and a %>.
 //
+// The trim markers <%- and whitespace followed by -%> remove the whitespace
+// and newlines in the text before and after the action.
+//
 // A action name consists of a letter followed by zero or more letters,
 // digits, hyphens or colons.
 //
 // An argument consists of whitespace, an argument name, and an optional
-// argument value specification.
+// argument value specification, or whitespace and a single-quoted or
+// double-quoted argument value. Arguments without a name are positional.
 //
 // An argument name consists of a letter or  _, followed by zero or more
 // letters, digits, _, ., :, or -.
 //
 // An argument value specification consists of optional whitespace, an optional
 // + charecter, a = character, optional whitespace, and an argument value.
+// The + character appends the value to the previous value of the argument.
+// It is an error to specify an argument more than once without the +
+// character.
 //
 // An argument value consists of an unquoted argument value, a single-quoted
 // argument value,or a double-quoted argument value.
@@ -33,45 +40,150 @@ package action
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
 )
 
 const TextAction = "__text__"
 
 type Action struct {
-	Name string
-	Args map[string]Value
-	Text []byte // set for TextAction
-	pos  int
+	Name   string
+	Args   map[string]Value
+	Values []Value // positional arguments
+	Text   []byte  // set for TextAction
+
+	// Names is the names of the arguments in Args in source order.
+	Names []string
+
+	// Start and End are the byte offsets of the action in the input. The
+	// range includes the delimiters. For text actions, the range is the
+	// text after trimming.
+	Start, End int
+
+	// TrimLeft and TrimRight are true if the action has trim markers.
+	TrimLeft, TrimRight bool
+
+	pos int
 }
 
 type Value struct {
 	Text string
-	pos  int
+
+	// Start and End are the byte offsets of the value in the input,
+	// including quotes. For arguments with += specifications, the range
+	// is from the start of the first value to the end of the last value.
+	Start, End int
+
+	pos int
 }
 
 type LocationContext struct {
 	fpath string
 	input []byte
+
+	// Offsets of the newlines in the input for a Scanner. The Scanner does
+	// not keep the input.
+	stream   bool
+	newlines []int
+}
+
+// Position is a location in an input file.
+type Position struct {
+	Filename string
+	Offset   int // byte offset, starting at 0
+	Line     int // line number, starting at 1
+	Column   int // column number in bytes, starting at 1
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+// Position returns the position of the action name or the start of the
+// text for text actions.
+func (a *Action) Position(lc *LocationContext) Position {
+	return lc.Position(a.pos)
 }
 
 func (a *Action) Location(lc *LocationContext) string {
-	return loc(lc.fpath, lc.input, a.pos)
+	return lc.loc(a.pos)
 }
 
-func (v Value) Location(lc *LocationContext) string {
-	return loc(lc.fpath, lc.input, v.pos)
+// Position returns the position of the value.
+func (v Value) Position(lc *LocationContext) Position {
+	return lc.Position(v.pos)
 }
 
-func loc(fpath string, input []byte, pos int) string {
-	s := input[:pos]
+func (v Value) Location(lc *LocationContext) string {
+	return lc.loc(v.pos)
+}
 
-	col := bytes.LastIndex(s, []byte{'\n'})
-	if col == -1 {
-		col = pos // first line
+// Position returns the position of byte offset in the input.
+func (lc *LocationContext) Position(offset int) Position {
+	var line, start int
+	if lc.stream {
+		n := sort.SearchInts(lc.newlines, offset)
+		line = n + 1
+		if n > 0 {
+			start = lc.newlines[n-1] + 1
+		}
 	} else {
-		col = pos - col
+		s := lc.input[:offset]
+		line = 1 + bytes.Count(s, []byte{'\n'})
+		start = bytes.LastIndexByte(s, '\n') + 1
 	}
+	return Position{Filename: lc.fpath, Offset: offset, Line: line, Column: offset - start + 1}
+}
+
+func (lc *LocationContext) loc(pos int) string {
+	return lc.Position(pos).String()
+}
+
+var valueEscaper = strings.NewReplacer("&", "&amp;", `"`, "&quot;")
 
-	line := 1 + bytes.Count(s, []byte{'\n'})
-	return fmt.Sprintf("%s:%d:%d", fpath, line, col)
+// WriteAction writes the source text for action a to w. Parsing the text
+// returns an action with the same name, arguments and trim markers.
+// Positional arguments are written first, followed by the arguments in Names
+// and the other arguments in Args sorted by name.
+func WriteAction(w io.Writer, a *Action) error {
+	if a.Name == TextAction {
+		_, err := w.Write(a.Text)
+		return err
+	}
+	var b bytes.Buffer
+	b.WriteString(defaultLeftDelim)
+	if a.TrimLeft {
+		b.Write(trimMarker)
+	}
+	b.WriteString(" ")
+	b.WriteString(a.Name)
+	for _, v := range a.Values {
+		fmt.Fprintf(&b, ` "%s"`, valueEscaper.Replace(v.Text))
+	}
+	names := make([]string, 0, len(a.Args))
+	seen := make(map[string]bool)
+	for _, name := range a.Names {
+		if _, ok := a.Args[name]; ok && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	var other []string
+	for name := range a.Args {
+		if !seen[name] {
+			other = append(other, name)
+		}
+	}
+	sort.Strings(other)
+	for _, name := range append(names, other...) {
+		fmt.Fprintf(&b, ` %s="%s"`, name, valueEscaper.Replace(a.Args[name].Text))
+	}
+	b.WriteString(" ")
+	if a.TrimRight {
+		b.Write(trimMarker)
+	}
+	b.WriteString(defaultRightDelim)
+	_, err := w.Write(b.Bytes())
+	return err
 }
@@ -37,6 +37,24 @@ var parserTests = []struct {
         `,
 		nil,
 	},
+	{
+		"a \n <%- b -%>\n\n c\n<%-d e=f -%> \n<% g x=y- -%>",
+		[]string{
+			`"a"`,
+			`x:2:6:b`,
+			`"c"`,
+			`x:5:4:d x:5:8:e="f"`,
+			`x:6:4:g x:6:8:x="y-"`,
+		},
+	},
+	{
+		"\xef\xbb\xbfa\r\n<%b\r\nc=\"d\"%>\r\n",
+		[]string{
+			`"a\n"`,
+			`x:2:3:b x:3:3:c="d"`,
+			`"\n"`,
+		},
+	},
 }
 
 var leadingWSPat = regexp.MustCompile(`^\s*`)
@@ -110,3 +128,102 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+func TestParseDuplicateArgument(t *testing.T) {
+	_, _, err := Parse([]byte("<% a b=\"c\"\n  b=d %>"), "x")
+	const want = `x:2:3: duplicate argument "b", previous value at x:1:8`
+	if err == nil || !strings.HasPrefix(err.Error(), want+"\n") {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestParseErrorExcerpt(t *testing.T) {
+	_, _, err := Parse([]byte("a\n\t<% b c %% %>\n"), "x")
+	const want = "x:2:9: expected =, found %\n\t\t<% b c %% %>\n\t\t       ^"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestParsePositional(t *testing.T) {
+	actions, lc, err := Parse([]byte(`<% t:img "a.png" 'A &amp; B' class=x %>`), "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := actions[0]
+	var got []string
+	for _, v := range a.Values {
+		got = append(got, fmt.Sprintf("%s:%q", v.Location(lc), v.Text))
+	}
+	want := []string{`x:1:10:"a.png"`, `x:1:18:"A & B"`}
+	if strings.Join(got, " ") != strings.Join(want, " ") || a.Args["class"].Text != "x" {
+		t.Errorf("got %v %v, want %v class=x", got, a.Args, want)
+	}
+	if _, _, err := Parse([]byte(`<% t:img a.png %>`), "x"); err == nil {
+		t.Error("expected error for unquoted positional argument")
+	}
+}
+
+func TestRanges(t *testing.T) {
+	input := "ab\n<%- x y = 'z' y+=\"w\" \"p\" -%>\n c"
+	actions, lc, err := Parse([]byte(input), "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, a := range actions {
+		got = append(got, fmt.Sprintf("%s %q", a.Position(lc), input[a.Start:a.End]))
+		for _, v := range a.Values {
+			got = append(got, fmt.Sprintf("%s %q", v.Position(lc), input[v.Start:v.End]))
+		}
+		for _, name := range a.Names {
+			v := a.Args[name]
+			got = append(got, fmt.Sprintf("%s %q", v.Position(lc), input[v.Start:v.End]))
+		}
+	}
+	want := []string{
+		`x:1:1 "ab"`,
+		`x:2:5 "<%- x y = 'z' y+=\"w\" \"p\" -%>"`,
+		`x:2:22 "\"p\""`,
+		`x:2:11 "'z' y+=\"w\""`,
+		`x:3:2 "c"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if p := actions[1].Position(lc); p.Offset != 7 || p.Line != 2 || p.Column != 5 {
+		t.Errorf("got position %+v, want offset 7, line 2, column 5", p)
+	}
+}
+
+func TestWriteAction(t *testing.T) {
+	input := `a<%- x "p&amp;" b='"c"' a="d" a+="e" -%>b<% y %>`
+	actions, lc, err := Parse([]byte(input), "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	for _, a := range actions {
+		if err := WriteAction(&buf, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const want = `a<%- x "p&amp;" b="&quot;c&quot;" a="de" -%>b<% y %>`
+	if buf.String() != want {
+		t.Errorf("got %s, want %s", buf.String(), want)
+	}
+	actions2, lc2, err := Parse(buf.Bytes(), "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range actions {
+		a, a2 := actions[i], actions2[i]
+		p, p2 := printAction(lc, a), printAction(lc2, a2)
+		// Remove locations.
+		loc := regexp.MustCompile(`x:\d+:\d+:`)
+		p, p2 = loc.ReplaceAllString(p, ""), loc.ReplaceAllString(p2, "")
+		if p != p2 || a.TrimLeft != a2.TrimLeft || a.TrimRight != a2.TrimRight || len(a.Values) != len(a2.Values) {
+			t.Errorf("action %d: got %s, want %s", i, p2, p)
+		}
+	}
+}
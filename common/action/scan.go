@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/garyburd/staticsite/common"
 )
 
 const (
@@ -14,9 +16,13 @@ const (
 	defaultRightDelim = "%>"
 )
 
+// Parse parses the actions in input. A leading byte order mark is removed
+// and line endings are converted to LF before parsing.
 func Parse(input []byte, fpath string) ([]*Action, *LocationContext, error) {
-	actions, err := newScanner(input, fpath, "", "").scan()
-	return actions, &LocationContext{fpath: fpath, input: input}, err
+	input = common.NormalizeText(input)
+	lc := &LocationContext{fpath: fpath, input: input}
+	actions, err := newScanner(input, lc, "", "").scan()
+	return actions, lc, err
 }
 
 func ParseFile(fpath string) ([]*Action, *LocationContext, error) {
@@ -28,18 +34,25 @@ func ParseFile(fpath string) ([]*Action, *LocationContext, error) {
 }
 
 type scanner struct {
-	fpath string
+	lc    *LocationContext
 	input []byte
 	pos   int
 
+	// Offset of input in the file. Positions in actions and values are
+	// file offsets.
+	base int
+
 	// action delimiters
 	leftDelim  []byte
 	rightDelim []byte
 
 	unquoteTerminators []byte
+
+	// True if the last scanned action ended with a trim marker.
+	trimRight bool
 }
 
-func newScanner(input []byte, fpath string, leftDelim, rightDelim string) *scanner {
+func newScanner(input []byte, lc *LocationContext, leftDelim, rightDelim string) *scanner {
 	if leftDelim == "" {
 		leftDelim = defaultLeftDelim
 	}
@@ -58,7 +71,7 @@ func newScanner(input []byte, fpath string, leftDelim, rightDelim string) *scann
 		}
 	}
 	return &scanner{
-		fpath:              fpath,
+		lc:                 lc,
 		input:              input,
 		leftDelim:          []byte(leftDelim),
 		rightDelim:         []byte(rightDelim),
@@ -67,30 +80,81 @@ func newScanner(input []byte, fpath string, leftDelim, rightDelim string) *scann
 }
 
 func (s *scanner) loc(pos int) string {
-	return loc(s.fpath, s.input, pos)
+	return s.lc.loc(s.base + pos)
+}
+
+// errorf returns an error for the input at pos. The error message includes
+// an excerpt of the input with a caret under pos.
+func (s *scanner) errorf(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s\n%s", s.loc(pos), fmt.Sprintf(format, args...), common.SourceExcerpt(s.input, pos))
 }
 
 func (s *scanner) scan() ([]*Action, error) {
 
 	var result []*Action
+	trimLeading := false
 	for {
 		pos := s.pos
 		text, more := s.scanText()
+		if trimLeading {
+			n := len(text)
+			text = bytes.TrimLeft(text, spaceChars)
+			pos += n - len(text)
+		}
+		start := s.pos - len(s.leftDelim)
+		trimLeft := more && bytes.HasPrefix(s.input[s.pos:], trimMarker)
+		if trimLeft {
+			text = bytes.TrimRight(text, spaceChars)
+		}
 		if len(text) > 0 {
-			result = append(result, &Action{Name: TextAction, pos: pos, Text: text})
+			result = append(result, s.textAction(pos, text))
 		}
 		if !more {
 			break
 		}
-		a, err := s.scanAction()
+		s.pos = start
+		a, err := s.scanAction(trimLeft)
 		if err != nil {
 			return nil, err
 		}
 		result = append(result, a)
+		trimLeading = s.trimRight
 	}
 	return result, nil
 }
 
+const spaceChars = " \t\r\n"
+
+var trimMarker = []byte("-")
+
+// scanRightDelim scans the right delimiter at the current position. The
+// delimiter can be preceded by a trim marker if skipped is true.
+func (s *scanner) scanRightDelim(skipped bool) bool {
+	if bytes.HasPrefix(s.input[s.pos:], s.rightDelim) {
+		s.pos += len(s.rightDelim)
+		s.trimRight = false
+		return true
+	}
+	if skipped && bytes.HasPrefix(s.input[s.pos:], trimMarker) &&
+		bytes.HasPrefix(s.input[s.pos+len(trimMarker):], s.rightDelim) {
+		s.pos += len(trimMarker) + len(s.rightDelim)
+		s.trimRight = true
+		return true
+	}
+	return false
+}
+
+// textAction returns a text action for text at pos.
+func (s *scanner) textAction(pos int, text []byte) *Action {
+	return &Action{
+		Name:  TextAction,
+		Text:  text,
+		Start: s.base + pos,
+		End:   s.base + pos + len(text),
+		pos:   s.base + pos,
+	}
+}
+
 // scanText scans to text to the next action or EOF.
 func (s *scanner) scanText() ([]byte, bool) {
 
@@ -122,7 +186,15 @@ func (s *scanner) skipSpace() bool {
 	return ok
 }
 
-func (s *scanner) scanAction() (*Action, error) {
+// scanAction scans the action starting with the left delimiter at the
+// current position. The delimiter is followed by a trim marker if trimLeft
+// is true.
+func (s *scanner) scanAction(trimLeft bool) (*Action, error) {
+	start := s.pos
+	s.pos += len(s.leftDelim)
+	if trimLeft {
+		s.pos += len(trimMarker)
+	}
 
 	s.skipSpace()
 	pos := s.pos
@@ -133,9 +205,11 @@ func (s *scanner) scanAction() (*Action, error) {
 	}
 
 	a := &Action{
-		pos:  pos,
-		Name: name,
-		Args: make(map[string]Value),
+		Name:     name,
+		Args:     make(map[string]Value),
+		Start:    s.base + start,
+		TrimLeft: trimLeft,
+		pos:      s.base + pos,
 	}
 
 	for {
@@ -146,6 +220,17 @@ func (s *scanner) scanAction() (*Action, error) {
 			break
 		}
 
+		if name == "" {
+			pos := s.pos
+			text, err := s.scanArgumentValue()
+			if err != nil {
+				return nil, err
+			}
+			a.Values = append(a.Values, Value{Text: text, Start: s.base + pos, End: s.base + s.pos, pos: s.base + pos})
+			continue
+		}
+
+		namePos := s.pos - len(name)
 		pos := s.pos
 
 		plus, done, err := s.scanEqual()
@@ -157,8 +242,10 @@ func (s *scanner) scanAction() (*Action, error) {
 
 		if plus {
 			if _, ok := a.Args[name]; !ok {
-				return nil, fmt.Errorf("%s: expected previous definition of %q of +=", s.loc(pos), name)
+				return nil, s.errorf(pos, "expected previous definition of %q of +=", name)
 			}
+		} else if v, ok := a.Args[name]; ok {
+			return nil, s.errorf(namePos, "duplicate argument %q, previous value at %s", name, s.lc.loc(v.pos))
 		}
 
 		pos = s.pos
@@ -171,19 +258,29 @@ func (s *scanner) scanAction() (*Action, error) {
 		if plus {
 			v := a.Args[name]
 			v.Text += text
+			v.End = s.base + s.pos
 			a.Args[name] = v
 		} else {
-			a.Args[name] = Value{Text: text, pos: pos}
+			start := s.base + pos + spaceLen(s.input[pos:s.pos])
+			a.Args[name] = Value{Text: text, Start: start, End: s.base + s.pos, pos: start}
+			a.Names = append(a.Names, name)
 		}
 	}
+	a.End = s.base + s.pos
+	a.TrimRight = s.trimRight
 	return a, nil
 }
 
+// spaceLen returns the length of the leading whitespace in p.
+func spaceLen(p []byte) int {
+	return len(p) - len(bytes.TrimLeft(p, spaceChars))
+}
+
 func (s *scanner) scanActionName() (string, error) {
 
 	r, w := utf8.DecodeRune(s.input[s.pos:])
 	if !isActionNameStart(r) {
-		return "", fmt.Errorf("%s: expected start of action name, found %c", s.loc(s.pos), r)
+		return "", s.errorf(s.pos, "expected start of action name, found %c", r)
 	}
 
 	i := w + s.pos
@@ -203,22 +300,26 @@ func (s *scanner) scanArgumentName() (string, bool, error) {
 	pos := s.pos
 	skipped := s.skipSpace()
 
-	if bytes.HasPrefix(s.input[s.pos:], s.rightDelim) {
-		s.pos += len(s.rightDelim)
+	if s.scanRightDelim(skipped) {
 		return "", true, nil
 	}
 
 	if s.pos >= len(s.input) {
-		return "", false, fmt.Errorf("%s: reached EOF looking for argument name", s.loc(pos))
+		return "", false, s.errorf(pos, "reached EOF looking for argument name")
 	}
 
 	if !skipped {
-		return "", false, fmt.Errorf("%s: expected space before start of argument name", s.loc(pos))
+		return "", false, s.errorf(pos, "expected space before start of argument name")
+	}
+
+	// A quoted value without a name is a positional argument.
+	if b := s.input[s.pos]; b == '"' || b == '\'' {
+		return "", false, nil
 	}
 
 	r, w := utf8.DecodeRune(s.input[s.pos:])
 	if !isArgumentNameStart(r) {
-		return "", false, fmt.Errorf("%s: expected start of argument name, found %c", s.loc(pos), r)
+		return "", false, s.errorf(s.pos, "expected start of argument name, found %c", r)
 	}
 
 	i := w + s.pos
@@ -235,15 +336,14 @@ func (s *scanner) scanArgumentName() (string, bool, error) {
 
 func (s *scanner) scanEqual() (bool, bool, error) {
 	pos := s.pos
-	s.skipSpace()
+	skipped := s.skipSpace()
 
-	if bytes.HasPrefix(s.input[s.pos:], s.rightDelim) {
-		s.pos += len(s.rightDelim)
+	if s.scanRightDelim(skipped) {
 		return false, true, nil
 	}
 
 	if s.pos >= len(s.input) {
-		return false, false, fmt.Errorf("%s: reached EOF looking for =", s.loc(pos))
+		return false, false, s.errorf(pos, "reached EOF looking for =")
 	}
 
 	r, _ := utf8.DecodeRune(s.input[s.pos:])
@@ -254,7 +354,7 @@ func (s *scanner) scanEqual() (bool, bool, error) {
 	}
 
 	if r != '=' {
-		return false, false, fmt.Errorf("%s: expected =, found %c", s.loc(pos), r)
+		return false, false, s.errorf(s.pos, "expected =, found %c", r)
 	}
 
 	s.pos += len("=")
@@ -266,7 +366,7 @@ func (s *scanner) scanArgumentValue() (string, error) {
 	s.skipSpace()
 
 	if s.pos >= len(s.input) {
-		return "", fmt.Errorf("%s: reached EOF looking for argument value", s.loc(pos))
+		return "", s.errorf(pos, "reached EOF looking for argument value")
 	}
 
 	fn := s.scanUnquotedValue
@@ -286,7 +386,7 @@ func (s *scanner) scanQuotedValue() (string, error) {
 
 	i := bytes.Index(s.input[s.pos:], []byte{q})
 	if i < 0 {
-		return "", fmt.Errorf("%s: reached EOF looking for close quote %c", s.loc(pos), q)
+		return "", s.errorf(pos, "reached EOF looking for close quote %c", q)
 	}
 
 	val := s.input[s.pos : s.pos+i]
@@ -301,14 +401,14 @@ func (s *scanner) scanUnquotedValue() (string, error) {
 		if bytes.ContainsRune(s.unquoteTerminators, r) {
 			val := s.input[s.pos:i]
 			if len(val) == 0 {
-				return "", fmt.Errorf("%s: expected value following =, found %c", s.loc(s.pos), r)
+				return "", s.errorf(s.pos, "expected value following =, found %c", r)
 			}
 			s.pos = i
 			return string(val), nil
 		}
 		i += w
 	}
-	return "", fmt.Errorf("%s: reached EOF looking for end of value", s.loc(s.pos))
+	return "", s.errorf(s.pos, "reached EOF looking for end of value")
 }
 
 func isActionNameStart(r rune) bool {
@@ -0,0 +1,188 @@
+package action
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+const (
+	// scanChunkSize is the size of the reads by a Scanner. Text longer than
+	// the chunk size is returned in multiple text actions.
+	scanChunkSize = 64 * 1024
+
+	// maxActionSize is the maximum size of an action read by a Scanner. The
+	// Scanner cannot distinguish an incomplete action in its buffer from an
+	// invalid action. Errors are reported after reading the maximum size.
+	maxActionSize = 1 << 20
+)
+
+// Scanner reads actions incrementally from an io.Reader. Use a Scanner to
+// process large files without reading the whole file into memory. The actions
+// are the same as the actions returned by Parse except that long text is
+// split into multiple text actions.
+type Scanner struct {
+	r   *bufio.Reader
+	s   *scanner
+	eof bool
+	err error
+
+	// True if the previous read ended with CR. The CR is converted to LF
+	// after the next read.
+	cr bool
+
+	// True if leading whitespace in text is removed.
+	trimLeading bool
+}
+
+// NewScanner returns a scanner that reads actions from r. The file path
+// fpath is used in locations.
+func NewScanner(r io.Reader, fpath string) *Scanner {
+	lc := &LocationContext{fpath: fpath, stream: true}
+	return &Scanner{r: bufio.NewReader(r), s: newScanner(nil, lc, "", "")}
+}
+
+// LocationContext returns the location context for the scanned actions.
+func (sc *Scanner) LocationContext() *LocationContext {
+	return sc.s.lc
+}
+
+// Next returns the next action. Next returns io.EOF at the end of the input.
+func (sc *Scanner) Next() (*Action, error) {
+	if sc.err != nil {
+		return nil, sc.err
+	}
+	a, err := sc.next()
+	if err != nil {
+		sc.err = err
+	}
+	return a, err
+}
+
+func (sc *Scanner) next() (*Action, error) {
+	s := sc.s
+	for {
+		rest := s.input[s.pos:]
+		i := bytes.Index(rest, s.leftDelim)
+		switch {
+		case i >= 0 && (i+len(s.leftDelim) < len(rest) || sc.eof):
+			trimRight := bytes.HasPrefix(rest[i+len(s.leftDelim):], trimMarker)
+			if a := sc.text(rest[:i], trimRight); a != nil {
+				return a, nil
+			}
+			if a, err := sc.action(trimRight); err == nil || sc.eof || len(rest)-i > maxActionSize {
+				return a, err
+			}
+		case i < 0 && sc.eof:
+			if a := sc.text(rest, false); a != nil {
+				return a, nil
+			}
+			return nil, io.EOF
+		case i < 0 && len(rest) >= scanChunkSize:
+			// Return the text except for a possible partial delimiter and
+			// trailing whitespace that might be trimmed by the next action.
+			n := len(rest) - len(s.leftDelim) + 1
+			n = len(bytes.TrimRight(rest[:n], spaceChars))
+			if a := sc.text(rest[:n], false); a != nil {
+				return a, nil
+			}
+		}
+		if err := sc.fill(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// text returns an action for text at the current position and advances the
+// position past the text. A nil action is returned if the text is empty
+// after trimming.
+func (sc *Scanner) text(text []byte, trimRight bool) *Action {
+	s := sc.s
+	pos := s.pos
+	s.pos += len(text)
+	if sc.trimLeading {
+		n := len(text)
+		text = bytes.TrimLeft(text, spaceChars)
+		pos += n - len(text)
+		sc.trimLeading = len(text) == 0 && !trimRight
+	}
+	if trimRight {
+		text = bytes.TrimRight(text, spaceChars)
+	}
+	if len(text) == 0 {
+		return nil
+	}
+	return s.textAction(pos, append([]byte(nil), text...))
+}
+
+// action scans the action at the current position. The position is not
+// changed if the action is not complete.
+func (sc *Scanner) action(trimLeft bool) (*Action, error) {
+	s := sc.s
+	start := s.pos
+	a, err := s.scanAction(trimLeft)
+	if err != nil {
+		s.pos = start
+		return nil, err
+	}
+	sc.trimLeading = s.trimRight
+	return a, nil
+}
+
+// fill reads more input. The consumed input before the start of the current
+// line is discarded. The current line is kept for source excerpts in error
+// messages.
+func (sc *Scanner) fill() error {
+	s := sc.s
+	if sc.eof {
+		return errors.New("action: fill after EOF")
+	}
+
+	start := bytes.LastIndexByte(s.input[:s.pos], '\n') + 1
+	if s.pos-start > scanChunkSize {
+		start = s.pos
+	}
+	input := append(s.input[:0:0], s.input[start:]...)
+	s.base += start
+	s.pos -= start
+
+	if s.base == 0 && len(input) == 0 {
+		if p, _ := sc.r.Peek(len(byteOrderMark)); bytes.Equal(p, byteOrderMark) {
+			sc.r.Discard(len(byteOrderMark))
+		}
+	}
+
+	p := make([]byte, scanChunkSize)
+	n, err := io.ReadFull(sc.r, p)
+	p = p[:n]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		sc.eof = true
+	} else if err != nil {
+		return err
+	}
+
+	// Convert CRLF and CR line endings to LF.
+	if sc.cr {
+		p = append([]byte{'\r'}, p...)
+	}
+	sc.cr = !sc.eof && len(p) > 0 && p[len(p)-1] == '\r'
+	if sc.cr {
+		p = p[:len(p)-1]
+	}
+	if bytes.IndexByte(p, '\r') >= 0 {
+		p = bytes.ReplaceAll(p, []byte("\r\n"), []byte("\n"))
+		p = bytes.ReplaceAll(p, []byte("\r"), []byte("\n"))
+	}
+
+	offset := s.base + len(input)
+	for i, b := range p {
+		if b == '\n' {
+			s.lc.newlines = append(s.lc.newlines, offset+i)
+		}
+	}
+	s.input = append(input, p...)
+	return nil
+}
+
+var byteOrderMark = []byte("\xef\xbb\xbf")
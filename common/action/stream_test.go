@@ -0,0 +1,80 @@
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// scanAll returns the printed actions from the scanner with adjacent text
+// actions merged.
+func scanAll(sc *Scanner) ([]string, error) {
+	var result []string
+	var text []byte
+	for {
+		a, err := sc.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if a.Name == TextAction {
+			text = append(text, a.Text...)
+			continue
+		}
+		if text != nil {
+			result = append(result, printAction(sc.LocationContext(), &Action{Name: TextAction, Text: text}))
+			text = nil
+		}
+		result = append(result, printActionRange(sc.LocationContext(), a))
+	}
+	if text != nil {
+		result = append(result, printAction(sc.LocationContext(), &Action{Name: TextAction, Text: text}))
+	}
+	return result, nil
+}
+
+func printActionRange(lc *LocationContext, a *Action) string {
+	return fmt.Sprintf("%s %d-%d", printAction(lc, a), a.Start, a.End)
+}
+
+func TestScanner(t *testing.T) {
+	const doc = "\xef\xbb\xbfa\r\nb <%- x y=\"1 %> 2\" -%>\r\n  c\n<% z w=v %>"
+	for _, pad := range []int{0, 1, scanChunkSize - 40, scanChunkSize - 20, scanChunkSize - 10, scanChunkSize - 3, scanChunkSize, 2*scanChunkSize - 25} {
+		input := strings.Repeat("\n", pad%7) + strings.Repeat(".", pad-pad%7) + doc + strings.Repeat("\r\n", scanChunkSize/3)
+
+		actions, lc, err := Parse([]byte(input), "x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []string
+		for _, a := range actions {
+			if a.Name == TextAction {
+				want = append(want, printAction(lc, a))
+			} else {
+				want = append(want, printActionRange(lc, a))
+			}
+		}
+
+		got, err := scanAll(NewScanner(strings.NewReader(input), "x"))
+		if err != nil {
+			t.Errorf("pad %d: %v", pad, err)
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("pad %d:\n got %.200q\nwant %.200q", pad, got, want)
+		}
+	}
+}
+
+func TestScannerError(t *testing.T) {
+	input := strings.Repeat("a\n", scanChunkSize) + "<% x y %% %>"
+	_, _, want := Parse([]byte(input), "x")
+	sc := NewScanner(bytes.NewReader([]byte(input)), "x")
+	_, err := scanAll(sc)
+	if err == nil || want == nil || err.Error() != want.Error() {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+}
@@ -8,13 +8,20 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
 )
 
 const (
-	ConfigDir = "config"
-	LayoutDir = "layout"
-	PageDir   = "page"
-	StaticDir = "static"
+	ArchetypeDir = "archetypes"
+	CacheDir     = "cache"
+	ConfigDir    = "config"
+	LayoutDir    = "layout"
+	PageDir      = "page"
+	StaticDir    = "static"
+	ThemeDir     = "themes"
 )
 
 type Command struct {
@@ -27,20 +34,181 @@ type Command struct {
 
 var Verbose bool
 
+// Env is the name of the environment set with the -env flag. Commands use a
+// default environment if the flag is not set.
+var Env string
+
+// MaxErrors is the number of errors after which commands stop generating
+// the site. Zero means no limit. Set with the -max-errors flag.
+var MaxErrors int
+
+// WarningsAsErrors specifies that commands fail if warnings are reported.
+// Set with the -Werror flag.
+var WarningsAsErrors bool
+
+// EnvOr returns Env or def if Env is not set.
+func EnvOr(def string) string {
+	if Env == "" {
+		return def
+	}
+	return Env
+}
+
+// Slug converts s to a URL path element. Letters are converted to lowercase
+// and runs of characters other than letters and digits are replaced with a
+// single -. Leading and trailing runs are removed. The empty string is
+// returned if s does not contain a letter or digit.
+func Slug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+var byteOrderMark = []byte("\xef\xbb\xbf")
+
+// NormalizeText removes a leading UTF-8 byte order mark from p and converts
+// CRLF and CR line endings to LF. Use NormalizeText on source files so that
+// files saved by Windows editors produce the same output as other files. The
+// slice p is returned if it does not need to be changed.
+func NormalizeText(p []byte) []byte {
+	p = bytes.TrimPrefix(p, byteOrderMark)
+	if bytes.IndexByte(p, '\r') < 0 {
+		return p
+	}
+	q := make([]byte, 0, len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] != '\r' {
+			q = append(q, p[i])
+		} else if i+1 >= len(p) || p[i+1] != '\n' {
+			q = append(q, '\n')
+		}
+	}
+	return q
+}
+
 func DecodeConfigFile(fpath string, v interface{}) error {
 	p, err := ioutil.ReadFile(fpath)
 	if err != nil {
 		return err
 	}
+	src := NormalizeText(p)
+	p, err = expandJSONEnv(src)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fpath, err)
+	}
 	d := json.NewDecoder(bytes.NewReader(p))
 	d.DisallowUnknownFields()
 	err = d.Decode(v)
 	var se *json.SyntaxError
-	if errors.As(err, &se) {
-		offset := int(se.Offset)
-		return fmt.Errorf("%s:%d: %w", fpath, bytes.Count(p[:offset+1], []byte("\n"))+1, err)
-	} else if err != nil {
+	var te *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &se):
+		return configError(fpath, src, p, int(se.Offset)-1, err)
+	case errors.As(err, &te):
+		return configError(fpath, src, p, int(te.Offset)-1, err)
+	case err != nil:
 		return fmt.Errorf("%s:1: %w", fpath, err)
 	}
 	return nil
 }
+
+// configError returns an error for err at offset pos in the expanded
+// configuration file p. The error includes a source excerpt if the line is
+// not changed by environment variable expansion. Expanded values are not
+// shown because they can contain secrets.
+func configError(fpath string, src []byte, p []byte, pos int, err error) error {
+	if pos < 0 {
+		pos = 0
+	} else if pos > len(p) {
+		pos = len(p)
+	}
+	n := bytes.Count(p[:pos], []byte("\n"))
+	srcLines := bytes.Split(src, []byte("\n"))
+	lines := bytes.Split(p, []byte("\n"))
+	if n < len(srcLines) && n < len(lines) && bytes.Equal(srcLines[n], lines[n]) {
+		return fmt.Errorf("%s:%d: %w\n%s", fpath, n+1, err, SourceExcerpt(p, pos))
+	}
+	return fmt.Errorf("%s:%d: %w", fpath, n+1, err)
+}
+
+// SourceExcerpt returns the line in p containing byte offset pos followed by
+// a line with a caret under the character at pos. Both lines are indented
+// with a tab. Use SourceExcerpt to show the location of an error in a file.
+func SourceExcerpt(p []byte, pos int) string {
+	if pos > len(p) {
+		pos = len(p)
+	}
+	start := bytes.LastIndexByte(p[:pos], '\n') + 1
+	end := bytes.IndexByte(p[pos:], '\n')
+	if end < 0 {
+		end = len(p)
+	} else {
+		end += pos
+	}
+	var b strings.Builder
+	b.WriteByte('\t')
+	b.Write(p[start:end])
+	b.WriteString("\n\t")
+	for _, r := range string(p[start:pos]) {
+		if r == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteByte('^')
+	return b.String()
+}
+
+var envRef = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExpandEnv replaces ${VAR} references in s with the value of the
+// environment variable VAR. It is an error to reference a variable that is
+// not set. The text $${VAR} is replaced with ${VAR}.
+func ExpandEnv(s string) (string, error) {
+	var err error
+	s = envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		name := ref[2 : len(ref)-1]
+		v, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s not set", name)
+		}
+		return v
+	})
+	return s, err
+}
+
+// expandJSONEnv replaces ${VAR} references in the JSON text p. The values
+// are escaped for use in JSON strings. Errors are prefixed with the line
+// number of the reference.
+func expandJSONEnv(p []byte) ([]byte, error) {
+	var err error
+	p = envRef.ReplaceAllFunc(p, func(ref []byte) []byte {
+		if err != nil {
+			return nil
+		}
+		v, e := ExpandEnv(string(ref))
+		if e != nil {
+			i := bytes.Index(p, ref)
+			err = fmt.Errorf("%d: %w", bytes.Count(p[:i], []byte("\n"))+1, e)
+			return nil
+		}
+		q, _ := json.Marshal(v)
+		return q[1 : len(q)-1]
+	})
+	return p, err
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSlug(t *testing.T) {
+	for s, want := range map[string]string{
+		"Go":          "go",
+		"Web Dev":     "web-dev",
+		" C++ & Go! ": "c-go",
+		"café":        "café",
+		"C++":         "c",
+		"++":          "",
+	} {
+		if got := Slug(s); got != want {
+			t.Errorf("Slug(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestExpandJSONEnv(t *testing.T) {
+	os.Setenv("STATICSITE_TEST_VAR", `a"b`)
+	defer os.Unsetenv("STATICSITE_TEST_VAR")
+
+	p, err := expandJSONEnv([]byte(`{"BaseURL": "https://${STATICSITE_TEST_VAR}", "CSP": "$x {y}", "Analytics": "$${STATICSITE_TEST_UNSET} $$${STATICSITE_TEST_VAR}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"BaseURL": "https://a\"b", "CSP": "$x {y}", "Analytics": "${STATICSITE_TEST_UNSET} $${STATICSITE_TEST_VAR}"}`
+	if string(p) != want {
+		t.Errorf("got %s, want %s", p, want)
+	}
+
+	_, err = expandJSONEnv([]byte("{\n\"BaseURL\": \"${STATICSITE_TEST_UNSET}\"}"))
+	const wantErr = "2: environment variable STATICSITE_TEST_UNSET not set"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("got error %v, want %s", err, wantErr)
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"a\nb", "a\nb"},
+		{"\xef\xbb\xbfa\r\nb\r\n", "a\nb\n"},
+		{"a\rb\r\r\nc\r", "a\nb\n\nc\n"},
+		{"a\xef\xbb\xbf", "a\xef\xbb\xbf"},
+	} {
+		if got := string(NormalizeText([]byte(tt.in))); got != tt.want {
+			t.Errorf("NormalizeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeConfigFileError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("STATICSITE_TEST_VAR", "secret")
+	defer os.Unsetenv("STATICSITE_TEST_VAR")
+
+	var v struct {
+		A string
+		B bool
+	}
+	for _, tt := range []struct{ data, line, excerpt string }{
+		{"{\n\"A\": \"x\",\n\"B\": \"y\"}", ":3: ", "\n\t\"B\": \"y\"}\n\t       ^"},
+		{"{\n\"A\": \"x\" \"B\": true}", ":2: ", "\n\t\"A\": \"x\" \"B\": true}\n\t         ^"},
+		{"{\"A\": \"${STATICSITE_TEST_VAR}\" \"B\": true}", ":1: ", "value pair"},
+	} {
+		fpath := filepath.Join(dir, "site.json")
+		if err := ioutil.WriteFile(fpath, []byte(tt.data), 0666); err != nil {
+			t.Fatal(err)
+		}
+		err := DecodeConfigFile(fpath, &v)
+		if err == nil || !strings.HasPrefix(err.Error(), fpath+tt.line) || !strings.HasSuffix(err.Error(), tt.excerpt) {
+			t.Errorf("got error %v, want %s...%s", err, fpath+tt.line, tt.excerpt)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// LogFormat is the format of log output set with the -log flag. The formats
+// are "text" and "json".
+var LogFormat = "text"
+
+// Event is a structured log event.
+type Event struct {
+	// Event is the kind of event. Examples: upload, delete, error.
+	Event string `json:"event"`
+
+	// Path of the resource or file.
+	Path string `json:"path,omitempty"`
+
+	// Reason for the event.
+	Reason string `json:"reason,omitempty"`
+
+	// Duration of the operation in seconds.
+	Duration float64 `json:"duration,omitempty"`
+
+	// Message is the text written in the text log format.
+	Message string `json:"message,omitempty"`
+}
+
+// SetupLog configures the log package for LogFormat. In the JSON format, each
+// line written by the log package is written as an event with the name
+// "log".
+func SetupLog() error {
+	switch LogFormat {
+	case "text":
+	case "json":
+		log.SetOutput(&jsonLogWriter{w: os.Stderr, event: "log"})
+	default:
+		return fmt.Errorf("unknown log format %q", LogFormat)
+	}
+	return nil
+}
+
+// LogEvent writes e to the log.
+func LogEvent(e *Event) {
+	if LogFormat != "json" {
+		log.Print(e.Message)
+		return
+	}
+	p, _ := json.Marshal(e)
+	os.Stderr.Write(append(p, '\n'))
+}
+
+// Since returns the duration since t in seconds for use in Event.
+func Since(t time.Time) float64 {
+	return time.Since(t).Seconds()
+}
+
+// ErrorWriter returns a writer for site error messages. In the JSON format,
+// each line written to the returned writer is written to w as an event with
+// the name "error" or "warning".
+func ErrorWriter(w io.Writer) io.Writer {
+	if LogFormat != "json" {
+		return w
+	}
+	return &jsonLogWriter{w: w, event: "error"}
+}
+
+type jsonLogWriter struct {
+	w     io.Writer
+	event string
+}
+
+func (w *jsonLogWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		e := Event{Event: w.event, Message: line}
+		if w.event == "error" && strings.Contains(line, ": warning: ") {
+			e.Event = "warning"
+		}
+		if w.event == "error" {
+			// Error messages start with the file location.
+			if i := strings.Index(line, ": "); i > 0 {
+				e.Path = strings.SplitN(line[:i], ":", 2)[0]
+			}
+		}
+		q, _ := json.Marshal(&e)
+		buf.Write(q)
+		buf.WriteByte('\n')
+	}
+	if _, err := w.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
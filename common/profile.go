@@ -0,0 +1,89 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"time"
+)
+
+// Profile flags.
+var (
+	CPUProfile string
+	MemProfile string
+	Trace      string
+	Timing     bool
+)
+
+var cpuFile, traceFile *os.File
+
+// StartProfiles starts the CPU profile and execution trace requested with
+// the -cpuprofile and -trace flags.
+func StartProfiles() error {
+	if CPUProfile != "" {
+		f, err := os.Create(CPUProfile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		cpuFile = f
+	}
+	if Trace != "" {
+		f, err := os.Create(Trace)
+		if err != nil {
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return err
+		}
+		traceFile = f
+	}
+	return nil
+}
+
+// StopProfiles stops the profiles started by StartProfiles and writes the
+// heap profile requested with the -memprofile flag.
+func StopProfiles() error {
+	if cpuFile != nil {
+		pprof.StopCPUProfile()
+		cpuFile.Close()
+		cpuFile = nil
+	}
+	if traceFile != nil {
+		trace.Stop()
+		traceFile.Close()
+		traceFile = nil
+	}
+	if MemProfile != "" {
+		f, err := os.Create(MemProfile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogTiming logs the time spent in a phase of a command if timing is
+// enabled with the -timing flag.
+func LogTiming(phase string, d time.Duration) {
+	if !Timing {
+		return
+	}
+	LogEvent(&Event{
+		Event:    "timing",
+		Reason:   phase,
+		Duration: d.Seconds(),
+		Message:  fmt.Sprintf("%-8s %v", phase, d.Round(time.Microsecond)),
+	})
+}
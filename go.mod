@@ -1,10 +1,9 @@
 module github.com/garyburd/staticsite
 
-go 1.13
+go 1.16
 
 require (
 	github.com/aws/aws-sdk-go v1.31.3
-	github.com/kr/pretty v0.1.0 // indirect
+	github.com/yuin/goldmark v1.4.11
 	golang.org/x/net v0.0.0-20201021035429-f5854403a974
-	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 )
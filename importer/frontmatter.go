@@ -0,0 +1,343 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+// field is a field in front matter.
+type field struct {
+	key    string
+	values []string
+}
+
+// value returns the field's values joined by commas.
+func (f *field) value() string {
+	return strings.Join(f.values, ", ")
+}
+
+// splitFrontMatter splits p into the front matter fields and the body. YAML
+// front matter is delimited by --- lines and TOML front matter is delimited
+// by +++ lines. A nil slice of fields is returned if p does not have front
+// matter.
+//
+// Scalar values and lists of scalar values are supported. Nested mappings and
+// TOML tables are flattened with the keys joined by -.
+func splitFrontMatter(p []byte) ([]*field, []byte, error) {
+	p = common.NormalizeText(p)
+	var delim string
+	switch {
+	case bytes.HasPrefix(p, []byte("---\n")):
+		delim = "---"
+	case bytes.HasPrefix(p, []byte("+++\n")):
+		delim = "+++"
+	default:
+		return nil, p, nil
+	}
+
+	i := bytes.IndexByte(p, '\n') + 1
+	j := bytes.Index(p[i:], []byte("\n"+delim))
+	if bytes.HasPrefix(p[i:], []byte(delim)) {
+		j = -1
+	} else if j < 0 {
+		return nil, nil, fmt.Errorf("front matter not terminated with %s", delim)
+	}
+	head := p[i : i+j+1]
+	body := p[i+j+1+len(delim):]
+	if k := bytes.IndexByte(body, '\n'); k >= 0 {
+		body = body[k+1:]
+	} else {
+		body = nil
+	}
+
+	var fields []*field
+	var err error
+	if delim == "---" {
+		fields, err = parseYAML(head)
+	} else {
+		fields, err = parseTOML(head)
+	}
+	if fields == nil {
+		fields = []*field{}
+	}
+	return fields, body, err
+}
+
+func parseYAML(p []byte) ([]*field, error) {
+	var fields []*field
+	var prefixes []string
+	var indents []int
+	lines := strings.Split(strings.TrimSuffix(string(p), "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		text := strings.TrimRight(lines[i], " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(text) - len(trimmed)
+
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("line %d: list item without key", i+1)
+			}
+			f := fields[len(fields)-1]
+			f.values = append(f.values, unquote(strings.TrimSpace(trimmed[1:])))
+			continue
+		}
+
+		for len(indents) > 0 && indent <= indents[len(indents)-1] {
+			indents = indents[:len(indents)-1]
+			prefixes = prefixes[:len(prefixes)-1]
+		}
+
+		j := strings.Index(trimmed, ":")
+		if j <= 0 {
+			return nil, fmt.Errorf("line %d: expected key: value", i+1)
+		}
+		key := unquote(strings.TrimSpace(trimmed[:j]))
+		value := strings.TrimSpace(trimmed[j+1:])
+		if value == "" {
+			// Start of a list or mapping.
+			prefixes = append(prefixes, key)
+			indents = append(indents, indent)
+			fields = append(fields, &field{key: joinKey(prefixes)})
+			continue
+		}
+		f := &field{key: joinKey(append(prefixes, key))}
+		if header := blockScalarHeader.FindStringSubmatch(stripComment(value)); header != nil {
+			var n int
+			value, n = blockScalar(lines[i+1:], indent, header[1], header[2])
+			f.values = []string{value}
+			i += n
+		} else {
+			var n int
+			value, n = continueList(value, lines[i+1:])
+			var err error
+			f.values, err = parseValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			i += n
+		}
+		fields = append(fields, f)
+	}
+	return removeEmpty(fields), nil
+}
+
+// blockScalarHeader matches the header of a literal (|) or folded (>) block
+// scalar. Explicit indentation indicators are not supported.
+var blockScalarHeader = regexp.MustCompile(`^([|>])([+-]?)$`)
+
+// blockScalar returns the value of the block scalar in lines and the number
+// of lines consumed. The block scalar's content is indented more than the
+// key at indent. The style is | for literal or > for folded. The chomp
+// indicator is "-" to strip the final line break, "+" to keep trailing line
+// breaks and "" to keep the final line break only.
+func blockScalar(lines []string, indent int, style string, chomp string) (string, int) {
+	var content []string
+	blockIndent := -1
+	n := 0
+	for ; n < len(lines); n++ {
+		line := strings.TrimRight(lines[n], " \t\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" {
+			content = append(content, "")
+			continue
+		}
+		lineIndent := len(line) - len(trimmed)
+		if blockIndent < 0 {
+			blockIndent = lineIndent
+		}
+		if lineIndent <= indent || lineIndent < blockIndent {
+			break
+		}
+		content = append(content, line[blockIndent:])
+	}
+
+	// Trailing blank lines are kept with the + chomp indicator only.
+	trailing := 0
+	for len(content) > 0 && content[len(content)-1] == "" {
+		content = content[:len(content)-1]
+		trailing++
+	}
+
+	var b strings.Builder
+	for i, line := range content {
+		if i > 0 {
+			prev := content[i-1]
+			if style == ">" && line != "" && prev != "" &&
+				!strings.HasPrefix(line, " ") && !strings.HasPrefix(prev, " ") {
+				b.WriteByte(' ')
+			} else if style == ">" && prev == "" && i > 1 && content[i-2] != "" &&
+				!strings.HasPrefix(content[i-2], " ") && !strings.HasPrefix(line, " ") {
+				// A blank line between folded lines is the line break.
+			} else {
+				b.WriteByte('\n')
+			}
+		}
+		b.WriteString(line)
+	}
+	s := b.String()
+	switch {
+	case s == "" || chomp == "-":
+	case chomp == "+":
+		s += strings.Repeat("\n", 1+trailing)
+	default:
+		s += "\n"
+	}
+	return s, n
+}
+
+func parseTOML(p []byte) ([]*field, error) {
+	var fields []*field
+	var prefix []string
+	lines := strings.Split(strings.TrimSuffix(string(p), "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		text := strings.TrimSpace(lines[i])
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			prefix = []string{strings.Trim(text, "[] ")}
+			continue
+		}
+		j := strings.Index(text, "=")
+		if j <= 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		f := &field{key: joinKey(append(prefix, unquote(strings.TrimSpace(text[:j]))))}
+		value, n := continueList(strings.TrimSpace(text[j+1:]), lines[i+1:])
+		var err error
+		f.values, err = parseValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		fields = append(fields, f)
+		i += n
+	}
+	return fields, nil
+}
+
+// continueList returns value joined with the following lines if value
+// starts a list that is continued on the following lines. The number of
+// lines consumed is also returned.
+func continueList(value string, lines []string) (string, int) {
+	value = stripComment(value)
+	if !strings.HasPrefix(value, "[") {
+		return value, 0
+	}
+	n := 0
+	for !strings.HasSuffix(value, "]") && n < len(lines) {
+		value += " " + stripComment(strings.TrimSpace(lines[n]))
+		n++
+	}
+	return value, n
+}
+
+// parseValue parses a scalar value or a list of scalar values in [].
+func parseValue(s string) ([]string, error) {
+	s = stripComment(s)
+	if !strings.HasPrefix(s, "[") {
+		return []string{unquote(s)}, nil
+	}
+	if !strings.HasSuffix(s, "]") {
+		return nil, errors.New("list not terminated with ]")
+	}
+	var values []string
+	for _, v := range splitList(s[1 : len(s)-1]) {
+		if v = unquote(strings.TrimSpace(v)); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// scanValue calls fn for each byte in s that is not in a quoted string. A
+// quoted string starts with " or ' at the start of s or after whitespace, [
+// or ,. Scanning stops when fn returns false.
+func scanValue(s string, fn func(i int) bool) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote == '\'' && c == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			// Escaped single quote.
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t[,", s[i-1]) >= 0):
+			quote = c
+		default:
+			if !fn(i) {
+				return
+			}
+		}
+	}
+}
+
+// stripComment removes a trailing comment from a value. A comment starts
+// with # outside of a quoted string at the start of the value or after
+// whitespace.
+func stripComment(s string) string {
+	end := len(s)
+	scanValue(s, func(i int) bool {
+		if s[i] == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t') {
+			end = i
+			return false
+		}
+		return true
+	})
+	return strings.TrimSpace(s[:end])
+}
+
+// splitList splits the items of a list on the commas outside of quoted
+// strings.
+func splitList(s string) []string {
+	var items []string
+	start := 0
+	scanValue(s, func(i int) bool {
+		if s[i] == ',' {
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+		return true
+	})
+	return append(items, s[start:])
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		q := s[0]
+		s = s[1 : len(s)-1]
+		if q == '"' {
+			s = strings.NewReplacer(`\"`, `"`, `\\`, `\`, `\n`, "\n", `\t`, "\t").Replace(s)
+		} else {
+			s = strings.Replace(s, "''", "'", -1)
+		}
+	}
+	return s
+}
+
+func joinKey(keys []string) string {
+	return strings.Join(keys, "-")
+}
+
+// removeEmpty removes the fields for mappings.
+func removeEmpty(fields []*field) []*field {
+	result := fields[:0]
+	for _, f := range fields {
+		if len(f.values) > 0 {
+			result = append(result, f)
+		}
+	}
+	return result
+}
@@ -0,0 +1,131 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+)
+
+var frontMatterTests = []struct {
+	in     string
+	fields []*field
+	body   string
+}{
+	{"<p>Hello</p>\n", nil, "<p>Hello</p>\n"},
+	{"---\n---\nbody\n", []*field{}, "body\n"},
+	{
+		"---\ntitle: \"Hello: World\"\ntags: [a, 'b']\ncategories:\n  - c\n  - d\nparams:\n  color: red # comment\n---\nbody\n",
+		[]*field{
+			{"title", []string{"Hello: World"}},
+			{"tags", []string{"a", "b"}},
+			{"categories", []string{"c", "d"}},
+			{"params-color", []string{"red"}},
+		},
+		"body\n",
+	},
+	{
+		"+++\ntitle = \"Hello\"\ndraft = true\ntags = [\"a\", \"b\"]\n[params]\ncolor = \"red\"\n+++\nbody\n",
+		[]*field{
+			{"title", []string{"Hello"}},
+			{"draft", []string{"true"}},
+			{"tags", []string{"a", "b"}},
+			{"params-color", []string{"red"}},
+		},
+		"body\n",
+	},
+	{
+		"---\nsummary: >\n  Folded\n  text.\n\n  Next.\nnote: |-\n  Literal\n    text\nkeep: |+\n  a\n\ntitle: T\n---\nbody\n",
+		[]*field{
+			{"summary", []string{"Folded text.\nNext.\n"}},
+			{"note", []string{"Literal\n  text"}},
+			{"keep", []string{"a\n\n"}},
+			{"title", []string{"T"}},
+		},
+		"body\n",
+	},
+	{
+		"---\nparams:\n  bio: > # comment\n    a\n    b\n  color: red\n---\nbody\n",
+		[]*field{
+			{"params-bio", []string{"a b\n"}},
+			{"params-color", []string{"red"}},
+		},
+		"body\n",
+	},
+	{
+		"---\ntitle: \"x\" # c\nquote: 'it''s' # c\ntags: [\"a, b\", 'c#d'] # c\nnote: it's #1\n---\nbody\n",
+		[]*field{
+			{"title", []string{"x"}},
+			{"quote", []string{"it's"}},
+			{"tags", []string{"a, b", "c#d"}},
+			{"note", []string{"it's"}},
+		},
+		"body\n",
+	},
+	{
+		"+++\ntitle = \"x\" # c\ntags = [\n  \"a\", # first\n  \"b\",\n]\ndraft = true\n+++\nbody\n",
+		[]*field{
+			{"title", []string{"x"}},
+			{"tags", []string{"a", "b"}},
+			{"draft", []string{"true"}},
+		},
+		"body\n",
+	},
+	{
+		"---\ntags: [a,\n  b]\ntitle: T\n---\nbody\n",
+		[]*field{
+			{"tags", []string{"a", "b"}},
+			{"title", []string{"T"}},
+		},
+		"body\n",
+	},
+}
+
+func TestSplitFrontMatter(t *testing.T) {
+	for _, tt := range frontMatterTests {
+		fields, body, err := splitFrontMatter([]byte(tt.in))
+		if err != nil {
+			t.Errorf("splitFrontMatter(%q) returned error %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(fields, tt.fields) || string(body) != tt.body {
+			t.Errorf("splitFrontMatter(%q) = %v, %q, want %v, %q", tt.in, fields, body, tt.fields, tt.body)
+		}
+	}
+}
+
+func TestSplitFrontMatterErrors(t *testing.T) {
+	for _, in := range []string{
+		"---\ntitle: Hello\n",
+		"---\ntags: [a, b\n---\n",
+		"---\n- a\n---\n",
+		"---\nnot a field\n---\n",
+		"+++\ntags = [\"a\",\n+++\n",
+	} {
+		if _, _, err := splitFrontMatter([]byte(in)); err == nil {
+			t.Errorf("splitFrontMatter(%q) did not return error", in)
+		}
+	}
+}
+
+func TestSetArgs(t *testing.T) {
+	fields := []*field{
+		{"title", []string{"Hello"}},
+		{"date", []string{"2020-05-01 10:00:00 -0700"}},
+		{"tags", []string{"a", "b"}},
+		{"layout", []string{"post"}},
+		{"cover.image", []string{"x.png"}},
+	}
+	got, err := setArgs(fields, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]string{
+		{"title", "Hello"},
+		{"param:cover_image", "x.png"},
+		{"created", "2020-05-01T10:00:00-07:00"},
+		{"tags", "a,b"},
+		{"layout", "post.html"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("setArgs() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,404 @@
+// Package importer implements the import command.
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+var (
+	flagSet = flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun  = flagSet.Bool("n", false, "Dry run. Print the files to create.")
+	Command = &common.Command{
+		Name:    "import",
+		Usage:   "import [-n] source [directory]",
+		FlagSet: flagSet,
+		Run:     run,
+		Help: `
+Import a Jekyll or Hugo site.
+
+The command converts YAML and TOML front matter to set actions, copies
+content to the page directory and other files to the static directory, and
+creates a layout skeleton for each layout in the source site. Markdown content
+is copied to Markdown pages and Markdown is enabled in config/site.json.
+Template code in layouts is not converted.
+
+The command does not overwrite existing files.
+`,
+	}
+)
+
+func run() {
+	src := flagSet.Arg(0)
+	if src == "" {
+		flagSet.Usage()
+	}
+	dst := flagSet.Arg(1)
+	if dst == "" {
+		dst = "."
+	}
+	files, err := convert(src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := write(dst, files, *dryRun); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// file is a file to create in the destination site.
+type file struct {
+	// Slash separated path relative to the site directory.
+	path string
+
+	// Data for the file or source file to copy.
+	data  []byte
+	fpath string
+}
+
+func write(dir string, files []*file, dryRun bool) error {
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	for _, f := range files {
+		fpath := filepath.Join(dir, filepath.FromSlash(f.path))
+		if _, err := os.Stat(fpath); err == nil {
+			return fmt.Errorf("%s already exists", fpath)
+		}
+	}
+	for _, f := range files {
+		fpath := filepath.Join(dir, filepath.FromSlash(f.path))
+		if dryRun {
+			log.Println(fpath)
+			continue
+		}
+		data := f.data
+		if data == nil {
+			var err error
+			data, err = ioutil.ReadFile(f.fpath)
+			if err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(fpath, data, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convert returns the files to create for the Jekyll or Hugo site in
+// directory src.
+func convert(src string) ([]*file, error) {
+	for _, name := range []string{"_config.yml", "_config.yaml", "_config.toml"} {
+		if _, err := os.Stat(filepath.Join(src, name)); err == nil {
+			return convertJekyll(src)
+		}
+	}
+	for _, name := range []string{"config.toml", "config.yaml", "config.yml", "hugo.toml", "hugo.yaml"} {
+		if _, err := os.Stat(filepath.Join(src, name)); err == nil {
+			return convertHugo(src)
+		}
+	}
+	return nil, fmt.Errorf("%s: configuration file for Jekyll or Hugo not found", src)
+}
+
+var postName = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+
+func convertJekyll(src string) ([]*file, error) {
+	var files []*file
+	layouts := make(map[string]string)
+	err := filepath.Walk(src, func(fpath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, fpath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		name := fi.Name()
+		if fi.IsDir() {
+			if rel != "." && (strings.HasPrefix(name, ".") ||
+				strings.HasPrefix(name, "_") && rel != "_posts" && rel != "_layouts") ||
+				name == "node_modules" || name == "vendor" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(rel, "_config.") ||
+			strings.HasPrefix(name, "Gemfile") {
+			return nil
+		}
+		switch {
+		case strings.HasPrefix(rel, "_layouts/"):
+			layouts[strings.TrimSuffix(name, path.Ext(name))] = rel
+			return nil
+		case strings.HasPrefix(rel, "_posts/"):
+			base := strings.TrimSuffix(name, path.Ext(name))
+			var created string
+			if m := postName.FindStringSubmatch(base); m != nil {
+				created, base = m[1], m[2]
+			}
+			f, err := convertPage(fpath, pagePath("posts/"+base+path.Ext(name)), created, "", true, layouts)
+			if err != nil || f == nil {
+				return err
+			}
+			files = append(files, f)
+			return nil
+		}
+		f, err := convertPage(fpath, pagePath(rel), "", "", true, layouts)
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			f = &file{path: common.StaticDir + "/" + rel, fpath: fpath}
+		}
+		files = append(files, f)
+		return nil
+	})
+	files = append(files, layoutFiles(layouts)...)
+	return append(files, configFiles(files)...), err
+}
+
+func convertHugo(src string) ([]*file, error) {
+	var files []*file
+	layouts := make(map[string]string)
+	for _, dir := range []string{"content", "static", "layouts"} {
+		root := filepath.Join(src, dir)
+		if _, err := os.Stat(root); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		err := filepath.Walk(root, func(fpath string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+				return nil
+			}
+			rel, err := filepath.Rel(root, fpath)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			switch dir {
+			case "content":
+				layout := "single"
+				if path.Base(rel) == "_index.md" || path.Base(rel) == "_index.html" {
+					rel = path.Join(path.Dir(rel), "index.md")
+					layout = "list"
+				}
+				// Hugo content files are pages with or without front matter.
+				f, err := convertPage(fpath, pagePath(rel), "", layout, false, layouts)
+				if err != nil {
+					return err
+				}
+				if f == nil {
+					f = &file{path: common.StaticDir + "/" + rel, fpath: fpath}
+				}
+				files = append(files, f)
+			case "static":
+				files = append(files, &file{path: common.StaticDir + "/" + rel, fpath: fpath})
+			case "layouts":
+				if strings.HasSuffix(rel, ".html") && !strings.HasPrefix(rel, "partials/") && !strings.HasPrefix(rel, "shortcodes/") {
+					layouts[strings.TrimSuffix(path.Base(rel), ".html")] = "layouts/" + rel
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	files = append(files, layoutFiles(layouts)...)
+	return append(files, configFiles(files)...), nil
+}
+
+// pagePath returns the path in the page directory for the content file at
+// rel.
+func pagePath(rel string) string {
+	ext := path.Ext(rel)
+	if ext == ".markdown" {
+		ext = ".md"
+	} else if ext != ".md" {
+		ext = ".html"
+	}
+	return strings.TrimSuffix(rel, path.Ext(rel)) + ext
+}
+
+var pageExts = map[string]bool{
+	".html":     true,
+	".md":       true,
+	".markdown": true,
+}
+
+// convertPage converts the content file fpath to a page at upath. If the file
+// is not a page or if requireFrontMatter is set and the file does not have
+// front matter, nil is returned. The created and layout arguments are defaults
+// for the corresponding set arguments. Layouts used by the page are added to
+// layouts.
+func convertPage(fpath string, upath string, created string, layout string, requireFrontMatter bool, layouts map[string]string) (*file, error) {
+	if !pageExts[path.Ext(fpath)] {
+		return nil, nil
+	}
+	p, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	fields, body, err := splitFrontMatter(p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fpath, err)
+	}
+	if fields == nil && requireFrontMatter {
+		return nil, nil
+	}
+	args, err := setArgs(fields, created, layout)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fpath, err)
+	}
+	var buf bytes.Buffer
+	buf.WriteString("<% set")
+	for _, a := range args {
+		if name := strings.TrimSuffix(a[1], ".html"); a[0] == "layout" && layouts[name] == "" {
+			layouts[name] = ""
+		}
+		fmt.Fprintf(&buf, " %s=\"%s\"", a[0], html.EscapeString(a[1]))
+	}
+	buf.WriteString(" %>\n")
+	buf.Write(body)
+	return &file{path: common.PageDir + "/" + upath, data: buf.Bytes()}, nil
+}
+
+// setArgs converts front matter fields to set action arguments.
+func setArgs(fields []*field, created string, layout string) ([][2]string, error) {
+	var args [][2]string
+	var tags []string
+	for _, f := range fields {
+		key := strings.ToLower(f.key)
+		switch key {
+		case "title", "description", "author", "weight", "draft":
+			args = append(args, [2]string{key, f.value()})
+		case "date":
+			created = f.value()
+		case "lastmod", "last_modified_at", "updated":
+			t, err := parseTime(f.value())
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, [2]string{"updated", t})
+		case "tags", "categories", "category":
+			tags = append(tags, f.values...)
+		case "layout":
+			layout = f.value()
+		case "url", "permalink":
+			if strings.HasPrefix(f.value(), "/") {
+				args = append(args, [2]string{"path", f.value()})
+			}
+		default:
+			args = append(args, [2]string{"param:" + paramName(f.key), f.value()})
+		}
+	}
+	if created != "" {
+		t, err := parseTime(created)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, [2]string{"created", t})
+	}
+	if len(tags) > 0 {
+		args = append(args, [2]string{"tags", strings.Join(tags, ",")})
+	}
+	if layout != "" && layout != "none" && layout != "null" {
+		args = append(args, [2]string{"layout", layout + ".html"})
+	}
+	return args, nil
+}
+
+// paramName converts a front matter key to a valid argument name.
+func paramName(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		if r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTime converts a front matter date to RFC 3339 format.
+func parseTime(s string) (string, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.RFC3339), nil
+		}
+	}
+	return "", fmt.Errorf("cannot parse date %q", s)
+}
+
+const layoutSkeleton = `{{/* Skeleton for %s. Port the template code from the source site. */}}
+<!doctype html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+</head>
+<body>
+{{.Content}}
+</body>
+</html>
+`
+
+// layoutFiles returns a layout skeleton for each layout. The map key is the
+// layout name and the value is the source path of the layout.
+func layoutFiles(layouts map[string]string) []*file {
+	var files []*file
+	for name, src := range layouts {
+		if src == "" {
+			src = name + " layout"
+		}
+		files = append(files, &file{
+			path: common.LayoutDir + "/" + name + ".html",
+			data: []byte(fmt.Sprintf(layoutSkeleton, src)),
+		})
+	}
+	return files
+}
+
+// configFiles returns the site configuration for the converted files.
+// Markdown pages are enabled if the files include a Markdown page.
+func configFiles(files []*file) []*file {
+	for _, f := range files {
+		if strings.HasPrefix(f.path, common.PageDir+"/") && strings.HasSuffix(f.path, ".md") {
+			return []*file{{path: common.ConfigDir + "/site.json", data: []byte(`{"Markdown": true}` + "\n")}}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package importer
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// convertedFiles returns the files converted from the site in src. The map
+// value is the data for the file or the source path for copied files.
+func convertedFiles(t *testing.T, src string, convert func(string) ([]*file, error)) map[string]string {
+	t.Helper()
+	files, err := convert(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, f := range files {
+		if f.data != nil {
+			got[f.path] = string(f.data)
+		} else {
+			rel, err := filepath.Rel(src, f.fpath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[f.path] = "copy " + filepath.ToSlash(rel)
+		}
+	}
+	return got
+}
+
+func TestConvertJekyll(t *testing.T) {
+	got := convertedFiles(t, "testdata/jekyll", convertJekyll)
+	want := map[string]string{
+		"page/posts/hello.md": `<% set title="Hello" created="2020-05-01T00:00:00Z" tags="a,b" layout="post.html" %>` + "\nHello *world*.\n",
+		"page/about.html":     `<% set title="About" param:summary="About me." %>` + "\n<p>About</p>\n",
+		"static/css/site.css": "copy css/site.css",
+		"layout/post.html":    fmt.Sprintf(layoutSkeleton, "_layouts/post.html"),
+		"config/site.json":    `{"Markdown": true}` + "\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
+
+func TestConvertHugo(t *testing.T) {
+	got := convertedFiles(t, "testdata/hugo", convertHugo)
+	want := map[string]string{
+		"page/index.md":      `<% set title="Home" layout="list.html" %>` + "\nWelcome\n",
+		"page/posts/a.md":    `<% set title="A" draft="true" created="2021-01-02T00:00:00Z" layout="single.html" %>` + "\nA\n",
+		"page/posts/b.md":    `<% set layout="single.html" %>` + "\nB\n",
+		"static/robots.txt":  "copy static/robots.txt",
+		"layout/single.html": fmt.Sprintf(layoutSkeleton, "layouts/_default/single.html"),
+		"layout/list.html":   fmt.Sprintf(layoutSkeleton, "list layout"),
+		"config/site.json":   `{"Markdown": true}` + "\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2011 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package lsp
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/garyburd/staticsite/common"
+	"github.com/garyburd/staticsite/common/action"
+	"github.com/garyburd/staticsite/site"
+	"github.com/garyburd/staticsite/site/html"
+)
+
+// LSP diagnostic severities.
+const (
+	severityError   = 1
+	severityWarning = 2
+)
+
+type diagnostic struct {
+	fpath    string
+	line     int // 1-based, 0 if not known
+	column   int // 1-based byte column, 0 if not known
+	severity int
+	message  string
+}
+
+// build builds the site in dir and returns the diagnostics by file path.
+func build(dir string) map[string][]diagnostic {
+	var out bytes.Buffer
+	paths := make(map[string]bool)
+	var pages []*site.Resource
+	err := site.Visit(dir, &out, func(r *site.Resource) error {
+		paths[r.Path] = true
+		if r.Data != nil && (r.ContentType == "" || strings.HasPrefix(r.ContentType, "text/html")) {
+			pages = append(pages, r)
+		}
+		return nil
+	}, site.WithEnv(common.EnvOr("development")))
+	if err != nil {
+		fmt.Fprintln(&out, err)
+	}
+	diags := parseErrors(out.String())
+	diags = append(diags, checkLinks(pages, paths)...)
+	result := make(map[string][]diagnostic)
+	for _, d := range diags {
+		result[d.fpath] = append(result[d.fpath], d)
+	}
+	return result
+}
+
+var errorPattern = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?: (.*)$`)
+
+// parseErrors parses the errors written by site.Visit to its error output.
+// Source excerpts following an error are skipped.
+func parseErrors(out string) []diagnostic {
+	var diags []diagnostic
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" || line[0] == '\t' {
+			continue
+		}
+		var d diagnostic
+		if m := errorPattern.FindStringSubmatch(line); m != nil {
+			d.fpath = m[1]
+			d.line, _ = strconv.Atoi(m[2])
+			d.column, _ = strconv.Atoi(m[3])
+			d.message = m[4]
+		} else if i := strings.Index(line, ": "); i > 0 {
+			d.fpath = line[:i]
+			d.message = line[i+2:]
+		} else {
+			continue
+		}
+		d.severity = severityError
+		if strings.HasPrefix(d.message, "warning: ") {
+			d.severity = severityWarning
+			d.message = d.message[len("warning: "):]
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// documentDiagnostics returns the action syntax errors in the text of a
+// content file.
+func (s *server) documentDiagnostics(fpath string, text []byte) []diagnostic {
+	rel, err := filepath.Rel(filepath.Join(s.dir, common.PageDir), fpath)
+	if err != nil || strings.HasPrefix(rel, "..") || (!strings.HasSuffix(fpath, ".html") && !strings.HasSuffix(fpath, ".md")) {
+		return nil
+	}
+	_, _, err = action.Parse(text, fpath)
+	if err == nil {
+		return nil
+	}
+	return parseErrors(err.Error())
+}
+
+// linkAttrs is the attribute checked for local links by tag name.
+var linkAttrs = map[string]string{
+	"a":      "href",
+	"area":   "href",
+	"link":   "href",
+	"img":    "src",
+	"script": "src",
+	"source": "src",
+	"iframe": "src",
+	"audio":  "src",
+	"video":  "src",
+}
+
+// checkLinks returns a diagnostic for each local link in pages to a path
+// that is not in paths. The diagnostic is located at the link in the page
+// source when the link appears in the source.
+func checkLinks(pages []*site.Resource, paths map[string]bool) []diagnostic {
+	var diags []diagnostic
+	for _, r := range pages {
+		var src []byte
+		seen := make(map[string]bool)
+		html.Rewrite(r.Data, func(t *html.Tag) error {
+			ref, ok := t.Get(linkAttrs[t.Name])
+			if !ok || seen[ref] {
+				return nil
+			}
+			seen[ref] = true
+			target, ok := linkTarget(r.Path, ref)
+			if !ok || linkExists(target, paths) {
+				return nil
+			}
+			if src == nil {
+				src, _ = ioutil.ReadFile(r.FilePath)
+			}
+			d := diagnostic{
+				fpath:    r.FilePath,
+				severity: severityWarning,
+				message:  fmt.Sprintf("broken link %s", ref),
+			}
+			d.line, d.column = findRef(src, ref)
+			diags = append(diags, d)
+			return nil
+		})
+	}
+	return diags
+}
+
+// linkTarget returns the site path for a link in the page with path base.
+// The boolean result is false for links to other sites and links within the
+// page.
+func linkTarget(base, ref string) (string, bool) {
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+	p := u.Path
+	if !strings.HasPrefix(p, "/") {
+		p = base[:strings.LastIndex(base, "/")+1] + p
+	}
+	return p, true
+}
+
+func linkExists(p string, paths map[string]bool) bool {
+	p = path.Clean(p)
+	if strings.HasSuffix(p, "/index.html") {
+		p = strings.TrimSuffix(p, "index.html")
+	}
+	return paths[p] || paths[p+"/"]
+}
+
+// findRef returns the 1-based line and column of the first occurrence of
+// ref in src as a quoted attribute value or Markdown link destination.
+func findRef(src []byte, ref string) (int, int) {
+	for _, q := range [][2]string{{`"`, `"`}, {`'`, `'`}, {"(", ")"}, {"<", ">"}, {"(", " "}} {
+		i := bytes.Index(src, []byte(q[0]+ref+q[1]))
+		if i < 0 {
+			continue
+		}
+		i++
+		line := bytes.Count(src[:i], []byte("\n")) + 1
+		column := i - (bytes.LastIndexByte(src[:i], '\n') + 1) + 1
+		return line, column
+	}
+	return 0, 0
+}
@@ -0,0 +1,381 @@
+// Copyright 2011 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package lsp implements a language server that reports site diagnostics to
+// editors.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+var (
+	flagSet = flag.NewFlagSet("lsp", flag.ExitOnError)
+	Command = &common.Command{
+		Name:    "lsp",
+		Usage:   "lsp [directory]",
+		FlagSet: flagSet,
+		Run:     run,
+		Help: `
+Run a Language Server Protocol server on stdin and stdout. The server reports
+action syntax errors as content files are edited and reports all site errors,
+warnings and broken links when files are saved. The directory defaults to the
+workspace root sent by the editor.`,
+	}
+)
+
+func run() {
+	s := newServer(os.Stdin, os.Stdout, flagSet.Arg(0))
+	if err := s.serve(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// message is a JSON-RPC request, response or notification.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+const methodNotFound = -32601
+
+type textDocumentParams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+// document is a file open in the editor.
+type document struct {
+	text []byte
+
+	// True if the text is modified from the file on disk.
+	dirty bool
+}
+
+type server struct {
+	r   *bufio.Reader
+	wmu sync.Mutex
+	w   io.Writer
+
+	dir string
+
+	docs map[string]*document
+
+	// Diagnostics from the last build by file path.
+	build map[string][]diagnostic
+
+	// Files with diagnostics published to the editor.
+	published map[string]bool
+}
+
+func newServer(r io.Reader, w io.Writer, dir string) *server {
+	return &server{
+		r:         bufio.NewReader(r),
+		w:         w,
+		dir:       dir,
+		docs:      make(map[string]*document),
+		published: make(map[string]bool),
+	}
+}
+
+// serve handles messages until the exit notification or the end of input.
+func (s *server) serve() error {
+	for {
+		m, err := s.read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if m.Method == "exit" {
+			return nil
+		}
+		result, err := s.handle(m)
+		if m.ID == nil {
+			if err != nil {
+				log.Printf("lsp: %s: %v", m.Method, err)
+			}
+			continue
+		}
+		resp := &message{ID: m.ID, Result: result}
+		if err != nil {
+			resp.Result = nil
+			resp.Error = &responseError{Code: methodNotFound, Message: err.Error()}
+		} else if result == nil {
+			resp.Result = json.RawMessage("null")
+		}
+		if err := s.write(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) handle(m *message) (interface{}, error) {
+	switch m.Method {
+	case "initialize":
+		var params struct {
+			RootURI  string `json:"rootUri"`
+			RootPath string `json:"rootPath"`
+		}
+		if err := json.Unmarshal(m.Params, &params); err != nil {
+			return nil, err
+		}
+		if s.dir == "" {
+			s.dir = params.RootPath
+			if fpath, ok := uriToPath(params.RootURI); ok {
+				s.dir = fpath
+			}
+		}
+		dir, err := filepath.Abs(s.dir)
+		if err != nil {
+			return nil, err
+		}
+		s.dir = dir
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": map[string]interface{}{
+					"openClose": true,
+					"change":    1, // full text
+					"save":      map[string]bool{"includeText": false},
+				},
+			},
+			"serverInfo": map[string]string{"name": "staticsite"},
+		}, nil
+	case "initialized":
+		return nil, s.rebuild()
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didOpen", "textDocument/didChange", "textDocument/didSave", "textDocument/didClose":
+		var params textDocumentParams
+		if err := json.Unmarshal(m.Params, &params); err != nil {
+			return nil, err
+		}
+		fpath, ok := uriToPath(params.TextDocument.URI)
+		if !ok {
+			return nil, nil
+		}
+		switch m.Method {
+		case "textDocument/didOpen":
+			s.docs[fpath] = &document{text: []byte(params.TextDocument.Text)}
+		case "textDocument/didChange":
+			doc := s.docs[fpath]
+			if doc == nil {
+				doc = &document{}
+				s.docs[fpath] = doc
+			}
+			if n := len(params.ContentChanges); n > 0 {
+				doc.text = []byte(params.ContentChanges[n-1].Text)
+				doc.dirty = true
+			}
+		case "textDocument/didSave":
+			if doc := s.docs[fpath]; doc != nil {
+				doc.dirty = false
+			}
+			return nil, s.rebuild()
+		case "textDocument/didClose":
+			delete(s.docs, fpath)
+		}
+		return nil, s.publish(fpath)
+	}
+	if m.ID != nil {
+		return nil, fmt.Errorf("method %q not found", m.Method)
+	}
+	return nil, nil
+}
+
+// rebuild builds the site and publishes the diagnostics for all files.
+func (s *server) rebuild() error {
+	s.build = build(s.dir)
+	files := make(map[string]bool)
+	for fpath := range s.published {
+		files[fpath] = true
+	}
+	for fpath := range s.build {
+		files[fpath] = true
+	}
+	for fpath := range s.docs {
+		files[fpath] = true
+	}
+	var sorted []string
+	for fpath := range files {
+		sorted = append(sorted, fpath)
+	}
+	sort.Strings(sorted)
+	for _, fpath := range sorted {
+		if err := s.publish(fpath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publish sends the diagnostics for the file to the editor. The diagnostics
+// for a modified document are the syntax errors in the document text. The
+// diagnostics for other files are from the last build.
+func (s *server) publish(fpath string) error {
+	diags := s.build[fpath]
+	doc := s.docs[fpath]
+	if doc != nil && doc.dirty {
+		diags = s.documentDiagnostics(fpath, doc.text)
+	}
+	if len(diags) == 0 && !s.published[fpath] {
+		return nil
+	}
+	s.published[fpath] = len(diags) > 0
+
+	var text []byte
+	if doc != nil {
+		text = doc.text
+	} else if len(diags) > 0 {
+		text, _ = ioutil.ReadFile(fpath)
+	}
+	type position struct {
+		Line      int `json:"line"`
+		Character int `json:"character"`
+	}
+	type lspDiagnostic struct {
+		Range struct {
+			Start position `json:"start"`
+			End   position `json:"end"`
+		} `json:"range"`
+		Severity int    `json:"severity"`
+		Source   string `json:"source"`
+		Message  string `json:"message"`
+	}
+	result := []lspDiagnostic{}
+	for _, d := range diags {
+		var ld lspDiagnostic
+		ld.Range.Start.Line, ld.Range.Start.Character = lspPosition(text, d.line, d.column)
+		ld.Range.End = ld.Range.Start
+		ld.Severity = d.severity
+		ld.Source = "staticsite"
+		ld.Message = d.message
+		result = append(result, ld)
+	}
+	params, err := json.Marshal(map[string]interface{}{
+		"uri":         pathToURI(fpath),
+		"diagnostics": result,
+	})
+	if err != nil {
+		return err
+	}
+	return s.write(&message{Method: "textDocument/publishDiagnostics", Params: params})
+}
+
+func (s *server) read() (*message, error) {
+	tr := textproto.NewReader(s.r)
+	header, err := tr.ReadMIMEHeader()
+	if err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("lsp: read header: %w", err)
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, errors.New("lsp: missing or invalid Content-Length header")
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(s.r, body); err != nil {
+		return nil, fmt.Errorf("lsp: read body: %w", err)
+	}
+	var m message
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, fmt.Errorf("lsp: decode message: %w", err)
+	}
+	return &m, nil
+}
+
+func (s *server) write(m *message) error {
+	m.JSONRPC = "2.0"
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if _, err := fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = s.w.Write(body)
+	return err
+}
+
+// lspPosition converts a 1-based line and byte column to a zero-based LSP
+// position. LSP characters are counted in UTF-16 code units.
+func lspPosition(text []byte, line, column int) (int, int) {
+	if line <= 0 {
+		return 0, 0
+	}
+	lines := strings.SplitN(string(text), "\n", line+1)
+	if line > len(lines) || column <= 1 {
+		return line - 1, 0
+	}
+	s := lines[line-1]
+	if column-1 < len(s) {
+		s = s[:column-1]
+	}
+	n := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return line - 1, n
+}
+
+func uriToPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+func pathToURI(fpath string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(fpath)}
+	return u.String()
+}
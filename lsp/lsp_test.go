@@ -0,0 +1,129 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	out := "page/a.html:2:8: expected value\n\t<% b c=%>\n\t       ^\n" +
+		"page/b.html: warning: image missing alt\n" +
+		"layout/page.html:3: function \"x\" not defined\n"
+	got := parseErrors(out)
+	want := []diagnostic{
+		{fpath: "page/a.html", line: 2, column: 8, severity: severityError, message: "expected value"},
+		{fpath: "page/b.html", severity: severityWarning, message: "image missing alt"},
+		{fpath: "layout/page.html", line: 3, severity: severityError, message: `function "x" not defined`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseErrors\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestLinkTarget(t *testing.T) {
+	paths := map[string]bool{"/": true, "/a/": true, "/a/x.png": true}
+	for _, tt := range []struct {
+		base, ref string
+		exists    bool
+	}{
+		{"/a/", "x.png", true},
+		{"/a/", "/a", true},
+		{"/a/", "../index.html", true},
+		{"/", "a/y.png", false},
+		{"/", "/b/", false},
+		{"/", "http://example.com/b/", true},
+		{"/", "#top", true},
+	} {
+		target, ok := linkTarget(tt.base, tt.ref)
+		exists := !ok || linkExists(target, paths)
+		if exists != tt.exists {
+			t.Errorf("link %q from %q exists = %v, want %v", tt.ref, tt.base, exists, tt.exists)
+		}
+	}
+}
+
+func TestLSPPosition(t *testing.T) {
+	text := []byte("a\nxé😀b\n")
+	for _, tt := range []struct {
+		line, column, wantLine, wantChar int
+	}{
+		{0, 0, 0, 0},
+		{1, 2, 0, 1},
+		{2, 8, 1, 4},
+		{2, 1, 1, 0},
+	} {
+		line, char := lspPosition(text, tt.line, tt.column)
+		if line != tt.wantLine || char != tt.wantChar {
+			t.Errorf("lspPosition(%d, %d) = %d, %d, want %d, %d", tt.line, tt.column, line, char, tt.wantLine, tt.wantChar)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	dir, err := filepath.Abs("testdata/site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := filepath.Join(dir, "page", "index.html")
+	uri := pathToURI(index)
+
+	var in bytes.Buffer
+	for _, m := range []string{
+		fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"rootUri":%q}}`, pathToURI(dir)),
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		fmt.Sprintf(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":%q,"text":%q}}}`, uri, `<% set layout="page.html" %><a href="/missing/">x</a>`),
+		fmt.Sprintf(`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":%q},"contentChanges":[{"text":"x\n<%% a b=%%>"}]}}`, uri),
+		`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	} {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(m), m)
+	}
+	var out bytes.Buffer
+	if err := newServer(&in, &out, "").serve(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	s := newServer(&out, nil, "")
+	for {
+		m, err := s.read()
+		if err != nil {
+			break
+		}
+		if m.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var params struct {
+			URI         string
+			Diagnostics []struct {
+				Range struct {
+					Start struct{ Line, Character int }
+				}
+				Severity int
+				Message  string
+			}
+		}
+		if err := json.Unmarshal(m.Params, &params); err != nil {
+			t.Fatal(err)
+		}
+		if params.URI != uri {
+			t.Errorf("unexpected uri %s", params.URI)
+		}
+		for _, d := range params.Diagnostics {
+			got = append(got, fmt.Sprintf("%d:%d %d %s", d.Range.Start.Line, d.Range.Start.Character, d.Severity, d.Message))
+		}
+	}
+	want := []string{
+		"0:37 2 broken link /missing/",              // build
+		"0:37 2 broken link /missing/",              // open
+		"1:7 1 expected value following =, found %", // change
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics\n got %q\nwant %q", got, want)
+	}
+}
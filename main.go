@@ -19,11 +19,16 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
 
 	"github.com/garyburd/staticsite/check"
+	"github.com/garyburd/staticsite/clean"
 	"github.com/garyburd/staticsite/common"
+	"github.com/garyburd/staticsite/importer"
+	"github.com/garyburd/staticsite/lsp"
 	"github.com/garyburd/staticsite/s3"
+	"github.com/garyburd/staticsite/scaffold"
 	"github.com/garyburd/staticsite/serve"
 )
 
@@ -32,13 +37,43 @@ var commands = []*common.Command{
 	serve.ReloadCommand,
 	s3.Command,
 	check.Command,
+	scaffold.NewCommand,
+	scaffold.InitCommand,
+	clean.Command,
+	importer.Command,
+	lsp.Command,
 }
 
 func main() {
 	log.SetFlags(0)
 	flag.Usage = printUsage
 	flag.BoolVar(&common.Verbose, "v", false, "Verbose output.")
+	flag.StringVar(&common.Env, "env", "", "Environment `name`. The default is production for s3 and development for other commands.")
+	flag.IntVar(&common.MaxErrors, "max-errors", 0, "Stop after `n` errors. Zero means no limit.")
+	flag.BoolVar(&common.WarningsAsErrors, "Werror", false, "Treat warnings as errors.")
+	flag.StringVar(&common.LogFormat, "log", "text", "Log `format`: text or json.")
+	flag.StringVar(&common.CPUProfile, "cpuprofile", "", "Write CPU profile to `file`.")
+	flag.StringVar(&common.MemProfile, "memprofile", "", "Write heap profile to `file` on exit.")
+	flag.StringVar(&common.Trace, "trace", "", "Write execution trace to `file`.")
+	flag.BoolVar(&common.Timing, "timing", false, "Log the time spent in each phase.")
 	flag.Parse()
+	if err := common.SetupLog(); err != nil {
+		log.Fatal(err)
+	}
+	if err := common.StartProfiles(); err != nil {
+		log.Fatal(err)
+	}
+	// Write profiles when a long running command such as serve is
+	// interrupted.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		if err := common.StopProfiles(); err != nil {
+			log.Print(err)
+		}
+		os.Exit(1)
+	}()
 
 	args := flag.Args()
 	if len(args) == 0 {
@@ -58,6 +93,9 @@ func main() {
 			}
 			c.FlagSet.Parse(args[1:])
 			c.Run()
+			if err := common.StopProfiles(); err != nil {
+				log.Fatal(err)
+			}
 			return
 		}
 	}
@@ -20,13 +20,16 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudfront"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -47,6 +50,20 @@ var (
 		Usage:   "s3 [dir]",
 		FlagSet: flagSet,
 		Run:     run,
+		Help: `
+Upload the site to an S3 bucket.
+
+The bucket is configured with a set action in config/s3.txt:
+
+    <% set bucket="example.com" region="us-east-1" %>
+
+The argument errorDocument="404.html" sets the bucket website error document
+to the page /404.html.
+
+If the file config/redirects exists, the bucket website routing rules are
+replaced with the wildcard rules in the file. The website configuration is
+not changed if the bucket is not configured for website hosting.
+`,
 	}
 )
 
@@ -61,6 +78,12 @@ type updater struct {
 	maxAge                   int
 	unmanaged                []string
 	cloudFrontDistributionID string
+
+	timings site.Timings
+
+	// Key of the page configured as the website error document or "" if
+	// the error document is not configured.
+	errorDocument string
 }
 
 func run() {
@@ -71,6 +94,7 @@ func run() {
 		log.Fatal(err)
 	}
 
+	start := time.Now()
 	u := updater{
 		dir:    flagSet.Arg(0),
 		maxAge: 60 * 60,
@@ -91,16 +115,33 @@ func run() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	common.LogTiming("walk", u.timings.Walk)
+	common.LogTiming("render", u.timings.Render)
+	common.LogTiming("minify", u.timings.Minify)
 
+	uploadStart := time.Now()
 	var invalidatePath string
 	for _, r := range uploadResources {
-		log.Printf("%s %s\n", r.UpdateReason, r.Path)
 		if *dryRun {
+			common.LogEvent(&common.Event{
+				Event:   "upload",
+				Path:    r.Path,
+				Reason:  r.UpdateReason,
+				Message: fmt.Sprintf("%s %s", r.UpdateReason, r.Path),
+			})
 			continue
 		}
+		t := time.Now()
 		if err := u.uploadResource(r); err != nil {
 			log.Fatal(err)
 		}
+		common.LogEvent(&common.Event{
+			Event:    "upload",
+			Path:     r.Path,
+			Reason:   r.UpdateReason,
+			Duration: common.Since(t),
+			Message:  fmt.Sprintf("%s %s", r.UpdateReason, r.Path),
+		})
 		if r.UpdateReason != updateNew {
 			if invalidatePath == "" {
 				invalidatePath = r.Path
@@ -110,19 +151,33 @@ func run() {
 		}
 	}
 
+	common.LogTiming("upload", time.Since(uploadStart))
+
 	if !*dryRun && *invalidate && invalidatePath != "" && u.cloudFrontDistributionID != "" {
 		if strings.HasSuffix(invalidatePath, "/index.html") {
 			invalidatePath = invalidatePath[:len(invalidatePath)-len("index.html")]
 		}
-		log.Printf("Invalidating CloudFront distribution: %s", invalidatePath)
+		common.LogEvent(&common.Event{
+			Event:   "invalidate",
+			Path:    invalidatePath,
+			Message: fmt.Sprintf("Invalidating CloudFront distribution: %s", invalidatePath),
+		})
 		err := u.invalidateDistribution(invalidatePath)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 
+	if err := u.updateWebsite(); err != nil {
+		log.Fatal(err)
+	}
+
 	for _, p := range deletePaths {
-		log.Printf("D %s\n", p)
+		common.LogEvent(&common.Event{
+			Event:   "delete",
+			Path:    p,
+			Message: fmt.Sprintf("D %s", p),
+		})
 		if *dryRun {
 			continue
 		}
@@ -131,7 +186,11 @@ func run() {
 		}
 	}
 
-	log.Printf("View the updated website at http://%s.s3-website-%s.amazonaws.com/", u.bucket, u.region)
+	common.LogEvent(&common.Event{
+		Event:    "done",
+		Duration: common.Since(start),
+		Message:  fmt.Sprintf("View the updated website at http://%s.s3-website-%s.amazonaws.com/", u.bucket, u.region),
+	})
 }
 
 func (u *updater) readConfig() error {
@@ -150,6 +209,10 @@ func (u *updater) readConfig() error {
 			}
 		case "set":
 			for k, v := range a.Args {
+				v.Text, err = common.ExpandEnv(v.Text)
+				if err != nil {
+					return fmt.Errorf("%s: %w", v.Location(lc), err)
+				}
 				switch k {
 				case "bucket":
 					u.bucket = v.Text
@@ -157,6 +220,8 @@ func (u *updater) readConfig() error {
 					u.region = v.Text
 				case "cloudFrontDistributionID":
 					u.cloudFrontDistributionID = v.Text
+				case "errorDocument":
+					u.errorDocument = strings.TrimPrefix(v.Text, "/")
 				case "maxAge":
 					var err error
 					u.maxAge, err = strconv.Atoi(v.Text)
@@ -227,14 +292,18 @@ func (u *updater) getResourcesToUpdate() ([]*site.Resource, []string, error) {
 	}
 
 	var (
-		newResources      []*site.Resource
-		modifiedResources []*site.Resource
+		newResources       []*site.Resource
+		modifiedResources  []*site.Resource
+		foundErrorDocument bool
 	)
-	err = site.Visit(u.dir, os.Stderr, func(r *site.Resource) error {
+	err = site.Visit(u.dir, common.ErrorWriter(os.Stderr), func(r *site.Resource) error {
 		if strings.HasSuffix(r.Path, "/") {
 			r.Path = r.Path + "index.html"
 		}
 		key := r.Path[1:]
+		if key == u.errorDocument {
+			foundErrorDocument = true
+		}
 		o, ok := objects[key]
 		if !ok {
 			r.UpdateReason = updateNew
@@ -265,10 +334,13 @@ func (u *updater) getResourcesToUpdate() ([]*site.Resource, []string, error) {
 		}
 		modifiedResources = append(modifiedResources, r)
 		return nil
-	})
+	}, site.WithEnv(common.EnvOr("production")), site.WithTimings(&u.timings), site.WithMaxErrors(common.MaxErrors), site.WithWarningsAsErrors(common.WarningsAsErrors), site.WithHooks(true))
 	if err != nil {
 		return nil, nil, err
 	}
+	if u.errorDocument != "" && !foundErrorDocument {
+		return nil, nil, fmt.Errorf("error document /%s not found in site", u.errorDocument)
+	}
 
 	// Find resources to delete. Skip unmanaged.
 
@@ -304,6 +376,29 @@ func (u *updater) uploadResource(r *site.Resource) error {
 	if r.Redirect != "" {
 		input.WebsiteRedirectLocation = aws.String(r.Redirect)
 	}
+	for k, v := range r.Header {
+		value := aws.String(strings.Join(v, ", "))
+		switch k {
+		case "Cache-Control":
+			input.CacheControl = value
+		case "Content-Disposition":
+			input.ContentDisposition = value
+		case "Content-Encoding":
+			input.ContentEncoding = value
+		case "Content-Language":
+			input.ContentLanguage = value
+		case "Content-Type":
+			input.ContentType = value
+		default:
+			// S3 returns metadata with the x-amz-meta- prefix. Use a
+			// CloudFront response headers policy or function to map
+			// metadata to response headers.
+			if input.Metadata == nil {
+				input.Metadata = make(map[string]*string)
+			}
+			input.Metadata[k] = value
+		}
+	}
 	_, err = u.s3.PutObject(input)
 	return err
 }
@@ -316,6 +411,114 @@ func (u *updater) deleteResource(p string) error {
 	return err
 }
 
+// updateWebsite updates the bucket website configuration. If the site has a
+// redirects file, the routing rules are set to the wildcard rules in the
+// file. If an error document is configured, the error document is set. The
+// configuration is not changed if the bucket is not configured for website
+// hosting.
+func (u *updater) updateWebsite() error {
+	_, err := os.Stat(filepath.Join(u.dir, common.ConfigDir, "redirects"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	hasRedirects := err == nil
+	if !hasRedirects && u.errorDocument == "" {
+		return nil
+	}
+	redirects, err := site.ReadRedirects(u.dir)
+	if err != nil {
+		return err
+	}
+	rules, err := routingRules(redirects)
+	if err != nil {
+		return err
+	}
+	website, err := u.s3.GetBucketWebsite(&s3.GetBucketWebsiteInput{Bucket: aws.String(u.bucket)})
+	if e, ok := err.(awserr.Error); ok && e.Code() == "NoSuchWebsiteConfiguration" {
+		common.LogEvent(&common.Event{
+			Event:   "website",
+			Message: "Bucket website configuration not updated: bucket is not configured for website hosting",
+		})
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if website.RedirectAllRequestsTo != nil {
+		return fmt.Errorf("cannot set routing rules or error document on bucket %s that redirects all requests", u.bucket)
+	}
+	config := &s3.WebsiteConfiguration{
+		ErrorDocument: website.ErrorDocument,
+		IndexDocument: website.IndexDocument,
+		RoutingRules:  website.RoutingRules,
+	}
+	changed := false
+	if hasRedirects && (len(rules) > 0 || len(website.RoutingRules) > 0) && !reflect.DeepEqual(website.RoutingRules, rules) {
+		config.RoutingRules = rules
+		changed = true
+		common.LogEvent(&common.Event{
+			Event:   "routing",
+			Message: fmt.Sprintf("R %d routing rules", len(rules)),
+		})
+	}
+	if u.errorDocument != "" && (website.ErrorDocument == nil || aws.StringValue(website.ErrorDocument.Key) != u.errorDocument) {
+		config.ErrorDocument = &s3.ErrorDocument{Key: aws.String(u.errorDocument)}
+		changed = true
+		common.LogEvent(&common.Event{
+			Event:   "errordocument",
+			Path:    "/" + u.errorDocument,
+			Message: fmt.Sprintf("E /%s", u.errorDocument),
+		})
+	}
+	if !changed || *dryRun {
+		return nil
+	}
+	_, err = u.s3.PutBucketWebsite(&s3.PutBucketWebsiteInput{
+		Bucket:               aws.String(u.bucket),
+		WebsiteConfiguration: config,
+	})
+	return err
+}
+
+// routingRules converts the wildcard redirect rules to S3 website routing
+// rules. The rules apply only to requests for objects that do not exist.
+func routingRules(redirects []*site.Redirect) ([]*s3.RoutingRule, error) {
+	var rules []*s3.RoutingRule
+	for _, r := range redirects {
+		if !r.IsWildcard() {
+			continue
+		}
+		redirect := &s3.Redirect{HttpRedirectCode: aws.String("301")}
+		to, err := url.Parse(r.To)
+		if err != nil {
+			return nil, fmt.Errorf("redirect %s: %w", r.From, err)
+		}
+		if to.Host != "" {
+			redirect.HostName = aws.String(to.Host)
+			redirect.Protocol = aws.String(to.Scheme)
+		}
+		p := strings.TrimPrefix(to.Path, "/")
+		switch strings.Count(r.To, ":splat") {
+		case 0:
+			redirect.ReplaceKeyWith = aws.String(p)
+		case 1:
+			if !strings.HasSuffix(p, ":splat") || to.RawQuery != "" || to.Fragment != "" {
+				return nil, fmt.Errorf("redirect %s: :splat must be at end of URL for S3 routing rules", r.From)
+			}
+			redirect.ReplaceKeyPrefixWith = aws.String(strings.TrimSuffix(p, ":splat"))
+		default:
+			return nil, fmt.Errorf("redirect %s: multiple :splat not supported by S3 routing rules", r.From)
+		}
+		rules = append(rules, &s3.RoutingRule{
+			Condition: &s3.Condition{
+				HttpErrorCodeReturnedEquals: aws.String("404"),
+				KeyPrefixEquals:             aws.String(r.Prefix()[1:]),
+			},
+			Redirect: redirect,
+		})
+	}
+	return rules, nil
+}
+
 func (u *updater) invalidateDistribution(path string) error {
 	_, err := u.cf.CreateInvalidation(&cloudfront.CreateInvalidationInput{
 		DistributionId: aws.String(u.cloudFrontDistributionID),
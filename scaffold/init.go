@@ -0,0 +1,95 @@
+package scaffold
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+var (
+	initFlagSet = flag.NewFlagSet("init", flag.ExitOnError)
+	InitCommand = &common.Command{
+		Name:    "init",
+		Usage:   "init [directory]",
+		FlagSet: initFlagSet,
+		Run:     runInit,
+		Help: `
+Create a site with a minimal layout, index page and configuration.
+
+The command does not overwrite existing files.
+`,
+	}
+)
+
+// skeleton is the files created by the init command. The key is the slash
+// separated path relative to the site directory.
+var skeleton = map[string]string{
+	common.ConfigDir + "/site.json": `{
+}
+`,
+	common.ConfigDir + "/s3.txt": `<% set bucket="example.com" region="us-east-1" %>
+`,
+	common.LayoutDir + "/base.html": `<!doctype html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="/site.css">
+</head>
+<body>
+{{.Content}}
+</body>
+</html>
+`,
+	common.PageDir + "/index.html": `<% set title="Home" layout="base.html" %>
+<h1>Hello, world!</h1>
+`,
+	common.StaticDir + "/site.css": `body {
+  font-family: sans-serif;
+  max-width: 40em;
+  margin: 0 auto;
+}
+`,
+}
+
+func runInit() {
+	dir := initFlagSet.Arg(0)
+	if dir == "" {
+		dir = "."
+	}
+	if err := initSite(dir); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Run 'staticsite serve %s' to view the site.", dir)
+}
+
+func initSite(dir string) error {
+	var names []string
+	for name := range skeleton {
+		names = append(names, name)
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			return fmt.Errorf("%s already exists", filepath.Join(dir, filepath.FromSlash(name)))
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fpath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(fpath, []byte(skeleton[name]), 0666); err != nil {
+			return err
+		}
+		if common.Verbose {
+			log.Printf("Created %s", fpath)
+		}
+	}
+	return nil
+}
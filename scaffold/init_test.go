@@ -0,0 +1,27 @@
+package scaffold
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/garyburd/staticsite/site"
+)
+
+func TestInit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scaffold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := initSite(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := initSite(dir); err == nil {
+		t.Error("second initSite did not return error")
+	}
+	err = site.Visit(dir, ioutil.Discard, func(r *site.Resource) error { return nil })
+	if err != nil {
+		t.Errorf("Visit returned error %v", err)
+	}
+}
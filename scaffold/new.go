@@ -0,0 +1,145 @@
+package scaffold
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+var (
+	newFlagSet = flag.NewFlagSet("new", flag.ExitOnError)
+	NewCommand = &common.Command{
+		Name:    "new",
+		Usage:   "new path [directory]",
+		FlagSet: newFlagSet,
+		Run:     runNew,
+		Help: `
+Create a page from an archetype.
+
+The page is created at page/<dir>/<slug>.html where slug is the last element
+of path converted to lowercase with runs of characters other than letters and
+digits replaced by -.
+
+The archetype is the text template archetypes/<section>.html where section is
+the first element of path, or archetypes/default.html if the section
+archetype does not exist. A built-in archetype is used if neither file
+exists. The template data has the fields Title, Slug, Path and Date.
+`,
+	}
+)
+
+const defaultArchetype = `<% set title="{{.Title}}" created="{{.Date}}" path="{{.Path}}" %>
+`
+
+// archetypeData is the data for executing an archetype template.
+type archetypeData struct {
+	// Title derived from the slug.
+	Title string
+
+	// Slug is the last element of the page path.
+	Slug string
+
+	// Path is the URL path of the page.
+	Path string
+
+	// Date is the current time in RFC 3339 format.
+	Date string
+}
+
+func runNew() {
+	if newFlagSet.NArg() < 1 {
+		newFlagSet.Usage()
+	}
+	dir := newFlagSet.Arg(1)
+	if dir == "" {
+		dir = "."
+	}
+	fpath, err := newPage(dir, newFlagSet.Arg(0), time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Created %s", fpath)
+}
+
+func newPage(dir string, upath string, now time.Time) (string, error) {
+	upath = path.Clean("/" + upath)
+	udir, name := path.Split(upath)
+	slug := slugify(name)
+	if slug == "" {
+		return "", fmt.Errorf("cannot create slug from %q", name)
+	}
+
+	data := &archetypeData{
+		Title: title(slug),
+		Slug:  slug,
+		Path:  udir + slug + "/",
+		Date:  now.Format(time.RFC3339),
+	}
+
+	t, err := loadArchetype(dir, strings.SplitN(udir[1:], "/", 2)[0])
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	fpath := filepath.Join(dir, common.PageDir, filepath.FromSlash(udir), slug+".html")
+	if _, err := os.Stat(fpath); err == nil {
+		return "", fmt.Errorf("%s already exists", fpath)
+	}
+	if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+		return "", err
+	}
+	return fpath, ioutil.WriteFile(fpath, buf.Bytes(), 0666)
+}
+
+func loadArchetype(dir string, section string) (*template.Template, error) {
+	var names []string
+	if section != "" {
+		names = append(names, section+".html")
+	}
+	names = append(names, "default.html")
+	for _, name := range names {
+		fpath := filepath.Join(dir, common.ArchetypeDir, name)
+		p, err := ioutil.ReadFile(fpath)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return template.New(fpath).Parse(string(p))
+	}
+	return template.New("default").Parse(defaultArchetype)
+}
+
+// slugify converts s to lowercase and replaces runs of characters other than
+// letters and digits with -.
+func slugify(s string) string {
+	return common.Slug(strings.TrimSuffix(s, ".html"))
+}
+
+// title converts a slug to a title by replacing - with space and
+// capitalizing the words.
+func title(slug string) string {
+	words := strings.Split(slug, "-")
+	for i, w := range words {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, " ")
+}
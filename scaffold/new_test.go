@@ -0,0 +1,25 @@
+package scaffold
+
+import "testing"
+
+var slugifyTests = []struct {
+	s, slug, title string
+}{
+	{"my-post", "my-post", "My Post"},
+	{"My First Post!", "my-first-post", "My First Post"},
+	{"  Go 1.14 Released ", "go-1-14-released", "Go 1 14 Released"},
+	{"café.html", "café", "Café"},
+}
+
+func TestSlugify(t *testing.T) {
+	for _, tt := range slugifyTests {
+		slug := slugify(tt.s)
+		if slug != tt.slug {
+			t.Errorf("slugify(%q) = %q, want %q", tt.s, slug, tt.slug)
+			continue
+		}
+		if got := title(slug); got != tt.title {
+			t.Errorf("title(%q) = %q, want %q", slug, got, tt.title)
+		}
+	}
+}
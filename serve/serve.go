@@ -18,11 +18,13 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/garyburd/staticsite/common"
 	"github.com/garyburd/staticsite/site"
@@ -32,9 +34,13 @@ var (
 	flagSet    = flag.NewFlagSet("serve", flag.ExitOnError)
 	listenAddr = flagSet.String("addr", "127.0.0.1:8080", "serve site at `address`")
 	live       = flagSet.Bool("live", true, "update page in browser on successful reload")
+	spill      = flagSet.Int("spill", 0, "write pages larger than `size` bytes to temporary files; 0 disables")
+	watch      = flagSet.Bool("watch", false, "reload site when files in the site change")
+	interval   = flagSet.Duration("watch-interval", time.Second, "check for changes every `duration` with -watch")
+	hooks      = flagSet.Bool("hooks", false, "run the pre-build and post-build hooks on each load")
 	Command    = &common.Command{
 		Name:    "serve",
-		Usage:   "serve [directoy]",
+		Usage:   "serve [-watch] [directoy]",
 		FlagSet: flagSet,
 		Run:     run,
 	}
@@ -59,8 +65,13 @@ type server struct {
 	live bool
 	dir  string
 
+	// reloadMu serializes reloads from the reload command and the watcher.
+	reloadMu sync.Mutex
+
 	mu        sync.Mutex
 	resources map[string]*site.Resource
+	redirects []*site.Redirect
+	spillDir  string
 	wait      chan struct{}
 }
 
@@ -72,11 +83,20 @@ func run() {
 	}
 
 	var err error
-	s.resources, err = loadResources(s.dir, os.Stderr)
-	if err != nil {
+	start := time.Now()
+	var timings site.Timings
+	s.resources, s.redirects, s.spillDir, err = loadResources(s.dir, common.ErrorWriter(os.Stderr), &timings)
+	if err != nil && *watch {
+		log.Print("Fix errors to reload the site")
+	} else if err != nil {
 		log.Printf("Fix errors and run 'staticsite reload http://%s'", *listenAddr)
 	} else {
-		log.Printf("Loaded %d resources.", len(s.resources))
+		common.LogEvent(&common.Event{
+			Event:    "load",
+			Duration: common.Since(start),
+			Message:  fmt.Sprintf("Loaded %d resources.", len(s.resources)),
+		})
+		logTimings(&timings)
 	}
 
 	mux := http.NewServeMux()
@@ -84,6 +104,10 @@ func run() {
 	mux.HandleFunc(waitPath, s.serveWait)
 	mux.HandleFunc(reloadPath, s.serveReload)
 
+	if *watch {
+		go s.watch(*interval)
+	}
+
 	log.Printf("Listening at %s.", *listenAddr)
 	log.Fatal(http.ListenAndServe(*listenAddr, mux))
 }
@@ -96,10 +120,17 @@ func (s *server) serveResource(resp http.ResponseWriter, req *http.Request) {
 
 	s.mu.Lock()
 	r := s.resources[path]
+	redirects := s.redirects
 	s.mu.Unlock()
 
 	if r == nil {
-		http.Error(resp, "Not Found", http.StatusNotFound)
+		for _, rule := range redirects {
+			if u, ok := rule.Match(path); ok && rule.IsWildcard() {
+				http.Redirect(resp, req, u, http.StatusFound)
+				return
+			}
+		}
+		s.serveNotFound(resp, req)
 		return
 	}
 	f, ct, err := r.Open()
@@ -110,6 +141,13 @@ func (s *server) serveResource(resp http.ResponseWriter, req *http.Request) {
 
 	defer f.Close()
 
+	for k, v := range r.Header {
+		if k == "Content-Security-Policy" && s.live {
+			// The policy blocks the live reload script.
+			continue
+		}
+		resp.Header()[k] = v
+	}
 	resp.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 	resp.Header().Set("Content-Type", ct)
 
@@ -129,6 +167,30 @@ func (s *server) serveResource(resp http.ResponseWriter, req *http.Request) {
 	http.ServeContent(resp, req, r.Path, r.ModTime, f)
 }
 
+// serveNotFound responds with the site's /404.html page if the page exists.
+func (s *server) serveNotFound(resp http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	r := s.resources["/404.html"]
+	s.mu.Unlock()
+	if r == nil {
+		http.Error(resp, "Not Found", http.StatusNotFound)
+		return
+	}
+	f, ct, err := r.Open()
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	resp.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	resp.Header().Set("Content-Type", ct)
+	resp.WriteHeader(http.StatusNotFound)
+	io.Copy(resp, f)
+	if s.live && isTextHTML(ct) {
+		resp.Write(reloadScript)
+	}
+}
+
 var reloadScript = []byte(`<script>
 (() => {
     let wl = window.location;
@@ -163,28 +225,79 @@ func (s *server) serveWait(resp http.ResponseWriter, req *http.Request) {
 
 func (s *server) serveReload(resp http.ResponseWriter, req *http.Request) {
 	resp.Header().Set("Content-Type", "text/plain")
-	resources, err := loadResources(s.dir, resp)
-	if err != nil {
+	if err := s.reload(resp); err != nil {
 		log.Print(err)
-		return
+	}
+}
+
+// reload loads the site and notifies the pages waiting for a reload. Errors
+// in the site are written to w. The current resources are kept on error.
+func (s *server) reload(w io.Writer, options ...site.Option) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	start := time.Now()
+	var timings site.Timings
+	resources, redirects, spillDir, err := loadResources(s.dir, w, &timings, options...)
+	if err != nil {
+		os.RemoveAll(spillDir)
+		return err
 	}
 
 	s.mu.Lock()
 	s.resources = resources
+	s.redirects = redirects
+	oldSpillDir := s.spillDir
+	s.spillDir = spillDir
 	close(s.wait)
 	s.wait = make(chan struct{})
 	s.mu.Unlock()
 
-	log.Printf("Reloaded %d resources", len(resources))
+	os.RemoveAll(oldSpillDir)
+
+	common.LogEvent(&common.Event{
+		Event:    "reload",
+		Duration: common.Since(start),
+		Message:  fmt.Sprintf("Reloaded %d resources", len(resources)),
+	})
+	logTimings(&timings)
+	return nil
 }
 
-func loadResources(dir string, w io.Writer) (map[string]*site.Resource, error) {
+// loadResources loads the site in dir. If the -spill flag is set, the
+// returned directory contains page data and should be removed when the
+// resources are no longer used. The options are added to the options for
+// the build.
+func loadResources(dir string, w io.Writer, timings *site.Timings, extra ...site.Option) (map[string]*site.Resource, []*site.Redirect, string, error) {
+	options := []site.Option{site.WithEnv(common.EnvOr("development")), site.WithTimings(timings), site.WithMaxErrors(common.MaxErrors), site.WithWarningsAsErrors(common.WarningsAsErrors)}
+	options = append(options, extra...)
+	if *hooks {
+		options = append(options, site.WithHooks(true))
+	}
+	var spillDir string
+	if *spill > 0 {
+		var err error
+		spillDir, err = ioutil.TempDir("", "staticsite")
+		if err != nil {
+			return nil, nil, "", err
+		}
+		options = append(options, site.WithSpill(spillDir, *spill))
+	}
 	resources := make(map[string]*site.Resource)
 	err := site.Visit(dir, w, func(r *site.Resource) error {
 		resources[r.Path] = r
 		return nil
-	})
-	return resources, err
+	}, options...)
+	if err != nil {
+		return resources, nil, spillDir, err
+	}
+	redirects, err := site.ReadRedirects(dir)
+	return resources, redirects, spillDir, err
+}
+
+func logTimings(timings *site.Timings) {
+	common.LogTiming("walk", timings.Walk)
+	common.LogTiming("render", timings.Render)
+	common.LogTiming("minify", timings.Minify)
 }
 
 func isTextHTML(ct string) bool {
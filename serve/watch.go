@@ -0,0 +1,152 @@
+// Copyright 2011 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package serve
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/garyburd/staticsite/common"
+	"github.com/garyburd/staticsite/site"
+)
+
+// watchDirs is the site directories watched for changes.
+var watchDirs = []string{
+	common.ConfigDir,
+	common.LayoutDir,
+	common.PageDir,
+	common.StaticDir,
+	common.ThemeDir,
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot is the state of the files used to build a site.
+type snapshot map[string]fileState
+
+// takeSnapshot returns the state of the files in the watched directories
+// of the site in dir and the files in fpaths. Files outside of the watched
+// directories, such as files in mounted directories, are watched through
+// fpaths.
+func takeSnapshot(dir string, fpaths []string) snapshot {
+	s := make(snapshot)
+	for _, name := range watchDirs {
+		filepath.Walk(filepath.Join(dir, name), func(fpath string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if strings.HasPrefix(fi.Name(), ".") {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			s[fpath] = fileState{modTime: fi.ModTime(), size: fi.Size()}
+			return nil
+		})
+	}
+	s.add(fpaths)
+	return s
+}
+
+// add adds the state of the files in fpaths that are not in the snapshot.
+func (s snapshot) add(fpaths []string) {
+	for _, fpath := range fpaths {
+		if _, ok := s[fpath]; ok {
+			continue
+		}
+		if fi, err := os.Stat(fpath); err == nil {
+			s[fpath] = fileState{modTime: fi.ModTime(), size: fi.Size()}
+		} else {
+			s[fpath] = fileState{size: -1}
+		}
+	}
+}
+
+// changed returns a file that differs between the snapshots or "" if the
+// snapshots are equal.
+func (s snapshot) changed(other snapshot) string {
+	for fpath, st := range s {
+		if ost, ok := other[fpath]; !ok || !ost.modTime.Equal(st.modTime) || ost.size != st.size {
+			return fpath
+		}
+	}
+	for fpath := range other {
+		if _, ok := s[fpath]; !ok {
+			return fpath
+		}
+	}
+	return ""
+}
+
+// watchedFiles returns the files used to generate the resources. Spill files
+// are not included.
+func watchedFiles(resources map[string]*site.Resource, spillDir string) []string {
+	var fpaths []string
+	for _, r := range resources {
+		if r.FilePath != "" && (spillDir == "" || !strings.HasPrefix(r.FilePath, spillDir)) {
+			fpaths = append(fpaths, r.FilePath)
+		}
+		fpaths = append(fpaths, r.Dependencies...)
+	}
+	return fpaths
+}
+
+// watch polls the site's files at the given interval and reloads the site
+// when a file changes. The snapshot compared in the next poll is taken when
+// the build starts so that changes made during the build trigger another
+// reload. The build starts after the pre-build hooks run, so files written
+// by the hooks do not trigger a reload.
+func (s *server) watch(interval time.Duration) {
+	s.mu.Lock()
+	last := takeSnapshot(s.dir, watchedFiles(s.resources, s.spillDir))
+	s.mu.Unlock()
+	for range time.Tick(interval) {
+		s.mu.Lock()
+		files := watchedFiles(s.resources, s.spillDir)
+		s.mu.Unlock()
+		fpath := takeSnapshot(s.dir, files).changed(last)
+		if fpath == "" {
+			continue
+		}
+		if common.Verbose {
+			log.Printf("Changed %s", fpath)
+		}
+		var next snapshot
+		err := s.reload(common.ErrorWriter(os.Stderr), site.WithBuildStart(func() {
+			next = takeSnapshot(s.dir, files)
+		}))
+		if err != nil {
+			log.Print(err)
+		}
+		s.mu.Lock()
+		files = watchedFiles(s.resources, s.spillDir)
+		s.mu.Unlock()
+		if next == nil {
+			// The build failed before it started.
+			next = takeSnapshot(s.dir, files)
+		}
+		// Add files used for the first time in the build, such as files in
+		// a newly mounted directory.
+		next.add(files)
+		last = next
+	}
+}
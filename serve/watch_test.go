@@ -0,0 +1,57 @@
+package serve
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mount := filepath.Join(dir, "mount.html")
+	for _, fpath := range []string{
+		filepath.Join(dir, "page", "index.html"),
+		filepath.Join(dir, "page", ".index.html.swp"),
+		filepath.Join(dir, "cache", "x"),
+		mount,
+	} {
+		if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fpath, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := []string{mount}
+	s := takeSnapshot(dir, files)
+
+	for _, tt := range []struct {
+		name   string
+		change func(fpath string) error
+		want   bool
+	}{
+		{"page/.index.html.swp", func(fpath string) error { return ioutil.WriteFile(fpath, []byte("x"), 0666) }, false},
+		{"cache/x", func(fpath string) error { return ioutil.WriteFile(fpath, []byte("x"), 0666) }, false},
+		{"page/index.html", func(fpath string) error { return ioutil.WriteFile(fpath, []byte("x"), 0666) }, true},
+		{"page/index.html", func(fpath string) error { return os.Chtimes(fpath, time.Now(), time.Now().Add(time.Hour)) }, true},
+		{"layout/new.html", func(fpath string) error { return ioutil.WriteFile(fpath, nil, 0666) }, true},
+		{"mount.html", os.Remove, true},
+	} {
+		fpath := filepath.Join(dir, filepath.FromSlash(tt.name))
+		os.MkdirAll(filepath.Dir(fpath), 0777)
+		if err := tt.change(fpath); err != nil {
+			t.Fatal(err)
+		}
+		next := takeSnapshot(dir, files)
+		if got := next.changed(s) != ""; got != tt.want {
+			t.Errorf("change to %s detected = %v, want %v", tt.name, got, tt.want)
+		}
+		s = next
+	}
+}
@@ -0,0 +1,87 @@
+package site
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+type bundleEntry struct {
+	once   sync.Once
+	upath  string
+	fpaths []string
+	err    error
+}
+
+// Bundle returns the path of the bundle with the given name from the Bundles
+// site configuration. The bundle is the concatenation of the bundle's static
+// files. The path includes a hash of the bundle's content so that the bundle
+// can be cached indefinitely by browsers.
+//
+//	<link rel=stylesheet href="{{static.Bundle .Path "/css/site.css"}}">
+func (sf staticFuncs) Bundle(upage string, name string) (string, error) {
+	s := sf.site
+	files, ok := s.config.Bundles[name]
+	if !ok {
+		return "", fmt.Errorf("bundle %q not found in configuration", name)
+	}
+
+	s.bundlesMu.Lock()
+	e := s.bundles[name]
+	if e == nil {
+		e = &bundleEntry{}
+		s.bundles[name] = e
+	}
+	s.bundlesMu.Unlock()
+
+	e.once.Do(func() {
+		e.upath, e.fpaths, e.err = s.createBundle(name, files)
+	})
+	if e.err != nil {
+		return "", e.err
+	}
+	s.addDependency(upage, e.fpaths...)
+	return e.upath, nil
+}
+
+func (s *site) createBundle(name string, files []string) (string, []string, error) {
+	var buf bytes.Buffer
+	var fpaths []string
+	for _, upath := range files {
+		fpath := s.filePath(common.StaticDir, path.Clean("/"+upath))
+		p, err := ioutil.ReadFile(fpath)
+		if err != nil {
+			return "", nil, fmt.Errorf("bundle %s: %w", name, err)
+		}
+		buf.Write(p)
+		if len(p) > 0 && p[len(p)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		fpaths = append(fpaths, fpath)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	base := path.Clean("/" + name)
+	ext := path.Ext(base)
+	upath := fmt.Sprintf("%s.%x%s", strings.TrimSuffix(base, ext), sum[:6], ext)
+	cpath := filepath.Join(s.dir, common.CacheDir, "bundles", fmt.Sprintf("%x%s", sum, ext))
+	if _, err := os.Stat(cpath); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(cpath), 0777); err != nil {
+			return "", nil, err
+		}
+		if err := ioutil.WriteFile(cpath, buf.Bytes(), 0666); err != nil {
+			return "", nil, err
+		}
+	} else if err != nil {
+		return "", nil, err
+	}
+	s.addGenerated(upath, cpath)
+	return upath, fpaths, nil
+}
@@ -0,0 +1,74 @@
+package site
+
+import (
+	"io/ioutil"
+	"regexp"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	dir, cleanup := tempSite(t, "testdata/bundle")
+	defer cleanup()
+
+	s, err := newSite(dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upath, err := staticFuncs{s}.Bundle("/", "/css/site.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^/css/site\.[0-9a-f]{12}\.css$`).MatchString(upath) {
+		t.Errorf("got path %s, want /css/site.<hash>.css", upath)
+	}
+	p, err := ioutil.ReadFile(s.generated[upath])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a{}\nb{}\n"; string(p) != want {
+		t.Errorf("got %q, want %q", p, want)
+	}
+	if _, err := (staticFuncs{s}).Bundle("/", "/missing.css"); err == nil {
+		t.Error("expected error for missing bundle")
+	}
+}
+
+func TestPageBundles(t *testing.T) {
+	const dir = "testdata/pagebundles"
+	got := make(map[string]string)
+	err := Visit(dir, nil, func(r *Resource) error {
+		got[r.Path] = string(r.Data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["/posts/trip/photo.png"]; !ok {
+		t.Errorf("page bundle file not visited, got %v", got)
+	}
+	if _, ok := got["/posts/.notes.txt"]; ok {
+		t.Error("hidden file visited")
+	}
+	if want := `<img src=photo.png alt="" width=30 height=20>`; got["/posts/trip/"] != want {
+		t.Errorf("got page %q, want %q", got["/posts/trip/"], want)
+	}
+
+	s, err := newSite(dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachments, err := pageFuncs{s}.Attachments("/posts/trip/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 1 || attachments[0].Name != "photo.png" || attachments[0].Type != "image/png" || attachments[0].Size == 0 {
+		t.Errorf("got attachments %+v, want photo.png", attachments)
+	}
+	attachments, err = pageFuncs{s}.Attachments("/posts/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 0 {
+		t.Errorf("got %d attachments for /posts/, want 0", len(attachments))
+	}
+}
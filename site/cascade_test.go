@@ -0,0 +1,47 @@
+package site
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDirCascade(t *testing.T) {
+	got := make(map[string]string)
+	err := Visit("testdata/dirlayout", nil, func(r *Resource) error {
+		if r.Path != "/robots.txt" {
+			got[r.Path] = string(r.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"/blog/":        "<h1>Blog</h1>\n",
+		"/blog/a/":      "<h1>A</h1>\n",
+		"/blog/2020/b/": "<h1>B</h1>\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMetaFile(t *testing.T) {
+	got := make(map[string]string)
+	err := Visit("testdata/meta", nil, func(r *Resource) error {
+		if r.Path != "/robots.txt" {
+			got[r.Path] = string(r.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"/a/": "<h1>Inline</h1><p>[go web]</p>\n",
+		"/b/": "<h1>B</h1><p>[]</p>\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
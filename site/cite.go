@@ -0,0 +1,391 @@
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/garyburd/staticsite/common"
+	"github.com/garyburd/staticsite/common/action"
+)
+
+// Reference is an entry in the references file.
+//
+// The references are read from config/references.bib in BibTeX format and
+// from config/references.json. The JSON file is an object mapping citation
+// keys to objects with the same fields as a BibTeX entry:
+//
+//	{"knuth84": {"author": "Donald E. Knuth", "title": "The TeXbook", "year": "1984"}}
+//
+// The action <% cite key="knuth84" %> renders a numbered link to the entry in
+// the page's reference list. The action <% references %> renders the list.
+// If a page cites entries and does not have a references action, the list is
+// added to the end of the page content.
+type Reference struct {
+	Key    string
+	Fields map[string]string
+}
+
+// referenceFiles are the files containing references relative to the
+// config directory.
+var referenceFiles = []string{"references.bib", "references.json"}
+
+// bibliography returns the references for the site.
+func (s *site) bibliography() (map[string]*Reference, error) {
+	s.refsOnce.Do(func() {
+		s.refs, s.refsErr = readReferences(s.dir)
+	})
+	return s.refs, s.refsErr
+}
+
+func readReferences(dir string) (map[string]*Reference, error) {
+	refs := make(map[string]*Reference)
+	for _, name := range referenceFiles {
+		fpath := filepath.Join(dir, common.ConfigDir, name)
+		p, err := ioutil.ReadFile(fpath)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var entries []*Reference
+		if filepath.Ext(name) == ".bib" {
+			entries, err = parseBibTeX(p)
+		} else {
+			entries, err = parseReferencesJSON(p)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fpath, err)
+		}
+		for _, r := range entries {
+			if _, ok := refs[r.Key]; ok {
+				return nil, fmt.Errorf("%s: duplicate reference %q", fpath, r.Key)
+			}
+			refs[r.Key] = r
+		}
+	}
+	return refs, nil
+}
+
+func parseReferencesJSON(p []byte) ([]*Reference, error) {
+	var m map[string]map[string]string
+	if err := json.Unmarshal(p, &m); err != nil {
+		return nil, err
+	}
+	var refs []*Reference
+	for key, fields := range m {
+		r := &Reference{Key: key, Fields: make(map[string]string)}
+		for k, v := range fields {
+			r.Fields[strings.ToLower(k)] = v
+		}
+		refs = append(refs, r)
+	}
+	return refs, nil
+}
+
+// bibParser parses BibTeX entries. String macros and concatenation with #
+// are not supported.
+type bibParser struct {
+	p   []byte
+	pos int
+}
+
+func parseBibTeX(p []byte) ([]*Reference, error) {
+	bp := &bibParser{p: p}
+	var refs []*Reference
+	for {
+		i := bytes.IndexByte(bp.p[bp.pos:], '@')
+		if i < 0 {
+			return refs, nil
+		}
+		bp.pos += i + 1
+		typ := strings.ToLower(bp.word())
+		bp.skipSpace()
+		if bp.pos >= len(bp.p) || (bp.p[bp.pos] != '{' && bp.p[bp.pos] != '(') {
+			return nil, bp.errorf("expected { after @%s", typ)
+		}
+		close := byte('}')
+		if bp.p[bp.pos] == '(' {
+			close = ')'
+		}
+		bp.pos++
+		if typ == "comment" || typ == "string" || typ == "preamble" {
+			if close == ')' {
+				if _, err := bp.until(")"); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			bp.pos--
+			if _, err := bp.braced(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		key, err := bp.until("," + string(close))
+		if err != nil {
+			return nil, err
+		}
+		if bp.p[bp.pos-1] == close {
+			// Entry with no fields.
+			bp.pos--
+		}
+		r := &Reference{Key: strings.TrimSpace(key), Fields: map[string]string{"type": typ}}
+		if r.Key == "" {
+			return nil, bp.errorf("missing key in @%s entry", typ)
+		}
+		for {
+			bp.skipSpace()
+			if bp.pos < len(bp.p) && bp.p[bp.pos] == ',' {
+				bp.pos++
+				bp.skipSpace()
+			}
+			if bp.pos >= len(bp.p) {
+				return nil, bp.errorf("unterminated entry %q", r.Key)
+			}
+			if bp.p[bp.pos] == close {
+				bp.pos++
+				break
+			}
+			name := strings.ToLower(bp.word())
+			bp.skipSpace()
+			if name == "" || bp.pos >= len(bp.p) || bp.p[bp.pos] != '=' {
+				return nil, bp.errorf("expected field in entry %q", r.Key)
+			}
+			bp.pos++
+			bp.skipSpace()
+			value, err := bp.value()
+			if err != nil {
+				return nil, err
+			}
+			r.Fields[name] = value
+		}
+		refs = append(refs, r)
+	}
+}
+
+func (bp *bibParser) errorf(format string, args ...interface{}) error {
+	line := 1 + bytes.Count(bp.p[:bp.pos], []byte{'\n'})
+	return fmt.Errorf("%d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (bp *bibParser) skipSpace() {
+	for bp.pos < len(bp.p) && unicode.IsSpace(rune(bp.p[bp.pos])) {
+		bp.pos++
+	}
+}
+
+func (bp *bibParser) word() string {
+	start := bp.pos
+	for bp.pos < len(bp.p) {
+		c := bp.p[bp.pos]
+		if !(c == '_' || c == '-' || c == ':' || c == '.' || c >= '0' && c <= '9' ||
+			c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			break
+		}
+		bp.pos++
+	}
+	return string(bp.p[start:bp.pos])
+}
+
+// until returns the text before the next byte in seps and advances the
+// position past that byte.
+func (bp *bibParser) until(seps string) (string, error) {
+	i := bytes.IndexAny(bp.p[bp.pos:], seps)
+	if i < 0 {
+		return "", bp.errorf("expected %s", strings.Join(strings.Split(seps, ""), " or "))
+	}
+	start := bp.pos
+	bp.pos += i + 1
+	return string(bp.p[start : bp.pos-1]), nil
+}
+
+// braced returns the text in the braces at the current position with nested
+// braces removed.
+func (bp *bibParser) braced() (string, error) {
+	var b strings.Builder
+	depth := 0
+	for ; bp.pos < len(bp.p); bp.pos++ {
+		switch c := bp.p[bp.pos]; c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				bp.pos++
+				return b.String(), nil
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", bp.errorf("unterminated {")
+}
+
+func (bp *bibParser) value() (string, error) {
+	var v string
+	if bp.pos >= len(bp.p) {
+		return "", bp.errorf("expected field value")
+	}
+	switch c := bp.p[bp.pos]; {
+	case c == '{':
+		var err error
+		v, err = bp.braced()
+		if err != nil {
+			return "", err
+		}
+	case c == '"':
+		start := bp.pos
+		bp.pos++
+		depth := 0
+		for ; bp.pos < len(bp.p); bp.pos++ {
+			c := bp.p[bp.pos]
+			if c == '{' {
+				depth++
+			} else if c == '}' {
+				depth--
+			} else if c == '"' && depth == 0 {
+				break
+			}
+		}
+		if bp.pos >= len(bp.p) {
+			bp.pos = start
+			return "", bp.errorf("unterminated \"")
+		}
+		v = strings.NewReplacer("{", "", "}", "").Replace(string(bp.p[start+1 : bp.pos]))
+		bp.pos++
+	default:
+		v = bp.word()
+		if v == "" {
+			return "", bp.errorf("expected field value")
+		}
+	}
+	return strings.Join(strings.Fields(v), " "), nil
+}
+
+// citations returns the number of each reference cited by the page's
+// actions, the cited references in order of first citation and whether the
+// page has a references action.
+func (s *site) citations(p *Page) (map[string]int, []*Reference, bool, error) {
+	var cited []*Reference
+	numbers := make(map[string]int)
+	hasList := false
+	for _, a := range p.actions {
+		switch a.Name {
+		case "references":
+			hasList = true
+		case "cite":
+			refs, err := s.bibliography()
+			if err != nil {
+				return nil, nil, false, err
+			}
+			keys := citeKeys(a)
+			if len(keys) == 0 {
+				return nil, nil, false, fmt.Errorf("%s: cite requires key argument", a.Location(p.lc))
+			}
+			for _, key := range keys {
+				r := refs[key.Text]
+				if r == nil {
+					return nil, nil, false, fmt.Errorf("%s: reference %q not found", key.Location(p.lc), key.Text)
+				}
+				if numbers[r.Key] == 0 {
+					cited = append(cited, r)
+					numbers[r.Key] = len(cited)
+				}
+			}
+		}
+	}
+	if len(cited) > 0 {
+		for _, name := range referenceFiles {
+			fpath := filepath.Join(s.dir, common.ConfigDir, name)
+			if _, err := os.Stat(fpath); err == nil {
+				s.addDependency(p.Path, fpath)
+			}
+		}
+	}
+	return numbers, cited, hasList, nil
+}
+
+// citeKeys returns the keys in the cite action's comma separated key
+// argument.
+func citeKeys(a *action.Action) []action.Value {
+	v := a.Args["key"]
+	var keys []action.Value
+	for _, k := range strings.Split(v.Text, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			v.Text = k
+			keys = append(keys, v)
+		}
+	}
+	return keys
+}
+
+// writeCite writes the inline citation for the cite action a.
+func writeCite(b *strings.Builder, a *action.Action, numbers map[string]int) {
+	b.WriteString(`<span class=cite>[`)
+	for i, key := range citeKeys(a) {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(b, `<a href="#ref-%s">%d</a>`, html.EscapeString(key.Text), numbers[key.Text])
+	}
+	b.WriteString(`]</span>`)
+}
+
+// writeReferences writes the reference list for the cited references.
+func writeReferences(b *strings.Builder, cited []*Reference) {
+	if len(cited) == 0 {
+		return
+	}
+	b.WriteString(`<ol class=references>`)
+	for _, r := range cited {
+		fmt.Fprintf(b, `<li id="ref-%s">%s</li>`, html.EscapeString(r.Key), formatReference(r))
+	}
+	b.WriteString(`</ol>`)
+}
+
+// formatReference returns HTML for reference r in the form: Authors. Year.
+// Title. Venue.
+func formatReference(r *Reference) string {
+	var parts []string
+	if a := r.Fields["author"]; a != "" {
+		parts = append(parts, html.EscapeString(joinAuthors(a)))
+	}
+	if y := r.Fields["year"]; y != "" {
+		parts = append(parts, html.EscapeString(y))
+	}
+	if t := r.Fields["title"]; t != "" {
+		t = html.EscapeString(t)
+		if u := r.Fields["url"]; u != "" {
+			t = `<a href="` + html.EscapeString(u) + `">` + t + `</a>`
+		}
+		parts = append(parts, "<cite>"+t+"</cite>")
+	}
+	for _, k := range []string{"journal", "booktitle", "publisher", "howpublished"} {
+		if v := r.Fields[k]; v != "" {
+			parts = append(parts, html.EscapeString(v))
+			break
+		}
+	}
+	for i, p := range parts {
+		if !strings.HasSuffix(p, ".") {
+			parts[i] = p + "."
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// joinAuthors converts the BibTeX author list "A and B and C" to "A, B and C".
+func joinAuthors(s string) string {
+	authors := strings.Split(s, " and ")
+	if len(authors) == 1 {
+		return s
+	}
+	return strings.Join(authors[:len(authors)-1], ", ") + " and " + authors[len(authors)-1]
+}
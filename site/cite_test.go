@@ -0,0 +1,82 @@
+package site
+
+import (
+	"testing"
+
+	"github.com/garyburd/staticsite/common/action"
+)
+
+func TestCite(t *testing.T) {
+	got := make(map[string]string)
+	err := Visit("testdata/cite", nil, func(r *Resource) error {
+		got[r.Path] = string(r.Data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"/": `A<span class=cite>[<a href=#ref-kr78>1</a>]</span> B<span class=cite>[<a href=#ref-knuth84>2</a>, <a href=#ref-kr78>1</a>]</span> C<span class=cite>[<a href=#ref-go>3</a>]</span>` + "\n" +
+			`<ol class=references>` +
+			`<li id=ref-kr78>Brian W. Kernighan, Dennis M. Ritchie and Someone Else. 1978. <cite>The C Programming Language</cite>. Bell Labs.</li>` +
+			`<li id=ref-knuth84>Donald E. Knuth. 1984. <cite>The TeXbook</cite>. Addison-Wesley.</li>` +
+			`<li id=ref-go>2009. <cite><a href=https://go.dev/>The Go Programming Language</a></cite>.</li></ol>`,
+		"/list/": `X<span class=cite>[<a href=#ref-go>1</a>]</span>` + "\n" + `<h2>Refs</h2><ol class=references><li id=ref-go>2009. <cite><a href=https://go.dev/>The Go Programming Language</a></cite>.</li></ol>` + "\n",
+	} {
+		if got[path] != want {
+			t.Errorf("%s:\n got %s\nwant %s", path, got[path], want)
+		}
+	}
+}
+
+func TestCiteUnknownKey(t *testing.T) {
+	s, err := newSite("testdata/cite", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Page{Path: "/x"}
+	refs, err := s.bibliography()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 3 {
+		t.Errorf("got %d references, want 3", len(refs))
+	}
+	p.actions, p.lc, err = action.Parse([]byte(`<% cite key=missing %>`), "x.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := s.citations(p); err == nil {
+		t.Error("expected error for missing reference")
+	}
+}
+
+func TestParseBibTeX(t *testing.T) {
+	refs, err := parseBibTeX([]byte("@misc{a}\n@book{b, title = {T}}\n@string(x = \"y\")\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 || refs[0].Key != "a" || refs[1].Key != "b" || refs[1].Fields["title"] != "T" {
+		t.Errorf("got %+v %+v", refs[0], refs[1])
+	}
+}
+
+func TestParseBibTeXErrors(t *testing.T) {
+	for _, src := range []string{
+		"@article{",
+		"@article{key",
+		"@article(key",
+		"@string(",
+		"@string(x = \"y\"",
+		"@article{key, title = {T}",
+		"@article{key, title",
+		"@article{key, title =",
+		"@article{key, title = {T",
+		"@article{key, title = \"T",
+		"@article",
+	} {
+		if refs, err := parseBibTeX([]byte(src)); err == nil {
+			t.Errorf("parseBibTeX(%q) = %v, want error", src, refs)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+// config is the site configuration. The configuration is read from the JSON
+// file config/site.json and the environment file config/site.<env>.json. The
+// files are optional. References of the form ${VAR} in the files are replaced
+// with the value of environment variable VAR. Use $${VAR} for the literal text
+// ${VAR}.
+type config struct {
+	// SourceDir is the directory containing source code for code excerpts.
+	// The path is relative to the site directory. If not set, code excerpts
+	// are read from the static directory.
+	SourceDir string
+
+	// Exec is the list of commands that templates are allowed to run using
+	// util.Exec.
+	Exec []string
+
+	// Diagrams maps diagram types to the command used by the diagram action
+	// to render the type. The command reads the diagram source from standard
+	// input and writes SVG to standard output.
+	//
+	//	"Diagrams": {"graphviz": ["dot", "-Tsvg"]}
+	Diagrams map[string][]string
+
+	// Bundles maps bundle paths to the static files concatenated to create
+	// the bundle. Use static.Bundle to add a bundle to the site and reference
+	// the bundle from a page.
+	//
+	//	"Bundles": {"/css/site.css": ["/css/reset.css", "/css/main.css"]}
+	Bundles map[string][]string
+
+	// GitModTime specifies that page updated times and resource modification
+	// times are set from the last git commit touching the source file.
+	GitModTime bool
+
+	// ExtendedFuncs specifies that the extended set of functions is added
+	// to templates. See template.WithExtendedFuncs.
+	ExtendedFuncs bool
+
+	// Strict specifies that template execution fails when a template
+	// references a missing map key.
+	Strict bool
+
+	// RawTags is a list of additional HTML elements where the minifier
+	// copies the content verbatim.
+	RawTags []string
+
+	// CollapseBooleanAttributes specifies that the minifier removes values
+	// from boolean attributes.
+	CollapseBooleanAttributes bool
+
+	// RemoveRedundantAttributes specifies that the minifier removes
+	// attributes set to the default value.
+	RemoveRedundantAttributes bool
+
+	// Integrity specifies that integrity and crossorigin attributes are
+	// added to script and stylesheet link tags referencing static files.
+	Integrity bool
+
+	// ImageAttributes specifies that missing width and height attributes
+	// are added to img tags referencing static images, and that images after
+	// the first EagerImages images on a page are loaded lazily.
+	ImageAttributes bool
+
+	// EagerImages is the number of images at the start of a page that are
+	// assumed to be above the fold. The default is 2. See ImageAttributes.
+	EagerImages int
+
+	// CSP is the Content-Security-Policy for pages. The hashes of the inline
+	// scripts and styles on a page are added to the script-src and
+	// style-src directives of the page's policy.
+	CSP string
+
+	// CSPMeta specifies that the policy is added to pages as a meta tag.
+	// Otherwise, the policy is added to the resource header.
+	CSPMeta bool
+
+	// BaseURL is the URL of the site without a trailing slash. Example:
+	// https://example.com.
+	BaseURL string
+
+	// Canonical specifies that links to pages are converted to the canonical
+	// form of the page URL and that a canonical link is added to pages.
+	Canonical bool
+
+	// PreBuild is a list of commands run in the site directory before the
+	// site is generated. Each command is a list of arguments. The
+	// environment variable STATICSITE_DIR is set to the site directory.
+	// Hooks are run by the s3 command and by the serve command with the
+	// -hooks flag. See WithHooks.
+	PreBuild [][]string
+
+	// PostBuild is a list of commands run after the site is generated
+	// without errors. In addition to STATICSITE_DIR, the environment
+	// variable STATICSITE_CHANGED is set to the newline separated list of
+	// resources modified since the previous build.
+	PostBuild [][]string
+
+	// Params is arbitrary data for templates. Use site.Params to access the
+	// data from a template.
+	Params map[string]interface{}
+
+	// DisableMinify specifies that pages are not minified.
+	DisableMinify bool
+
+	// Drafts specifies that pages with the draft argument set to true are
+	// included in the site.
+	Drafts bool
+
+	// FollowSymlinks specifies that symbolic links to files and directories
+	// in the static and page directories are followed.
+	FollowSymlinks bool
+
+	// StaticMounts is a list of directories overlaid on the static
+	// directory. Files in the static directory override files in the
+	// mounts and files in later mounts override files in earlier mounts.
+	// Paths are relative to the site directory.
+	StaticMounts []string
+
+	// PageMounts is a list of directories overlaid on the page directory.
+	// See StaticMounts for the override rules.
+	PageMounts []string
+
+	// Taxonomies is the list of taxonomies. A page is generated for each
+	// term in a taxonomy. See taxonomy for the fields.
+	Taxonomies []*taxonomy
+
+	// Sitemap specifies that the sitemap /sitemap.xml is generated for the
+	// HTML pages on the site. BaseURL must be set. If the site has more than
+	// 50,000 pages, /sitemap.xml is a sitemap index referencing the files
+	// /sitemap-<n>.xml.
+	Sitemap bool
+
+	// NotFoundLayout is the layout for the page /404.html. The page is
+	// returned for missing URLs by the serve command. Set errorDocument in
+	// config/s3.txt to use the page as the S3 website error document.
+	NotFoundLayout string
+
+	// Analytics is HTML inserted at the start of the head element of every
+	// HTML page before the page is minified. Use an environment file to
+	// include analytics scripts in production only.
+	Analytics string
+
+	// Typography specifies that straight quotes, dashes and ellipses in the
+	// text of HTML pages are replaced with their typographic forms and that
+	// the last two words of each paragraph are joined with a non-breaking
+	// space. Text in raw tags, code, kbd, samp, var, math and svg elements
+	// is not changed.
+	Typography bool
+
+	// Math specifies that TeX math in the text of HTML pages is converted to
+	// MathML when the site is built. Inline math is delimited by \( and \).
+	// Display math is delimited by \[ and \] or by $$.
+	Math bool
+
+	// PrintLayout is the layout for the print variants of pages. The
+	// argument print="true" in a set or cascade action adds the print
+	// variant of a page at the page's path followed by print/. Links in the
+	// print variant are followed by a footnote number and the link URLs are
+	// listed at the end of the page.
+	PrintLayout string
+
+	// Permalinks maps section directories to permalink patterns for the
+	// pages in the section and descendant directories. The set action path
+	// argument overrides the pattern. Index pages are not changed. See
+	// permalink for the pattern syntax.
+	//
+	//	"Permalinks": {"/blog/": "/blog/:year/:month/:slug/"}
+	Permalinks map[string]string
+
+	// Markdown specifies that files in the page directory with the
+	// extension .md are Markdown pages. The Markdown is converted to HTML
+	// before the page's actions are executed.
+	Markdown bool
+
+	// PageBundles specifies that files other than pages in the page
+	// directory are added to the site. Place the images and attachments for
+	// page dir/name.html in directory dir/name so that the page can reference
+	// the files with relative URLs. Template functions that read static files
+	// also read files in the page directory.
+	PageBundles bool
+
+	// Theme is the name of a theme in the directory themes/<name>. The
+	// theme's layout and static directories are used for files not found in
+	// the site's layout and static directories.
+	Theme string
+}
+
+// readConfig reads the site configuration. If env is not empty, the fields
+// in the optional file config/site.<env>.json override the fields in the
+// base configuration.
+func readConfig(dir string, env string) (*config, error) {
+	c := config{EagerImages: 2}
+	fpath := filepath.Join(dir, common.ConfigDir, "site.json")
+	err := common.DecodeConfigFile(fpath, &c)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if env != "" {
+		fpath := filepath.Join(dir, common.ConfigDir, "site."+env+".json")
+		err := common.DecodeConfigFile(fpath, &c)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
@@ -0,0 +1,23 @@
+package site
+
+import "testing"
+
+func TestReadConfigEnv(t *testing.T) {
+	for _, tt := range []struct {
+		env       string
+		baseURL   string
+		analytics bool
+	}{
+		{"", "http://localhost:8080", false},
+		{"development", "http://localhost:8080", false},
+		{"production", "https://example.com", true},
+	} {
+		c, err := readConfig("testdata/env", tt.env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.BaseURL != tt.baseURL || c.Params["analytics"] != tt.analytics || !c.Strict {
+			t.Errorf("readConfig(%q) = %+v, want BaseURL %q, analytics %v", tt.env, c, tt.baseURL, tt.analytics)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package site
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/garyburd/staticsite/site/html"
+)
+
+// addCSP adds a Content-Security-Policy with the hashes of the inline
+// scripts and styles in data to page p. The policy is added to the page head
+// or to the resource header as specified in the configuration. It is an error
+// if the policy is added to the head and the page does not have a head tag.
+// Warnings are reported for style and event handler attributes because the
+// hashes do not allow them.
+func (s *site) addCSP(p *Page, data []byte) ([]byte, error) {
+	scripts, styles, err := html.Inline(data)
+	if err != nil {
+		return nil, err
+	}
+	policy := cspPolicy(s.config.CSP, scripts, styles)
+	done := false
+	result, err := html.Rewrite(data, func(t *html.Tag) error {
+		for _, a := range t.Attr {
+			if a.Key == "style" {
+				s.reportWarning(p.resource.FilePath, "style attribute on <%s> not allowed by Content-Security-Policy", t.Name)
+			} else if strings.HasPrefix(a.Key, "on") {
+				s.reportWarning(p.resource.FilePath, "%s attribute on <%s> not allowed by Content-Security-Policy", a.Key, t.Name)
+			}
+		}
+		if s.config.CSPMeta && t.Name == "head" && !done {
+			t.After = `<meta http-equiv=Content-Security-Policy content="` + attrEscaper.Replace(policy) + `">`
+			done = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !s.config.CSPMeta {
+		if p.resource.Header == nil {
+			p.resource.Header = make(http.Header)
+		}
+		p.resource.Header.Set("Content-Security-Policy", policy)
+		return data, nil
+	}
+	if !done {
+		return nil, errors.New("Content-Security-Policy not added to page without head tag")
+	}
+	return result, nil
+}
+
+var attrEscaper = strings.NewReplacer(`&`, "&amp;", `"`, "&#34;")
+
+// cspPolicy returns base with the hashes of scripts and styles added to the
+// script-src and style-src directives. If base does not have a script-src or
+// style-src directive, the directive is created from default-src.
+func cspPolicy(base string, scripts [][]byte, styles [][]byte) string {
+	var directives [][]string
+	for _, d := range strings.Split(base, ";") {
+		if f := strings.Fields(d); len(f) > 0 {
+			directives = append(directives, f)
+		}
+	}
+	directives = addCSPHashes(directives, "script-src", scripts)
+	directives = addCSPHashes(directives, "style-src", styles)
+	var b strings.Builder
+	for i, d := range directives {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(strings.Join(d, " "))
+	}
+	return b.String()
+}
+
+func addCSPHashes(directives [][]string, name string, elements [][]byte) [][]string {
+	if len(elements) == 0 {
+		return directives
+	}
+	i := findCSPDirective(directives, name)
+	if i < 0 {
+		j := findCSPDirective(directives, "default-src")
+		if j < 0 {
+			// Inline elements are not restricted.
+			return directives
+		}
+		d := append([]string{name}, directives[j][1:]...)
+		directives = append(directives, d)
+		i = len(directives) - 1
+	}
+	if len(directives[i]) == 2 && directives[i][1] == "'none'" {
+		// 'none' cannot be combined with other sources.
+		directives[i] = directives[i][:1]
+	}
+	for _, e := range elements {
+		sum := sha256.Sum256(e)
+		h := "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+		if !containsString(directives[i], h) {
+			directives[i] = append(directives[i], h)
+		}
+	}
+	return directives
+}
+
+func findCSPDirective(directives [][]string, name string) int {
+	for i, d := range directives {
+		if strings.EqualFold(d[0], name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsString(a []string, s string) bool {
+	for _, e := range a {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package site
+
+import "testing"
+
+var cspPolicyTests = []struct {
+	base    string
+	scripts []string
+	styles  []string
+	want    string
+}{
+	{"default-src 'self'", nil, nil, "default-src 'self'"},
+	{"default-src 'self'", []string{"a()"}, nil, "default-src 'self'; script-src 'self' 'sha256-qVpDBgj7bpq5hMAcGp3AOc79J3Y1Z4HvySTwKrWDoy4='"},
+	{"script-src 'none'; style-src 'self';", []string{"a()"}, []string{"p{}"}, "script-src 'sha256-qVpDBgj7bpq5hMAcGp3AOc79J3Y1Z4HvySTwKrWDoy4='; style-src 'self' 'sha256-gG2yISYereRMiG2lMXrbiUgi0Ubw9p7QCeWcroOvy9Y='"},
+	{"img-src *", []string{"a()"}, nil, "img-src *"},
+}
+
+func TestCSPPolicy(t *testing.T) {
+	for _, tt := range cspPolicyTests {
+		var scripts, styles [][]byte
+		for _, s := range tt.scripts {
+			scripts = append(scripts, []byte(s))
+		}
+		for _, s := range tt.styles {
+			styles = append(styles, []byte(s))
+		}
+		got := cspPolicy(tt.base, scripts, styles)
+		if got != tt.want {
+			t.Errorf("cspPolicy(%q, ...) = %q, want %q", tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestAddCSP(t *testing.T) {
+	s := &site{
+		config:   &config{CSP: "default-src 'self'", CSPMeta: true},
+		warnings: make(map[string]struct{}),
+	}
+	p := &Page{resource: &Resource{FilePath: "page/index.html"}}
+	got, err := s.addCSP(p, []byte(`<html><head><title>x</title></head><p style=color:red onclick=f()>x</p></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `<html><head><meta http-equiv=Content-Security-Policy content="default-src 'self'"><title>x</title></head><p style=color:red onclick=f()>x</p></html>`
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, w := range []string{
+		"page/index.html: warning: style attribute on <p> not allowed by Content-Security-Policy",
+		"page/index.html: warning: onclick attribute on <p> not allowed by Content-Security-Policy",
+	} {
+		if _, ok := s.warnings[w]; !ok {
+			t.Errorf("warning %q not reported, got %v", w, s.warnings)
+		}
+	}
+
+	if _, err := s.addCSP(p, []byte(`<p>x`)); err == nil {
+		t.Error("no error for page without head tag")
+	}
+
+	s.config.CSPMeta = false
+	got, err = s.addCSP(p, []byte(`<p>x`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `<p>x` {
+		t.Errorf("got %q, want %q", got, `<p>x`)
+	}
+	if h := p.resource.Header.Get("Content-Security-Policy"); h != "default-src 'self'" {
+		t.Errorf("header = %q, want %q", h, "default-src 'self'")
+	}
+}
@@ -0,0 +1,85 @@
+package site
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/garyburd/staticsite/common"
+	"github.com/garyburd/staticsite/common/action"
+)
+
+// diagram renders the diagram action a in page p to an SVG resource and
+// writes an img element referencing the resource to b. The action's type
+// argument selects the command from the Diagrams configuration. The diagram
+// source is the action's source argument or the contents of the file argument
+// relative to the page's file. The optional alt argument sets the image's alt
+// text.
+//
+//	<% diagram type=graphviz alt="Flow" source='digraph { a -> b }' %>
+//
+// Rendered diagrams are cached in the site's cache directory.
+func (s *site) diagram(b *strings.Builder, p *Page, a *action.Action) error {
+	lc := p.lc
+	typ := a.Args["type"]
+	command := s.config.Diagrams[typ.Text]
+	if len(command) == 0 {
+		return fmt.Errorf("%s: diagram type %q not configured", a.Location(lc), typ.Text)
+	}
+	var source []byte
+	if v, ok := a.Args["file"]; ok {
+		fpath := filepath.Join(filepath.Dir(p.resource.FilePath), filepath.FromSlash(v.Text))
+		var err error
+		source, err = ioutil.ReadFile(fpath)
+		if err != nil {
+			return fmt.Errorf("%s: %w", v.Location(lc), err)
+		}
+		s.addDependency(p.Path, fpath)
+	} else if v, ok := a.Args["source"]; ok {
+		source = []byte(v.Text)
+	} else {
+		return fmt.Errorf("%s: diagram requires source or file argument", a.Location(lc))
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(command, "\x00") + "\x00" + string(source)))
+	name := fmt.Sprintf("%x.svg", sum[:8])
+	cpath := filepath.Join(s.dir, common.CacheDir, "diagrams", name)
+	if _, err := os.Stat(cpath); os.IsNotExist(err) {
+		if err := s.renderDiagram(cpath, command, source); err != nil {
+			return fmt.Errorf("%s: %w", a.Location(lc), err)
+		}
+	} else if err != nil {
+		return err
+	}
+	upath := "/_diagrams/" + name
+	s.addGenerated(upath, cpath)
+	fmt.Fprintf(b, `<img src="%s" alt="%s">`, upath, html.EscapeString(a.Args["alt"].Text))
+	return nil
+}
+
+// renderDiagram runs command with source as standard input and writes the
+// output to cpath.
+func (s *site) renderDiagram(cpath string, command []string, source []byte) error {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = s.dir
+	cmd.Stdin = bytes.NewReader(source)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("diagram %s: %w: %s", command[0], err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if !bytes.Contains(stdout.Bytes(), []byte("<svg")) {
+		return fmt.Errorf("diagram %s: output is not SVG", command[0])
+	}
+	if err := os.MkdirAll(filepath.Dir(cpath), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cpath, stdout.Bytes(), 0666)
+}
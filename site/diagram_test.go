@@ -0,0 +1,45 @@
+package site
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDiagram(t *testing.T) {
+	dir, cleanup := tempSite(t, "testdata/diagram")
+	defer cleanup()
+
+	got := make(map[string]string)
+	err := Visit(dir, nil, func(r *Resource) error {
+		if strings.HasPrefix(r.Path, "/_diagrams/") {
+			p, err := ioutil.ReadFile(r.FilePath)
+			if err != nil {
+				return err
+			}
+			got[r.Path] = string(p)
+		} else {
+			got[r.Path] = string(r.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range []string{"/", "/file/"} {
+		html := got[page]
+		i := strings.Index(html, "/_diagrams/")
+		j := strings.Index(html, ".svg")
+		if i < 0 || j < i {
+			t.Errorf("%s: diagram not found in %q", page, html)
+			continue
+		}
+		upath := html[i : j+len(".svg")]
+		if !strings.HasPrefix(got[upath], "<svg") {
+			t.Errorf("%s: resource %s = %q, want SVG", page, upath, got[upath])
+		}
+	}
+	if html := got["/"]; !strings.Contains(html, `alt="A &amp; B"`) {
+		t.Errorf("alt not found in %q", html)
+	}
+}
@@ -0,0 +1,57 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+type execCacheEntry struct {
+	once   sync.Once
+	output string
+	err    error
+}
+
+// Exec runs the named command in the site directory and returns the command's
+// standard output with leading and trailing white space removed. The command
+// must be listed in the site configuration Exec allowlist. Commands are run
+// at most once per site visit for a given list of arguments.
+func (uf utilFuncs) Exec(name string, args ...string) (string, error) {
+	allowed := false
+	for _, n := range uf.site.config.Exec {
+		if n == name {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("exec: command %q not in allowlist", name)
+	}
+
+	key := strings.Join(append([]string{name}, args...), "\x00")
+
+	s := uf.site
+	s.execMu.Lock()
+	e := s.execCache[key]
+	if e == nil {
+		e = &execCacheEntry{}
+		s.execCache[key] = e
+	}
+	s.execMu.Unlock()
+
+	e.once.Do(func() {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(name, args...)
+		cmd.Dir = s.dir
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			e.err = fmt.Errorf("exec %s: %w: %s", name, err, bytes.TrimSpace(stderr.Bytes()))
+			return
+		}
+		e.output = strings.TrimSpace(stdout.String())
+	})
+	return e.output, e.err
+}
@@ -0,0 +1,143 @@
+package site
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+var errNoExif = errors.New("no EXIF data")
+
+// readExifTime returns the capture time from the EXIF data in the JPEG file
+// fpath. The DateTimeOriginal tag is used if present. Otherwise, the
+// DateTime tag is used. The time is in the local time of the camera, but is
+// returned as UTC because EXIF does not record the time zone.
+func readExifTime(fpath string) (time.Time, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	tiff, err := readExifSegment(bufio.NewReader(f))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(tiff, []byte("II*\x00")):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(tiff, []byte("MM\x00*")):
+		order = binary.BigEndian
+	default:
+		return time.Time{}, errNoExif
+	}
+
+	const (
+		tagDateTime         = 0x0132
+		tagExifIFD          = 0x8769
+		tagDateTimeOriginal = 0x9003
+	)
+	ifd0 := exifIFD(tiff, order, order.Uint32(tiff[4:]))
+	s := ifd0.ascii(tagDateTime)
+	if off, ok := ifd0.uint32(tagExifIFD); ok {
+		if v := exifIFD(tiff, order, off).ascii(tagDateTimeOriginal); v != "" {
+			s = v
+		}
+	}
+	if s == "" {
+		return time.Time{}, errNoExif
+	}
+	return time.Parse("2006:01:02 15:04:05", s)
+}
+
+// readExifSegment returns the TIFF data from the APP1 segment of a JPEG
+// file.
+func readExifSegment(r *bufio.Reader) ([]byte, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xff, 0xd8} {
+		return nil, errNoExif
+	}
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil || hdr[0] != 0xff {
+			return nil, errNoExif
+		}
+		marker := hdr[1]
+		n := int(binary.BigEndian.Uint16(hdr[2:])) - 2
+		if marker == 0xda || n < 0 {
+			// Start of scan. EXIF data precedes the image data.
+			return nil, errNoExif
+		}
+		p := make([]byte, n)
+		if _, err := io.ReadFull(r, p); err != nil {
+			return nil, errNoExif
+		}
+		if marker == 0xe1 && bytes.HasPrefix(p, []byte("Exif\x00\x00")) && len(p) >= 14 {
+			return p[6:], nil
+		}
+	}
+}
+
+// exifDirectory is an image file directory in TIFF data.
+type exifDirectory struct {
+	tiff    []byte
+	order   binary.ByteOrder
+	entries []byte
+}
+
+func exifIFD(tiff []byte, order binary.ByteOrder, off uint32) exifDirectory {
+	d := exifDirectory{tiff: tiff, order: order}
+	if int(off)+2 > len(tiff) {
+		return d
+	}
+	n := int(order.Uint16(tiff[off:]))
+	start := int(off) + 2
+	if start+n*12 > len(tiff) {
+		return d
+	}
+	d.entries = tiff[start : start+n*12]
+	return d
+}
+
+// entry returns the 12 byte entry for tag.
+func (d exifDirectory) entry(tag uint16) []byte {
+	for i := 0; i+12 <= len(d.entries); i += 12 {
+		e := d.entries[i : i+12]
+		if d.order.Uint16(e) == tag {
+			return e
+		}
+	}
+	return nil
+}
+
+func (d exifDirectory) uint32(tag uint16) (uint32, bool) {
+	e := d.entry(tag)
+	if e == nil {
+		return 0, false
+	}
+	return d.order.Uint32(e[8:]), true
+}
+
+func (d exifDirectory) ascii(tag uint16) string {
+	e := d.entry(tag)
+	if e == nil || d.order.Uint16(e[2:]) != 2 {
+		return ""
+	}
+	n := int(d.order.Uint32(e[4:]))
+	var p []byte
+	if n <= 4 {
+		p = e[8 : 8+n]
+	} else {
+		off := int(d.order.Uint32(e[8:]))
+		if off+n > len(d.tiff) {
+			return ""
+		}
+		p = d.tiff[off : off+n]
+	}
+	return string(bytes.TrimRight(p, "\x00 "))
+}
@@ -1,35 +1,52 @@
 package site
 
 import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	htemplate "html/template"
 	"image"
+	"image/color"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"io/ioutil"
 	"math"
+	"math/rand"
+	"mime"
+	"net/http"
 	"os"
 	"path"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/garyburd/staticsite/common"
+	"github.com/garyburd/staticsite/site/scratch"
 )
 
 func (site *site) templateFuncs() map[string]interface{} {
 	static := staticFuncs{site}
 	page := pageFuncs{site}
+	remote := remoteFuncs{site}
+	util := utilFuncs{site}
 	time := timeFuncs{time.Now()} // snap time once for consitency across pages.
 	return map[string]interface{}{
 		"static":  func() staticFuncs { return static },
+		"ical":    func() icalFuncs { return icalFuncs{} },
 		"page":    func() pageFuncs { return page },
 		"path":    func() pathFuncs { return pathFuncs{} },
+		"remote":  func() remoteFuncs { return remote },
+		"site":    func() siteFuncs { return siteFuncs{site} },
 		"strings": func() stringFuncs { return stringFuncs{} },
 		"time":    func() timeFuncs { return time },
-		"util":    func() utilFuncs { return utilFuncs{} },
+		"util":    func() utilFuncs { return util },
 	}
 }
 
@@ -39,6 +56,121 @@ func (stringFuncs) TrimPrefix(s, prefix string) string   { return strings.TrimPr
 func (stringFuncs) TrimSuffix(s, suffix string) string   { return strings.TrimPrefix(s, suffix) }
 func (stringFuncs) TrimSpace(s string) string            { return strings.TrimSpace(s) }
 func (stringFuncs) ReplaceAll(s, old, new string) string { return strings.ReplaceAll(s, old, new) }
+func (stringFuncs) Title(s string) string                { return strings.Title(s) }
+func (stringFuncs) Upper(s string) string                { return strings.ToUpper(s) }
+func (stringFuncs) Lower(s string) string                { return strings.ToLower(s) }
+func (stringFuncs) Contains(s, substr string) bool       { return strings.Contains(s, substr) }
+func (stringFuncs) HasPrefix(s, prefix string) bool      { return strings.HasPrefix(s, prefix) }
+func (stringFuncs) HasSuffix(s, suffix string) bool      { return strings.HasSuffix(s, suffix) }
+func (stringFuncs) Split(s, sep string) []string         { return strings.Split(s, sep) }
+func (stringFuncs) Join(elems []string, sep string) string {
+	return strings.Join(elems, sep)
+}
+
+func (stringFuncs) Repeat(s string, count int) (string, error) {
+	if count < 0 {
+		return "", errors.New("repeat: negative count")
+	}
+	return strings.Repeat(s, count), nil
+}
+
+// PadLeft pads s on the left with repetitions of pad until s is width
+// characters long.
+func (stringFuncs) PadLeft(s string, width int, pad string) string {
+	return padding(s, width, pad) + s
+}
+
+// PadRight pads s on the right with repetitions of pad until s is width
+// characters long.
+func (stringFuncs) PadRight(s string, width int, pad string) string {
+	return s + padding(s, width, pad)
+}
+
+func padding(s string, width int, pad string) string {
+	n := width - utf8.RuneCountInString(s)
+	if n <= 0 || pad == "" {
+		return ""
+	}
+	var buf strings.Builder
+	for n > 0 {
+		for _, r := range pad {
+			if n <= 0 {
+				break
+			}
+			buf.WriteRune(r)
+			n--
+		}
+	}
+	return buf.String()
+}
+
+var (
+	regexpCacheMu sync.Mutex
+	regexpCache   = make(map[string]*regexp.Regexp)
+)
+
+// compileRegexp returns the compiled regular expression for pattern. Compiled
+// expressions are cached because templates typically evaluate the same
+// pattern for every page.
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	regexpCacheMu.Lock()
+	defer regexpCacheMu.Unlock()
+	if re, ok := regexpCache[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexpCache[pattern] = re
+	return re, nil
+}
+
+// Match returns whether s contains a match of the regular expression pattern.
+func (stringFuncs) Match(s, pattern string) (bool, error) {
+	re, err := compileRegexp(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(s), nil
+}
+
+// FindAll returns all successive matches of the regular expression pattern in
+// s.
+func (stringFuncs) FindAll(s, pattern string) ([]string, error) {
+	re, err := compileRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return re.FindAllString(s, -1), nil
+}
+
+// ReplaceAllRegex replaces matches of the regular expression pattern in s
+// with repl. Inside repl, $ signs are interpreted as in
+// regexp.Regexp.Expand.
+func (stringFuncs) ReplaceAllRegex(s, pattern, repl string) (string, error) {
+	re, err := compileRegexp(pattern)
+	if err != nil {
+		return "", err
+	}
+	return re.ReplaceAllString(s, repl), nil
+}
+
+type siteFuncs struct{ site *site }
+
+// Scratch returns the scratch data with site scope. The data is shared by all
+// pages on the site.
+func (sf siteFuncs) Scratch() *scratch.Scratch { return sf.site.scratch }
+
+// Env returns the name of the environment, such as "development" or
+// "production".
+func (sf siteFuncs) Env() string { return sf.site.env }
+
+// BaseURL returns the URL of the site from the site configuration.
+func (sf siteFuncs) BaseURL() string { return sf.site.config.BaseURL }
+
+// Params returns the parameters from the site configuration.
+func (sf siteFuncs) Params() map[string]interface{} { return sf.site.config.Params }
 
 type pathFuncs struct{}
 
@@ -50,7 +182,17 @@ type timeFuncs struct{ now time.Time }
 
 func (tf timeFuncs) Now() time.Time { return tf.now }
 
-type utilFuncs struct{}
+// Since returns the time elapsed since t. The current time is the time that
+// the site build started.
+func (tf timeFuncs) Since(t time.Time) time.Duration { return tf.now.Sub(t) }
+
+func (timeFuncs) AddDate(t time.Time, years int, months int, days int) time.Time {
+	return t.AddDate(years, months, days)
+}
+
+func (timeFuncs) ParseDuration(s string) (time.Duration, error) { return time.ParseDuration(s) }
+
+type utilFuncs struct{ site *site }
 
 func (utilFuncs) Slice(values ...interface{}) []interface{} { return values }
 
@@ -81,6 +223,125 @@ func (utilFuncs) SliceValues(slice interface{}) ([]*sliceElement, error) {
 	return result, nil
 }
 
+// Seq returns a sequence of integers. With one argument, the sequence is 1
+// through last. With two arguments, the sequence is first through last. With
+// three arguments, the sequence is first through last by increment.
+func (utilFuncs) Seq(args ...int) ([]int, error) {
+	first, incr, last := 1, 1, 0
+	switch len(args) {
+	case 1:
+		last = args[0]
+	case 2:
+		first, last = args[0], args[1]
+	case 3:
+		first, incr, last = args[0], args[1], args[2]
+	default:
+		return nil, errors.New("seq: expected one, two or three arguments")
+	}
+	if incr == 0 {
+		return nil, errors.New("seq: increment must not be zero")
+	}
+	var result []int
+	for i := first; (incr > 0 && i <= last) || (incr < 0 && i >= last); i += incr {
+		result = append(result, i)
+	}
+	return result, nil
+}
+
+// Shuffle returns a shuffled copy of slice. Specify seed for the same result
+// on every build.
+func (utilFuncs) Shuffle(slice interface{}, seed ...int64) (interface{}, error) {
+	v := reflect.ValueOf(slice)
+	if v.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("shuffle: expected slice, got %T", slice)
+	}
+	var r *rand.Rand
+	if len(seed) > 0 {
+		r = rand.New(rand.NewSource(seed[0]))
+	} else {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	result := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(result, v)
+	r.Shuffle(result.Len(), reflect.Swapper(result.Interface()))
+	return result.Interface(), nil
+}
+
+// Uniq returns a copy of slice with duplicate elements removed. The first
+// occurrence of each element is retained.
+func (utilFuncs) Uniq(slice interface{}) (interface{}, error) {
+	v := reflect.ValueOf(slice)
+	if v.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("uniq: expected slice, got %T", slice)
+	}
+	result := reflect.MakeSlice(v.Type(), 0, v.Len())
+	seen := make(map[interface{}]bool)
+outer:
+	for i := 0; i < v.Len(); i++ {
+		e := v.Index(i)
+		x := e.Interface()
+		if x == nil || reflect.TypeOf(x).Comparable() {
+			if seen[x] {
+				continue
+			}
+			seen[x] = true
+		} else {
+			for j := 0; j < result.Len(); j++ {
+				if reflect.DeepEqual(x, result.Index(j).Interface()) {
+					continue outer
+				}
+			}
+		}
+		result = reflect.Append(result, e)
+	}
+	return result.Interface(), nil
+}
+
+func (uf utilFuncs) readStaticFile(upage string, upath string) ([]byte, error) {
+	return ioutil.ReadFile(uf.site.staticFile(upage, upath))
+}
+
+// SHA256 returns the hex encoded SHA-256 digest of a static file.
+func (uf utilFuncs) SHA256(upage string, upath string) (string, error) {
+	p, err := uf.readStaticFile(upage, upath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(p)), nil
+}
+
+// SHA384 returns the hex encoded SHA-384 digest of a static file.
+func (uf utilFuncs) SHA384(upage string, upath string) (string, error) {
+	p, err := uf.readStaticFile(upage, upath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha512.Sum384(p)), nil
+}
+
+// Integrity returns a subresource integrity attribute for a static file.
+func (uf utilFuncs) Integrity(upage string, upath string) (htemplate.HTMLAttr, error) {
+	p, err := uf.readStaticFile(upage, upath)
+	if err != nil {
+		return "", err
+	}
+	return htemplate.HTMLAttr(fmt.Sprintf(`integrity="%s"`, integrity(p))), nil
+}
+
+func integrity(p []byte) string {
+	sum := sha512.Sum384(p)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (utilFuncs) Base64Encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func (utilFuncs) Base64Decode(s string) (string, error) {
+	p, err := base64.StdEncoding.DecodeString(s)
+	return string(p), err
+}
+
 type sliceElement struct {
 	v reflect.Value
 	i int
@@ -128,6 +389,7 @@ type staticFuncs struct{ site *site }
 
 func (sf staticFuncs) VersionedPath(pageDir string, upath string) (string, error) {
 	fpath := sf.site.filePath(common.StaticDir, absPath(upath, upath))
+	sf.site.addDependency(pageDir, fpath)
 	h, err := sf.site.getFileHash(fpath)
 	if err != nil {
 		return "", err
@@ -135,6 +397,142 @@ func (sf staticFuncs) VersionedPath(pageDir string, upath string) (string, error
 	return fmt.Sprintf("%s?v=%s", upath, h), nil
 }
 
+// File describes a file in the static directory.
+type File struct {
+	// Name is the base name of the file.
+	Name string
+
+	// Path is the path of the file, relative to the page when the
+	// directory was specified relative to the page.
+	Path string
+
+	// Size in bytes.
+	Size int64
+
+	// Modification time.
+	ModTime time.Time
+}
+
+// ReadDir returns the files in static directory udir sorted by name.
+// Subdirectories and hidden files are not included in the result.
+func (sf staticFuncs) ReadDir(upage string, udir string) ([]*File, error) {
+	fdir := sf.site.staticFile(upage, udir)
+	f, err := os.Open(fdir)
+	if err != nil {
+		return nil, err
+	}
+	fis, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*File
+	for _, fi := range fis {
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		files = append(files, &File{
+			Name:    fi.Name(),
+			Path:    path.Join(udir, fi.Name()),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// Exists returns whether the file upath exists in the static directory.
+func (sf staticFuncs) Exists(upage string, upath string) bool {
+	_, err := os.Stat(sf.site.staticFile(upage, upath))
+	return err == nil
+}
+
+// DataURI returns the contents of a static file as a data URI.
+func (sf staticFuncs) DataURI(upage string, upath string) (htemplate.URL, error) {
+	p, err := ioutil.ReadFile(sf.site.staticFile(upage, upath))
+	if err != nil {
+		return "", err
+	}
+	ct := mime.TypeByExtension(path.Ext(upath))
+	if ct == "" {
+		ct = http.DetectContentType(p)
+	}
+	ct = strings.ReplaceAll(ct, " ", "")
+	return htemplate.URL("data:" + ct + ";base64," + base64.StdEncoding.EncodeToString(p)), nil
+}
+
+// Code returns an excerpt from a source file. The file is read from the
+// configured source directory or the static directory if a source directory
+// is not configured.
+//
+// If no patterns are specified, the excerpt is the entire file. If one
+// pattern is specified, the excerpt is the first line matching the regular
+// expression. If two patterns are specified, the excerpt is the lines from the
+// first line matching the first pattern through the following line that
+// matches the second pattern.
+//
+// Lines ending with "OMIT" are removed from the excerpt.
+func (sf staticFuncs) Code(upage string, upath string, patterns ...string) (string, error) {
+	var fpath string
+	if sf.site.config.SourceDir != "" {
+		fpath = sf.site.filePath(sf.site.config.SourceDir, absPath(upage, upath))
+		sf.site.addDependency(upage, fpath)
+	} else {
+		fpath = sf.site.staticFile(upage, upath)
+	}
+	p, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.SplitAfter(string(p), "\n")
+
+	start, end := 0, len(lines)
+	switch len(patterns) {
+	case 0:
+	case 1, 2:
+		start, err = matchLine(lines, 0, patterns[0])
+		if err != nil {
+			return "", fmt.Errorf("code %s: %w", upath, err)
+		}
+		end = start + 1
+		if len(patterns) == 2 {
+			end, err = matchLine(lines, start+1, patterns[1])
+			if err != nil {
+				return "", fmt.Errorf("code %s: %w", upath, err)
+			}
+			end++
+		}
+	default:
+		return "", errors.New("code: too many patterns")
+	}
+
+	var buf strings.Builder
+	for _, line := range lines[start:end] {
+		if strings.HasSuffix(strings.TrimRight(line, " \t\r\n"), "OMIT") {
+			continue
+		}
+		buf.WriteString(line)
+	}
+	return buf.String(), nil
+}
+
+// matchLine returns the index of the first line at or after start matching
+// the regular expression pattern.
+func matchLine(lines []string, start int, pattern string) (int, error) {
+	re, err := compileRegexp(pattern)
+	if err != nil {
+		return 0, err
+	}
+	for i := start; i < len(lines); i++ {
+		if re.MatchString(lines[i]) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no line matching %q", pattern)
+}
+
 type Image struct {
 	Width  int
 	Height int
@@ -146,14 +544,64 @@ func (img *Image) SrcWidthHeight() htemplate.HTMLAttr {
 }
 
 func (sf staticFuncs) ReadImage(upage string, upath string) (*Image, error) {
-	fpath := sf.site.filePath(common.StaticDir, absPath(upage, upath))
+	fpath := sf.site.staticFile(upage, upath)
 	config, err := readImageConfig(fpath)
 	return &Image{Src: upath, Width: config.Width, Height: config.Height}, err
 }
 
+// ImageColor returns the average color of the static image upath in the
+// form #rrggbb. Use the color as the background of an image container to
+// show a placeholder while the image loads.
+func (sf staticFuncs) ImageColor(upage string, upath string) (string, error) {
+	fpath := sf.site.staticFile(upage, upath)
+	s := sf.site
+	s.imageColorsMu.Lock()
+	c, ok := s.imageColors[fpath]
+	s.imageColorsMu.Unlock()
+	if ok {
+		return c, nil
+	}
+	f, err := os.Open(fpath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", fpath, err)
+	}
+	avg := color.NRGBAModel.Convert(scaleImage(img, 1, 1).At(0, 0)).(color.NRGBA)
+	c = fmt.Sprintf("#%02x%02x%02x", avg.R, avg.G, avg.B)
+	s.imageColorsMu.Lock()
+	s.imageColors[fpath] = c
+	s.imageColorsMu.Unlock()
+	return c, nil
+}
+
 type ImageSrcSet struct {
 	Image
 	SrcSet string
+
+	// Sizes is the sizes attribute for the srcset. Set by
+	// ReadImageSrcSetSizes.
+	Sizes string
+}
+
+// Attrs returns the src, srcset, sizes, width and height attributes for an
+// img element.
+func (img *ImageSrcSet) Attrs() htemplate.HTMLAttr {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, `src="%s" srcset="%s"`, attrEscaper.Replace(img.Src), attrEscaper.Replace(img.SrcSet))
+	if img.Sizes != "" {
+		fmt.Fprintf(&buf, ` sizes="%s"`, attrEscaper.Replace(img.Sizes))
+	}
+	fmt.Fprintf(&buf, ` width="%d" height="%d"`, img.Width, img.Height)
+	return htemplate.HTMLAttr(buf.String())
+}
+
+// Img returns an img element with the given alt text.
+func (img *ImageSrcSet) Img(alt string) htemplate.HTML {
+	return htemplate.HTML(fmt.Sprintf(`<img %s alt="%s">`, img.Attrs(), attrEscaper.Replace(alt)))
 }
 
 func (sf staticFuncs) ReadImageSrcSet(upage string, upattern string, maxWidth int, maxHeight int) (*ImageSrcSet, error) {
@@ -162,6 +610,8 @@ func (sf staticFuncs) ReadImageSrcSet(upage string, upattern string, maxWidth in
 		return nil, err
 	}
 
+	sf.site.addDependency(upage, fpaths...)
+
 	if len(fpaths) == 0 {
 		return nil, fmt.Errorf("no images found for %s (%s)", upattern, sf.site.filePath(common.StaticDir, absPath(upage, upattern)))
 	}
@@ -179,6 +629,54 @@ func (sf staticFuncs) ReadImageSrcSet(upage string, upattern string, maxWidth in
 	return computeSrcSet(fpaths, upaths, configs, maxWidth, maxHeight)
 }
 
+// ReadImageSrcSetSizes is like ReadImageSrcSet and also sets the sizes
+// attribute. The sizes argument is a list of media conditions and slot
+// widths, for example "(max-width: 600px) 100vw, 600px".
+func (sf staticFuncs) ReadImageSrcSetSizes(upage string, upattern string, maxWidth int, maxHeight int, sizes string) (*ImageSrcSet, error) {
+	img, err := sf.ReadImageSrcSet(upage, upattern, maxWidth, maxHeight)
+	if err != nil {
+		return nil, err
+	}
+	img.Sizes = sizes
+	return img, nil
+}
+
+// Picture returns a picture element for art-directed images. The sources
+// arguments are pairs of a media query and a ReadImageSrcSet pattern. The
+// media query for the last pair must be empty. The last pair is used for the
+// fallback img element. Each image is sized to fit within maxWidth and
+// maxHeight.
+//
+//	{{static.Picture .Path "Skyline" 800 0 "(max-width: 600px)" "skyline-crop-*.jpg" "" "skyline-*.jpg"}}
+func (sf staticFuncs) Picture(upage string, alt string, maxWidth int, maxHeight int, sources ...string) (htemplate.HTML, error) {
+	if len(sources) == 0 || len(sources)%2 != 0 {
+		return "", errors.New("picture: sources must be pairs of media query and pattern")
+	}
+	if sources[len(sources)-2] != "" {
+		return "", errors.New("picture: media query for last source must be empty")
+	}
+	var buf strings.Builder
+	buf.WriteString("<picture>")
+	for i := 0; i < len(sources); i += 2 {
+		media, pattern := sources[i], sources[i+1]
+		img, err := sf.ReadImageSrcSet(upage, pattern, maxWidth, maxHeight)
+		if err != nil {
+			return "", err
+		}
+		if i == len(sources)-2 {
+			buf.WriteString(string(img.Img(alt)))
+			break
+		}
+		if media == "" {
+			return "", errors.New("picture: media query required for all but the last source")
+		}
+		fmt.Fprintf(&buf, `<source media="%s" srcset="%s" width="%d" height="%d">`,
+			attrEscaper.Replace(media), attrEscaper.Replace(img.SrcSet), img.Width, img.Height)
+	}
+	buf.WriteString("</picture>")
+	return htemplate.HTML(buf.String()), nil
+}
+
 func computeSrcSet(fpaths []string, upaths []string, configs []image.Config, maxWidth int, maxHeight int) (*ImageSrcSet, error) {
 
 	if maxWidth <= 0 {
@@ -245,7 +743,9 @@ type pageOption func(*pageOptions)
 type pageOptions struct {
 	lessFn  func(a, b *Page) bool
 	reverse bool
+	offset  int
 	limit   int
+	tail    int
 }
 
 type tempPage struct {
@@ -260,18 +760,130 @@ func (pf pageFuncs) Read(upage string, upath string) (*tempPage, error) {
 	if p == nil {
 		return nil, fmt.Errorf("page %q not found", upath)
 	}
+	pf.site.addPageDependency(upage, p)
 
 	return &tempPage{Page: p, Path: upath}, nil
 }
 
+// Exists returns whether the page with the given path exists.
+func (pf pageFuncs) Exists(upage string, upath string) bool {
+	p := pf.site.getPage(absPath(upage, upath))
+	if p == nil {
+		return false
+	}
+	pf.site.addPageDependency(upage, p)
+	return true
+}
+
+// Attachment is a file in a page bundle.
+type Attachment struct {
+	File
+
+	// Type is the MIME type of the file.
+	Type string
+}
+
+// Attachments returns the files in the bundle for the page at upage sorted
+// by name. The bundle for page dir/name.html is the directory dir/name and the
+// bundle for page dir/index.html is the directory dir. Pages and hidden files
+// are not included. The attachment paths are relative to the page.
+func (pf pageFuncs) Attachments(upage string) ([]*Attachment, error) {
+	s := pf.site
+	if !s.config.PageBundles {
+		return nil, errors.New("attachments: page bundles not enabled in site configuration")
+	}
+	fdir := s.filePath(common.PageDir, strings.TrimSuffix(upage, "/"))
+	s.addDependency(upage, fdir)
+	f, err := os.Open(fdir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	fis, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var attachments []*Attachment
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() || strings.HasPrefix(name, ".") || s.isPageExt(name) || strings.HasSuffix(name, metaFileSuffix) {
+			continue
+		}
+		ct := mime.TypeByExtension(path.Ext(name))
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		attachments = append(attachments, &Attachment{
+			File: File{
+				Name:    name,
+				Path:    name,
+				Size:    fi.Size(),
+				ModTime: fi.ModTime(),
+			},
+			Type: ct,
+		})
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].Name < attachments[j].Name })
+	return attachments, nil
+}
+
+// Count returns the number of pages matching the pattern.
+func (pf pageFuncs) Count(upage string, upattern string) (int, error) {
+	pages, err := pf.site.globPages(absPath(upage, upattern))
+	pf.site.addPageDependency(upage, pages...)
+	return len(pages), err
+}
+
 var pageLessFuncs = map[string]func(a, b *Page) bool{
 	"created": func(a, b *Page) bool { return a.Created.Before(b.Created) },
+	"weight": func(a, b *Page) bool {
+		if a.Weight != b.Weight {
+			return a.Weight < b.Weight
+		}
+		return a.Title < b.Title
+	},
 }
 
+// Limit limits the query result to the first n pages. A negative limit is
+// equivalent to Tail with -n.
 func (pf pageFuncs) Limit(n int) pageOption {
+	if n < 0 {
+		return pf.Tail(-n)
+	}
 	return func(o *pageOptions) { o.limit = n }
+}
 
+// Offset skips the first n pages in the query result.
+func (pf pageFuncs) Offset(n int) pageOption {
+	return func(o *pageOptions) { o.offset = n }
+}
+
+// Tail limits the query result to the last n pages.
+func (pf pageFuncs) Tail(n int) pageOption {
+	return func(o *pageOptions) { o.tail = n }
+}
+
+// applyWindow applies the offset, limit and tail options to pages.
+func (o *pageOptions) applyWindow(pages []*Page) []*Page {
+	if o.offset > 0 {
+		if o.offset < len(pages) {
+			pages = pages[o.offset:]
+		} else {
+			pages = nil
+		}
+	}
+	if o.limit > 0 && o.limit < len(pages) {
+		pages = pages[:o.limit]
+	}
+	if o.tail > 0 && o.tail < len(pages) {
+		pages = pages[len(pages)-o.tail:]
+	}
+	return pages
 }
+
 func (pf pageFuncs) Sort(field string) (pageOption, error) {
 	if field == "" {
 		return nil, nil
@@ -292,6 +904,9 @@ func (pf pageFuncs) Sort(field string) (pageOption, error) {
 	}, nil
 }
 
+// Glob returns the pages matching the pattern. The pages are sorted by path
+// unless a sort option is specified. Pages that are equal according to the
+// sort option are sorted by path.
 func (pf pageFuncs) Glob(upage string, upattern string, options ...pageOption) ([]*tempPage, error) {
 	// TODO: check for valid pattern.
 
@@ -307,6 +922,7 @@ func (pf pageFuncs) Glob(upage string, upattern string, options ...pageOption) (
 	if err != nil {
 		return nil, err
 	}
+	pf.site.addPageDependency(upage, pages...)
 
 	if o.lessFn != nil {
 		var lessFn func(a, b int) bool
@@ -315,18 +931,10 @@ func (pf pageFuncs) Glob(upage string, upattern string, options ...pageOption) (
 		} else {
 			lessFn = func(a, b int) bool { return o.lessFn(pages[a], pages[b]) }
 		}
-		sort.Slice(pages, lessFn)
+		sort.SliceStable(pages, lessFn)
 	}
 
-	if o.limit > 0 {
-		if o.limit < len(pages) {
-			pages = pages[:o.limit]
-		}
-	} else if o.limit < 0 {
-		if o.limit < len(pages) {
-			pages = pages[len(pages)-o.limit:]
-		}
-	}
+	pages = o.applyWindow(pages)
 
 	result := make([]*tempPage, len(pages))
 	for i, p := range pages {
@@ -336,6 +944,62 @@ func (pf pageFuncs) Glob(upage string, upattern string, options ...pageOption) (
 	return result, nil
 }
 
+// NextInSection returns the page following the current page in the current
+// page's directory. The pages are sorted by creation time unless a sort option
+// is specified. NextInSection returns nil if the current page is the last page.
+func (pf pageFuncs) NextInSection(upage string, options ...pageOption) (*tempPage, error) {
+	return pf.inSection(upage, 1, options)
+}
+
+// PrevInSection returns the page preceding the current page in the current
+// page's directory. See NextInSection for more information.
+func (pf pageFuncs) PrevInSection(upage string, options ...pageOption) (*tempPage, error) {
+	return pf.inSection(upage, -1, options)
+}
+
+func (pf pageFuncs) inSection(upage string, delta int, options []pageOption) (*tempPage, error) {
+	cur := pf.site.findPage(upage)
+	if cur == nil {
+		return nil, fmt.Errorf("page %q not found", upage)
+	}
+
+	o := pageOptions{lessFn: pageLessFuncs["created"]}
+	for _, fn := range options {
+		if fn != nil {
+			fn(&o)
+		}
+	}
+
+	pages := pf.site.sectionPages(cur.dir)
+	pf.site.addPageDependency(upage, pages...)
+	sort.Slice(pages, func(i, j int) bool {
+		a, b := pages[i], pages[j]
+		if o.reverse {
+			a, b = b, a
+		}
+		if o.lessFn(a, b) {
+			return true
+		}
+		if o.lessFn(b, a) {
+			return false
+		}
+		return a.queryPath < b.queryPath
+	})
+
+	for i, p := range pages {
+		if p != cur {
+			continue
+		}
+		i += delta
+		if i < 0 || i >= len(pages) {
+			return nil, nil
+		}
+		p = pages[i]
+		return &tempPage{Page: p, Path: shortPath(upage, p.Path)}, nil
+	}
+	return nil, nil
+}
+
 func absPath(upage string, upath string) string {
 	if strings.HasPrefix(upath, "/") {
 		return upath
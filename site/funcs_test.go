@@ -17,6 +17,7 @@ package site
 import (
 	"image"
 	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -127,3 +128,188 @@ func TestWalk(t *testing.T) {
 		}
 	}
 }
+
+func TestImageSrcSetImg(t *testing.T) {
+	img := &ImageSrcSet{
+		Image:  Image{Width: 600, Height: 400, Src: "a.jpg"},
+		SrcSet: "a.jpg 600w,b.jpg 1200w",
+		Sizes:  "(max-width: 600px) 100vw, 600px",
+	}
+	const want = `<img src="a.jpg" srcset="a.jpg 600w,b.jpg 1200w" sizes="(max-width: 600px) 100vw, 600px" width="600" height="400" alt="A &#34;B&#34;">`
+	if got := string(img.Img(`A "B"`)); got != want {
+		t.Errorf("Img() = %s, want %s", got, want)
+	}
+	img.Sizes = ""
+	const wantAttrs = `src="a.jpg" srcset="a.jpg 600w,b.jpg 1200w" width="600" height="400"`
+	if got := string(img.Attrs()); got != wantAttrs {
+		t.Errorf("Attrs() = %s, want %s", got, wantAttrs)
+	}
+}
+
+var padTests = []struct {
+	s, pad      string
+	width       int
+	left, right string
+}{
+	{s: "7", pad: "0", width: 3, left: "007", right: "700"},
+	{s: "abc", pad: "0", width: 2, left: "abc", right: "abc"},
+	{s: "é", pad: "-=", width: 4, left: "-=-é", right: "é-=-"},
+	{s: "x", pad: "", width: 4, left: "x", right: "x"},
+}
+
+func TestPad(t *testing.T) {
+	var sf stringFuncs
+	for _, tt := range padTests {
+		if got := sf.PadLeft(tt.s, tt.width, tt.pad); got != tt.left {
+			t.Errorf("PadLeft(%q, %d, %q) = %q, want %q", tt.s, tt.width, tt.pad, got, tt.left)
+		}
+		if got := sf.PadRight(tt.s, tt.width, tt.pad); got != tt.right {
+			t.Errorf("PadRight(%q, %d, %q) = %q, want %q", tt.s, tt.width, tt.pad, got, tt.right)
+		}
+	}
+}
+
+var codeTests = []struct {
+	patterns []string
+	want     string
+}{
+	{nil, "package example\n\n\nfunc hello() {\n\tfmt.Println(\"world\")\n}\n\nfunc goodbye() {}\n"},
+	{[]string{"^func goodbye"}, "func goodbye() {}\n"},
+	{[]string{"^func hello", "^}"}, "func hello() {\n\tfmt.Println(\"world\")\n}\n"},
+}
+
+func TestCode(t *testing.T) {
+	s, err := newSite("testdata/code", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf := staticFuncs{s}
+	for _, tt := range codeTests {
+		got, err := sf.Code("/", "example.go", tt.patterns...)
+		if err != nil {
+			t.Errorf("Code(%q) returned error %v", tt.patterns, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Code(%q) = %q, want %q", tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestPageWindow(t *testing.T) {
+	var pages []*Page
+	for i := 0; i < 5; i++ {
+		pages = append(pages, &Page{Weight: i})
+	}
+	var pf pageFuncs
+	tests := []struct {
+		options []pageOption
+		want    []int
+	}{
+		{nil, []int{0, 1, 2, 3, 4}},
+		{[]pageOption{pf.Limit(2)}, []int{0, 1}},
+		{[]pageOption{pf.Limit(-2)}, []int{3, 4}},
+		{[]pageOption{pf.Tail(2)}, []int{3, 4}},
+		{[]pageOption{pf.Tail(10)}, []int{0, 1, 2, 3, 4}},
+		{[]pageOption{pf.Offset(1), pf.Limit(2)}, []int{1, 2}},
+		{[]pageOption{pf.Offset(5)}, nil},
+	}
+	for i, tt := range tests {
+		var o pageOptions
+		for _, fn := range tt.options {
+			fn(&o)
+		}
+		var got []int
+		for _, p := range o.applyWindow(pages) {
+			got = append(got, p.Weight)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: got %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+var seqTests = []struct {
+	args []int
+	want []int
+}{
+	{[]int{3}, []int{1, 2, 3}},
+	{[]int{0}, nil},
+	{[]int{2, 4}, []int{2, 3, 4}},
+	{[]int{1, 2, 6}, []int{1, 3, 5}},
+	{[]int{3, -1, 1}, []int{3, 2, 1}},
+	{[]int{1, -1, 3}, nil},
+}
+
+func TestSeq(t *testing.T) {
+	var uf utilFuncs
+	for _, tt := range seqTests {
+		got, err := uf.Seq(tt.args...)
+		if err != nil {
+			t.Errorf("Seq(%v) returned error %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Seq(%v) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+	for _, args := range [][]int{nil, {1, 2, 3, 4}, {1, 0, 3}} {
+		if _, err := uf.Seq(args...); err == nil {
+			t.Errorf("Seq(%v) did not return error", args)
+		}
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	var uf utilFuncs
+	in := []string{"a", "b", "c", "d", "e"}
+	a, err := uf.Shuffle(in, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := uf.Shuffle(in, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("Shuffle with same seed returned %v and %v", a, b)
+	}
+	if want := []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(in, want) {
+		t.Errorf("Shuffle modified argument, got %v, want %v", in, want)
+	}
+	got := append([]string(nil), a.([]string)...)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, in) {
+		t.Errorf("Shuffle(%v) = %v, want permutation", in, a)
+	}
+	if _, err := uf.Shuffle("abc"); err == nil {
+		t.Error("Shuffle of string did not return error")
+	}
+}
+
+var uniqTests = []struct {
+	in, want interface{}
+}{
+	{[]string{"a", "b", "a", "c", "b"}, []string{"a", "b", "c"}},
+	{[]int{1, 1, 1}, []int{1}},
+	{[]interface{}{1, "1", 1, nil, nil}, []interface{}{1, "1", nil}},
+	{[]interface{}{[]int{1}, []int{1}, []int{2}}, []interface{}{[]int{1}, []int{2}}},
+	{[]string{}, []string{}},
+}
+
+func TestUniq(t *testing.T) {
+	var uf utilFuncs
+	for _, tt := range uniqTests {
+		got, err := uf.Uniq(tt.in)
+		if err != nil {
+			t.Errorf("Uniq(%v) returned error %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Uniq(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	if _, err := uf.Uniq(1); err == nil {
+		t.Error("Uniq of int did not return error")
+	}
+}
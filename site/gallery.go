@@ -0,0 +1,89 @@
+package site
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Photo is an image in a gallery.
+type Photo struct {
+	// Image is the full size image.
+	Image
+
+	// Name is the base name of the image file.
+	Name string
+
+	// Thumb is the thumbnail image.
+	Thumb *Image
+
+	// Taken is the capture time from the image's EXIF data or the zero time
+	// if the image does not have EXIF data. The file modification time is not
+	// used so that the order of photos does not depend on when the files
+	// were copied.
+	Taken time.Time
+}
+
+var galleryExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+}
+
+// Gallery returns the JPEG, PNG and GIF images in static directory udir
+// sorted by capture time and name. Images without a capture time are sorted
+// by name before the other images. Thumbnails scaled to fit within
+// thumbWidth and thumbHeight are added to the site. A zero thumbnail
+// dimension is not constrained.
+func (sf staticFuncs) Gallery(upage string, udir string, thumbWidth int, thumbHeight int) ([]*Photo, error) {
+	fdir := sf.site.staticFile(upage, udir)
+	f, err := os.Open(fdir)
+	if err != nil {
+		return nil, err
+	}
+	fis, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var photos []*Photo
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() || strings.HasPrefix(name, ".") || !galleryExts[strings.ToLower(path.Ext(name))] {
+			continue
+		}
+		upath := path.Join(absPath(upage, udir), name)
+		fpath := sf.site.staticFile(upage, upath)
+		config, err := readImageConfig(fpath)
+		if err != nil {
+			return nil, err
+		}
+		p := &Photo{
+			Image: Image{Src: path.Join(udir, name), Width: config.Width, Height: config.Height},
+			Name:  name,
+		}
+		if t, err := readExifTime(fpath); err == nil {
+			p.Taken = t
+		}
+		p.Thumb, err = sf.site.resizeImage(upath, fpath, thumbWidth, thumbHeight)
+		if err != nil {
+			return nil, err
+		}
+		p.Thumb.Src = shortPath(upage, p.Thumb.Src)
+		if p.Thumb.Src == shortPath(upage, upath) {
+			p.Thumb.Src = p.Src
+		}
+		photos = append(photos, p)
+	}
+	sort.Slice(photos, func(i, j int) bool {
+		if !photos[i].Taken.Equal(photos[j].Taken) {
+			return photos[i].Taken.Before(photos[j].Taken)
+		}
+		return photos[i].Name < photos[j].Name
+	})
+	return photos, nil
+}
@@ -0,0 +1,182 @@
+package site
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestImage(t *testing.T, fpath string, w, h int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGallery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gallery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestImage(t, filepath.Join(dir, "static", "photos", "a.png"), 200, 100)
+	writeTestImage(t, filepath.Join(dir, "static", "photos", "b.png"), 50, 50)
+	// The modification time does not affect the order.
+	old := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "static", "photos", "b.png"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := newSite(dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	photos, err := staticFuncs{s}.Gallery("/", "photos", 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(photos) != 2 || photos[0].Name != "a.png" || photos[1].Name != "b.png" {
+		t.Fatalf("got %d photos, want a.png and b.png in order", len(photos))
+	}
+	for _, p := range photos {
+		want := Image{Src: "photos/a.png", Width: 200, Height: 100}
+		wantThumb := Image{Src: "photos/_resized/a-100x50.png", Width: 100, Height: 50}
+		if p.Name == "b.png" {
+			want = Image{Src: "photos/b.png", Width: 50, Height: 50}
+			wantThumb = want
+		}
+		if p.Image != want || *p.Thumb != wantThumb {
+			t.Errorf("%s: got %+v %+v, want %+v %+v", p.Name, p.Image, *p.Thumb, want, wantThumb)
+		}
+	}
+	fpath := s.generated["/photos/_resized/a-100x50.png"]
+	config, err := readImageConfig(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 100 || config.Height != 50 {
+		t.Errorf("resized image is %dx%d, want 100x50", config.Width, config.Height)
+	}
+}
+
+func TestFitSize(t *testing.T) {
+	for _, tt := range []struct{ w, h, mw, mh, ww, wh int }{
+		{200, 100, 100, 100, 100, 50},
+		{100, 200, 100, 100, 50, 100},
+		{50, 50, 100, 100, 50, 50},
+		{200, 100, 0, 20, 40, 20},
+	} {
+		w, h := fitSize(tt.w, tt.h, tt.mw, tt.mh)
+		if w != tt.ww || h != tt.wh {
+			t.Errorf("fitSize(%d, %d, %d, %d) = %d, %d, want %d, %d", tt.w, tt.h, tt.mw, tt.mh, w, h, tt.ww, tt.wh)
+		}
+	}
+}
+
+func TestReadExifTime(t *testing.T) {
+	tiff := []byte("II*\x00\x08\x00\x00\x00" + // header
+		"\x01\x00" + // one entry
+		"\x32\x01\x02\x00\x14\x00\x00\x00\x1a\x00\x00\x00" + // DateTime, ASCII, 20 bytes at 26
+		"\x00\x00\x00\x00" + // next IFD
+		"2020:05:06 07:08:09\x00")
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	n := len(app1) + 2
+	data := append([]byte{0xff, 0xd8, 0xff, 0xe1, byte(n >> 8), byte(n)}, app1...)
+	data = append(data, 0xff, 0xda, 0x00, 0x02)
+
+	f, err := ioutil.TempFile("", "exif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(data)
+	f.Close()
+
+	got, err := readExifTime(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2020, 5, 6, 7, 8, 9, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestImageColor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "color")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{R: 0xff, A: 0xff})
+	img.Set(1, 0, color.RGBA{B: 0xff, A: 0xff})
+	fpath := filepath.Join(dir, "static", "a.png")
+	if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = png.Encode(f, img)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := newSite(dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := staticFuncs{s}.ImageColor("/", "a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#7f007f"; got != want {
+		t.Errorf("ImageColor() = %s, want %s", got, want)
+	}
+}
+
+func TestPicture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "picture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestImage(t, filepath.Join(dir, "static", "crop-400.png"), 400, 400)
+	writeTestImage(t, filepath.Join(dir, "static", "wide-800.png"), 800, 400)
+	writeTestImage(t, filepath.Join(dir, "static", "wide-1600.png"), 1600, 800)
+
+	s, err := newSite(dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := staticFuncs{s}.Picture("/", "Sky", 800, 0, "(max-width: 600px)", "crop-*.png", "", "wide-*.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `<picture>` +
+		`<source media="(max-width: 600px)" srcset="crop-400.png 400w" width="400" height="400">` +
+		`<img src="wide-800.png" srcset="wide-1600.png 1600w,wide-800.png 800w" width="800" height="400" alt="Sky">` +
+		`</picture>`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if _, err := (staticFuncs{s}).Picture("/", "Sky", 800, 0, "(max-width: 600px)", "crop-*.png"); err == nil {
+		t.Error("expected error for media query on last source")
+	}
+}
@@ -0,0 +1,37 @@
+package site
+
+import (
+	"path"
+	"path/filepath"
+
+	"github.com/garyburd/staticsite/common"
+	"github.com/garyburd/staticsite/site/scratch"
+)
+
+// newGeneratedPage returns a page at upath generated from the layout
+// specified in the site configuration.
+func (s *site) newGeneratedPage(upath string, layout string) *Page {
+	fpath := filepath.Join(s.dir, common.ConfigDir, "site.json")
+	s.addDependency(upath, fpath)
+	return &Page{
+		Path:      upath,
+		Title:     path.Base(upath),
+		Scratch:   scratch.New(),
+		resource:  &Resource{Path: upath, FilePath: fpath},
+		queryPath: upath,
+		layout:    layout,
+		layoutLoc: fpath + ":1",
+	}
+}
+
+// visitNotFound generates and visits the page /404.html if a layout is
+// specified in the site configuration.
+func (s *site) visitNotFound() error {
+	if s.config.NotFoundLayout == "" {
+		return nil
+	}
+	p := s.newGeneratedPage("/404.html", s.config.NotFoundLayout)
+	p.Title = "Not Found"
+	p.SitemapExclude = true
+	return s.visitPage(p)
+}
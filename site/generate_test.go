@@ -0,0 +1,22 @@
+package site
+
+import (
+	"testing"
+)
+
+func TestNotFound(t *testing.T) {
+	var got string
+	err := Visit("testdata/notfound", nil, func(r *Resource) error {
+		if r.Path == "/404.html" {
+			got = string(r.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "<h1>Not Found</h1>\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
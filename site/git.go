@@ -0,0 +1,62 @@
+package site
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// readGitModTimes returns the time of the last commit for each file in the
+// git history of directory dir. The keys in the returned map are file paths
+// joined to dir.
+func readGitModTimes(dir string) (map[string]time.Time, error) {
+	cmd := exec.Command("git", "log", "--name-only", "--relative", "--format=%x00%cI")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	times := make(map[string]time.Time)
+	var t time.Time
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case line == "":
+			// skip
+		case line[0] == 0:
+			t, err = time.Parse(time.RFC3339, line[1:])
+			if err != nil {
+				return nil, err
+			}
+		default:
+			fpath := filepath.Join(dir, filepath.FromSlash(line))
+			// The log is in reverse chronological order. Keep the first
+			// time seen for the file.
+			if _, ok := times[fpath]; !ok {
+				times[fpath] = t
+			}
+		}
+	}
+	return times, s.Err()
+}
+
+// gitModTime returns the time of the last commit touching fpath. The zero
+// time is returned if the option is not enabled or the file is not
+// committed.
+func (s *site) gitModTime(fpath string) (time.Time, error) {
+	if !s.config.GitModTime {
+		return time.Time{}, nil
+	}
+	s.gitOnce.Do(func() {
+		s.gitModTimes, s.gitErr = readGitModTimes(s.dir)
+		if s.gitErr != nil {
+			s.gitErr = fmt.Errorf("reading modification times from git: %w", s.gitErr)
+		}
+	})
+	return s.gitModTimes[fpath], s.gitErr
+}
@@ -0,0 +1,96 @@
+package site
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+// headerRule is a rule from the headers file.
+//
+// The headers file config/headers is a list of path patterns, each followed
+// by indented header lines. The patterns are matched against resource paths
+// using the syntax of path.Match extended with ** to match zero or more path
+// elements. Blank lines and lines starting with # are ignored.
+//
+//	/**/*.woff2
+//	  Cache-Control: public, max-age=31536000, immutable
+//	/private/**
+//	  X-Robots-Tag: noindex
+type headerRule struct {
+	pattern string
+	header  http.Header
+}
+
+func readHeaderRules(dir string) ([]*headerRule, error) {
+	fpath := filepath.Join(dir, common.ConfigDir, "headers")
+	f, err := os.Open(fpath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []*headerRule
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if trimmed == text {
+			if _, err := matchPath(trimmed, "/"); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", fpath, line, err)
+			}
+			rules = append(rules, &headerRule{pattern: trimmed, header: make(http.Header)})
+			continue
+		}
+		if len(rules) == 0 {
+			return nil, fmt.Errorf("%s:%d: header before path pattern", fpath, line)
+		}
+		i := strings.Index(trimmed, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("%s:%d: expected header name and value separated by :", fpath, line)
+		}
+		rules[len(rules)-1].header.Add(strings.TrimSpace(trimmed[:i]), strings.TrimSpace(trimmed[i+1:]))
+	}
+	return rules, scanner.Err()
+}
+
+// addHeaders adds the headers from matching rules in the headers file to
+// resource r. Headers set when generating the resource take precedence
+// over the headers file.
+func (s *site) addHeaders(r *Resource) error {
+	generated := make(map[string]bool)
+	for k := range r.Header {
+		generated[k] = true
+	}
+	for _, rule := range s.headerRules {
+		matched, err := matchPath(rule.pattern, r.Path)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+		for k, v := range rule.header {
+			if generated[k] {
+				continue
+			}
+			if r.Header == nil {
+				r.Header = make(http.Header)
+			}
+			r.Header[k] = append(r.Header[k], v...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package site
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestAddHeaders(t *testing.T) {
+	s, err := newSite("testdata/headers", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Resource{
+		Path:   "/fonts/a.woff2",
+		Header: http.Header{"X-Robots-Tag": {"all"}},
+	}
+	if err := s.addHeaders(r); err != nil {
+		t.Fatal(err)
+	}
+	want := http.Header{
+		"Cache-Control": {"public, max-age=31536000, immutable"},
+		"X-Robots-Tag":  {"all"},
+		"X-Test":        {"a", "b"},
+	}
+	if !reflect.DeepEqual(r.Header, want) {
+		t.Errorf("header = %v, want %v", r.Header, want)
+	}
+}
@@ -0,0 +1,107 @@
+package site
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+// WithHooks returns an option that runs the pre-build and post-build hooks
+// in the site configuration if enable is true. Hooks are not run by default
+// so that checks and verification builds do not run external commands.
+func WithHooks(enable bool) Option {
+	return func(o *options) {
+		o.hooks = enable
+	}
+}
+
+// runHooks runs the hook commands in the site directory. The variables in
+// env are added to the environment of the commands.
+func (s *site) runHooks(commands [][]string, env []string) error {
+	for _, args := range commands {
+		if len(args) == 0 {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = s.dir
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("hook %s: %w", strings.Join(args, " "), err)
+		}
+	}
+	return nil
+}
+
+func (s *site) hookEnv() []string {
+	dir, err := filepath.Abs(s.dir)
+	if err != nil {
+		dir = s.dir
+	}
+	return []string{"STATICSITE_DIR=" + dir}
+}
+
+// lastBuildPath returns the path of the file recording the time of the
+// last build with post-build hooks.
+func (s *site) lastBuildPath() string {
+	return filepath.Join(s.dir, common.CacheDir, "hooks", "last-build")
+}
+
+// lastBuildTime returns the time of the last build with post-build hooks.
+// The zero time is returned if there was no previous build.
+func (s *site) lastBuildTime() time.Time {
+	fi, err := os.Stat(s.lastBuildPath())
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+// modifiedSince returns whether resource r or the files used to generate
+// r were modified after time t.
+func modifiedSince(r *Resource, t time.Time) bool {
+	if r.ModTime.After(t) {
+		return true
+	}
+	if r.Data == nil && r.render == nil && r.Dependencies == nil {
+		return false
+	}
+	// The dependencies of a page include the page file. FilePath is not
+	// the page file for pages written to a spill file.
+	fpaths := r.Dependencies
+	if fpaths == nil {
+		fpaths = []string{r.FilePath}
+	}
+	for _, fpath := range fpaths {
+		fi, err := os.Stat(fpath)
+		if err != nil || fi.ModTime().After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// runPostBuildHooks runs the post-build hooks. The environment variable
+// STATICSITE_CHANGED is set to the newline separated list of resources
+// modified since the last build.
+func (s *site) runPostBuildHooks(changed []string, start time.Time) error {
+	env := append(s.hookEnv(), "STATICSITE_CHANGED="+strings.Join(changed, "\n"))
+	if err := s.runHooks(s.config.PostBuild, env); err != nil {
+		return err
+	}
+	fpath := s.lastBuildPath()
+	if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(fpath, nil, 0666); err != nil {
+		return err
+	}
+	return os.Chtimes(fpath, start, start)
+}
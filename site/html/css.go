@@ -0,0 +1,96 @@
+package html
+
+import "bytes"
+
+// minifyCSS returns a minified version of the CSS in src. Comments are
+// removed, except for comments starting with /*! (typically license banners).
+// Whitespace is collapsed and removed where it is not significant.
+func minifyCSS(src []byte) []byte {
+	dst := make([]byte, 0, len(src))
+	space := false
+	for i := 0; i < len(src); {
+		b := src[i]
+		switch {
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f':
+			space = true
+			i++
+			continue
+		case b == '/' && i+1 < len(src) && src[i+1] == '*':
+			n := bytes.Index(src[i+2:], []byte("*/"))
+			end := len(src)
+			if n >= 0 {
+				end = i + 2 + n + 2
+			}
+			if i+2 < len(src) && src[i+2] == '!' {
+				dst = appendCSSSpace(dst, space, '/')
+				dst = append(dst, src[i:end]...)
+				space = false
+			} else {
+				space = true
+			}
+			i = end
+			continue
+		}
+
+		if b == ':' && isDeclaration(src[i+1:]) {
+			// Whitespace before the colon in a declaration is not
+			// significant. In a selector, it separates a pseudo-class from
+			// the previous compound selector.
+			space = false
+		}
+		dst = appendCSSSpace(dst, space, b)
+		space = false
+
+		switch b {
+		case '"', '\'':
+			// Copy string literal.
+			j := i + 1
+			for j < len(src) && src[j] != b {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(src) {
+				j++
+			} else {
+				j = len(src)
+			}
+			dst = append(dst, src[i:j]...)
+			i = j
+			continue
+		case '}':
+			if n := len(dst); n > 0 && dst[n-1] == ';' {
+				dst = dst[:n-1]
+			}
+		}
+		dst = append(dst, b)
+		i++
+	}
+	return dst
+}
+
+// appendCSSSpace appends a space to dst if there was whitespace in the input
+// and the whitespace is significant between the last byte in dst and b.
+func appendCSSSpace(dst []byte, space bool, b byte) []byte {
+	if !space || len(dst) == 0 {
+		return dst
+	}
+	switch dst[len(dst)-1] {
+	case '{', '}', ';', ',', '>', ':', '(', '/':
+		return dst
+	}
+	switch b {
+	case '{', '}', ';', ',', '>', ')':
+		return dst
+	}
+	return append(dst, ' ')
+}
+
+// isDeclaration returns whether the colon before src separates a property
+// from a value. The colon is part of a selector if the next '{' comes before
+// the next ';' or '}'.
+func isDeclaration(src []byte) bool {
+	i := bytes.IndexAny(src, "{;}")
+	return i < 0 || src[i] != '{'
+}
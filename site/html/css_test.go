@@ -0,0 +1,27 @@
+package html
+
+import "testing"
+
+var cssTests = []struct {
+	src, want string
+}{
+	{"body {\n  color: red;\n}\n", "body{color:red}"},
+	{"a , b > c { margin : 0 auto ; }", "a,b>c{margin:0 auto}"},
+	{"a :hover { color : red }", "a :hover{color:red}"},
+	{"@media screen { a :hover { x : y } }", "@media screen{a :hover{x:y}}"},
+	{"a :hover{}", "a :hover{}"},
+	{"p { width: calc(1px + 2px); }", "p{width:calc(1px + 2px)}"},
+	{"/* comment */ p { content: ' a  b ' }", "p{content:' a  b '}"},
+	{"/*! license */\np{}", "/*! license */p{}"},
+	{"@media screen and (min-width: 10px) { p { x: y } }", "@media screen and (min-width:10px){p{x:y}}"},
+	{"color: red; font-weight: bold", "color:red;font-weight:bold"},
+}
+
+func TestCSS(t *testing.T) {
+	for _, tt := range cssTests {
+		got := string(minifyCSS([]byte(tt.src)))
+		if got != tt.want {
+			t.Errorf("minifyCSS(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"golang.org/x/net/html"
+)
+
+// LinkFootnotes adds a footnote number after each link in src and a list of
+// the link URLs at the end of the body. The function resolve converts an
+// href to the URL in the footnote. Links are skipped when resolve returns an
+// empty string. Use LinkFootnotes to show link targets on printed pages.
+func LinkFootnotes(src []byte, resolve func(href string) string) ([]byte, error) {
+	var dst bytes.Buffer
+	var urls []string
+	numbers := make(map[string]int)
+	pending := 0 // footnote number for the current link
+	z := html.NewTokenizer(bytes.NewReader(src))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			err := z.Err()
+			if err != io.EOF {
+				return nil, err
+			}
+			writeFootnoteList(&dst, urls)
+			return dst.Bytes(), nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			// The tokenizer reports <a href=x/> as self-closing.
+			name, hasAttr := z.TagName()
+			if string(name) != "a" {
+				break
+			}
+			raw := append([]byte(nil), z.Raw()...)
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				if string(k) != "href" {
+					continue
+				}
+				u := resolve(string(v))
+				if u == "" {
+					continue
+				}
+				if numbers[u] == 0 {
+					urls = append(urls, u)
+					numbers[u] = len(urls)
+				}
+				pending = numbers[u]
+			}
+			dst.Write(raw)
+			continue
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "a":
+				dst.Write(z.Raw())
+				if pending > 0 {
+					fmt.Fprintf(&dst, "<sup class=link-footnote>[%d]</sup>", pending)
+					pending = 0
+				}
+				continue
+			case "body":
+				writeFootnoteList(&dst, urls)
+				urls = nil
+			}
+		}
+		dst.Write(z.Raw())
+	}
+}
+
+func writeFootnoteList(dst *bytes.Buffer, urls []string) {
+	if len(urls) == 0 {
+		return
+	}
+	dst.WriteString("<ol class=link-footnotes>")
+	for _, u := range urls {
+		dst.WriteString("<li>")
+		dst.WriteString(html.EscapeString(u))
+	}
+	dst.WriteString("</ol>")
+}
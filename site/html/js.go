@@ -0,0 +1,197 @@
+package html
+
+import (
+	"bytes"
+	"errors"
+)
+
+var errJSUnsupported = errors.New("unsupported JavaScript")
+
+// regexpPrecedingKeywords is the set of keywords that can precede a regular
+// expression literal.
+var regexpPrecedingKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true,
+	"new": true, "delete": true, "void": true, "throw": true, "case": true,
+	"do": true, "else": true, "yield": true, "await": true,
+}
+
+// minifyJS returns a minified version of the JavaScript in src. Comments are
+// removed and whitespace is collapsed. Line breaks are retained where they can
+// be significant for automatic semicolon insertion. The function returns an
+// error for input that it cannot safely minify. Callers should use the
+// original source on error.
+func minifyJS(src []byte) ([]byte, error) {
+	if bytes.Contains(src, []byte("<!--")) || bytes.Contains(src, []byte("-->")) {
+		return nil, errJSUnsupported
+	}
+
+	dst := make([]byte, 0, len(src))
+	space, newline := false, false
+	for i := 0; i < len(src); {
+		b := src[i]
+		switch {
+		case b == '\n' || b == '\r':
+			newline = true
+			i++
+			continue
+		case b == ' ' || b == '\t' || b == '\f' || b == '\v':
+			space = true
+			i++
+			continue
+		case b == '/' && i+1 < len(src) && src[i+1] == '/':
+			n := bytes.IndexByte(src[i:], '\n')
+			if n < 0 {
+				i = len(src)
+			} else {
+				i += n
+			}
+			continue
+		case b == '/' && i+1 < len(src) && src[i+1] == '*':
+			n := bytes.Index(src[i+2:], []byte("*/"))
+			if n < 0 {
+				return nil, errJSUnsupported
+			}
+			if bytes.ContainsAny(src[i+2:i+2+n], "\r\n") {
+				newline = true
+			} else {
+				space = true
+			}
+			i += 2 + n + 2
+			continue
+		}
+
+		dst = appendJSSpace(dst, space, newline, b)
+		space, newline = false, false
+
+		var j int
+		switch {
+		case b == '"' || b == '\'':
+			j = scanJSQuoted(src, i, b, false)
+		case b == '`':
+			j = scanJSQuoted(src, i, b, true)
+			if j > 0 && bytes.Contains(src[i:j], []byte("${")) {
+				return nil, errJSUnsupported
+			}
+		case b == '/':
+			allowed, err := regexpAllowed(dst)
+			if err != nil {
+				return nil, err
+			}
+			if !allowed {
+				dst = append(dst, b)
+				i++
+				continue
+			}
+			j = scanJSRegexp(src, i)
+		default:
+			dst = append(dst, b)
+			i++
+			continue
+		}
+		if j < 0 {
+			return nil, errJSUnsupported
+		}
+		dst = append(dst, src[i:j]...)
+		i = j
+	}
+	return dst, nil
+}
+
+func isJSIdent(b byte) bool {
+	return b == '_' || b == '$' || b >= 0x80 ||
+		('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9')
+}
+
+// appendJSSpace appends whitespace to dst if the whitespace in the input is
+// significant between the last byte in dst and b.
+func appendJSSpace(dst []byte, space, newline bool, b byte) []byte {
+	if (!space && !newline) || len(dst) == 0 {
+		return dst
+	}
+	last := dst[len(dst)-1]
+	if newline {
+		if bytes.IndexByte([]byte("{;,(["), last) >= 0 || bytes.IndexByte([]byte(")]};,"), b) >= 0 {
+			return dst
+		}
+		return append(dst, '\n')
+	}
+	if (isJSIdent(last) && isJSIdent(b)) ||
+		(last == b && (b == '+' || b == '-')) ||
+		(last == '/' && (b == '/' || b == '*')) ||
+		// The space in "1 .toString()" ends the numeric literal.
+		('0' <= last && last <= '9' && b == '.') {
+		return append(dst, ' ')
+	}
+	return dst
+}
+
+// regexpAllowed returns whether a / following dst starts a regular expression
+// literal. The function returns errJSUnsupported when the answer depends on
+// the syntax before the closing ) or }, as in "if (x) /a/.test(y)" and
+// "{}\n/a/.test(y)".
+func regexpAllowed(dst []byte) (bool, error) {
+	dst = bytes.TrimRight(dst, " \n")
+	if len(dst) == 0 {
+		return true, nil
+	}
+	last := dst[len(dst)-1]
+	switch last {
+	case ')', '}':
+		return false, errJSUnsupported
+	case ']':
+		return false, nil
+	}
+	if !isJSIdent(last) {
+		return true, nil
+	}
+	i := len(dst)
+	for i > 0 && isJSIdent(dst[i-1]) {
+		i--
+	}
+	return regexpPrecedingKeywords[string(dst[i:])], nil
+}
+
+// scanJSQuoted returns the index following the string literal starting at i
+// or -1 if the literal is not terminated.
+func scanJSQuoted(src []byte, i int, q byte, multiline bool) int {
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '\n', '\r':
+			if !multiline {
+				return -1
+			}
+		case q:
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// scanJSRegexp returns the index following the regular expression literal
+// starting at i or -1 if the literal is not terminated.
+func scanJSRegexp(src []byte, i int) int {
+	class := false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '\n', '\r':
+			return -1
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '/':
+			if !class {
+				j++
+				for j < len(src) && isJSIdent(src[j]) {
+					j++
+				}
+				return j
+			}
+		}
+	}
+	return -1
+}
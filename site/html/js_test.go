@@ -0,0 +1,45 @@
+package html
+
+import "testing"
+
+var jsTests = []struct {
+	src, want string
+}{
+	{"var a = 1;\n// comment\nvar b = 2;", "var a=1;var b=2;"},
+	{"let x = a + +b;", "let x=a+ +b;"},
+	{"f(a)\ng(b)", "f(a)\ng(b)"},
+	{"if (x) {\n  y();\n}\n", "if(x){y();}"},
+	{"s = 'a  // b' + \"c /* d */\";", "s='a  // b'+\"c /* d */\";"},
+	{"r = /a  b\\/[/]/g.test(s) && n / 2;", "r=/a  b\\/[/]/g.test(s)&&n/2;"},
+	{"a = b[0] / c[1] / 2", "a=b[0]/c[1]/2"},
+	{"return /x y/;", "return/x y/;"},
+	{"a = b /* c */ / d", "a=b/d"},
+	{"a = b / /c/", "a=b/ /c/"},
+	{"s = 1 .toString();", "s=1 .toString();"},
+	{"t = `a\n  b`;", "t=`a\n  b`;"},
+	{"t = `${x}`;", ""},
+	{"s = 'unterminated", ""},
+	{"if (x) /a b/.test(y)", ""},
+	{"a = (b) / c", ""},
+	{"{}\n/a b/.test(y)", ""},
+	{"a = {} / b", ""},
+}
+
+func TestJS(t *testing.T) {
+	for _, tt := range jsTests {
+		got, err := minifyJS([]byte(tt.src))
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("minifyJS(%q) = %q, want error", tt.src, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("minifyJS(%q) returned error %v", tt.src, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("minifyJS(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package html
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// mathDelims are the delimiters for TeX math in text. The boolean is true
+// for display math.
+var mathDelims = []struct {
+	open, close string
+	display     bool
+}{
+	{`\(`, `\)`, false},
+	{`\[`, `\]`, true},
+	{`$$`, `$$`, true},
+}
+
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Math converts TeX math in the text of src to MathML. Inline math is
+// delimited by \( and \). Display math is delimited by \[ and \] or by $$.
+// Text in the elements skipped by Typography is not changed. Math cannot
+// contain tags.
+//
+// TeXToMathML documents the supported subset of TeX.
+func Math(src []byte, rawTags []string) ([]byte, error) {
+	raw := textRawTags(rawTags)
+	var dst bytes.Buffer
+	z := html.NewTokenizer(bytes.NewReader(src))
+	depth := 0 // depth in raw elements
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			err := z.Err()
+			if err == io.EOF {
+				err = nil
+			}
+			return dst.Bytes(), err
+		case html.TextToken:
+			if depth == 0 && (bytes.Contains(z.Raw(), []byte(`\`)) || bytes.Contains(z.Raw(), []byte(`$$`))) {
+				if err := writeMathText(&dst, string(z.Text())); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		case html.StartTagToken:
+			if name, _ := z.TagName(); raw[string(name)] {
+				depth++
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); raw[string(name)] && depth > 0 {
+				depth--
+			}
+		}
+		dst.Write(z.Raw())
+	}
+}
+
+// writeMathText writes unescaped text to dst with the math converted to
+// MathML.
+func writeMathText(dst *bytes.Buffer, text string) error {
+	for {
+		start, end := -1, -1
+		var tex string
+		var display bool
+		for _, d := range mathDelims {
+			i := strings.Index(text, d.open)
+			if i < 0 || (start >= 0 && i >= start) {
+				continue
+			}
+			j := strings.Index(text[i+len(d.open):], d.close)
+			if j < 0 {
+				continue
+			}
+			start, end = i, i+len(d.open)+j+len(d.close)
+			tex, display = text[i+len(d.open):i+len(d.open)+j], d.display
+		}
+		if start < 0 {
+			dst.WriteString(textEscaper.Replace(text))
+			return nil
+		}
+		m, err := TeXToMathML(tex, display)
+		if err != nil {
+			return err
+		}
+		dst.WriteString(textEscaper.Replace(text[:start]))
+		dst.WriteString(m)
+		text = text[end:]
+	}
+}
@@ -0,0 +1,61 @@
+package html
+
+import "testing"
+
+var texTests = []struct {
+	tex     string
+	display bool
+	out     string
+}{
+	{`x`, false, `<math><mi>x</mi></math>`},
+	{`x^2 + 1`, false, `<math><mrow><msup><mi>x</mi><mn>2</mn></msup><mo>+</mo><mn>1</mn></mrow></math>`},
+	{`a_{ij}^{n-1}`, false, `<math><msubsup><mi>a</mi><mrow><mi>i</mi><mi>j</mi></mrow><mrow><mi>n</mi><mo>−</mo><mn>1</mn></mrow></msubsup></math>`},
+	{`\frac{\alpha}{2}`, false, `<math><mfrac><mi>α</mi><mn>2</mn></mfrac></math>`},
+	{`\sqrt[3]{x}`, false, `<math><mroot><mi>x</mi><mn>3</mn></mroot></math>`},
+	{`\sum_{i=1}^n i`, true, `<math display=block><mrow><munderover><mo>∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></munderover><mi>i</mi></mrow></math>`},
+	{`\sum_i`, false, `<math><msub><mo>∑</mo><mi>i</mi></msub></math>`},
+	{`\text{if } x<y`, false, `<math><mrow><mtext>if </mtext><mi>x</mi><mo>&lt;</mo><mi>y</mi></mrow></math>`},
+	{`\left( \mathbf{v} \right)`, false, `<math><mrow><mo>(</mo><mi mathvariant=bold>v</mi><mo>)</mo></mrow></math>`},
+}
+
+func TestTeXToMathML(t *testing.T) {
+	for _, tt := range texTests {
+		out, err := TeXToMathML(tt.tex, tt.display)
+		if err != nil {
+			t.Errorf("TeXToMathML(%q) returned error %v", tt.tex, err)
+			continue
+		}
+		if out != tt.out {
+			t.Errorf("TeXToMathML(%q) =\n%s\nwant\n%s", tt.tex, out, tt.out)
+		}
+	}
+}
+
+func TestTeXToMathMLError(t *testing.T) {
+	for _, tex := range []string{`\foo`, `{x`, `x}`, `^2`, `x^`, `x^1^2`, `\frac{1}`} {
+		if _, err := TeXToMathML(tex, false); err == nil {
+			t.Errorf("TeXToMathML(%q) did not return error", tex)
+		}
+	}
+}
+
+var mathTests = []struct {
+	in, out string
+}{
+	{`<p>Let \(x\) be &amp; $$y$$.`, `<p>Let <math><mi>x</mi></math> be &amp; <math display=block><mi>y</mi></math>.`},
+	{`<p>Costs $5 and $6 \not math`, `<p>Costs $5 and $6 \not math`},
+	{`<code>\(x\)</code>`, `<code>\(x\)</code>`},
+}
+
+func TestMath(t *testing.T) {
+	for _, tt := range mathTests {
+		out, err := Math([]byte(tt.in), nil)
+		if err != nil {
+			t.Errorf("Math(%q) returned error %v", tt.in, err)
+			continue
+		}
+		if string(out) != tt.out {
+			t.Errorf("Math(%q) = %q, want %q", tt.in, out, tt.out)
+		}
+	}
+}
@@ -2,27 +2,139 @@ package html
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 
+	"github.com/garyburd/staticsite/common"
 	"golang.org/x/net/html"
 )
 
+// rawTags is the set of elements where the content is copied verbatim.
 var rawTags = map[string]bool{
-	"script":   true,
-	"pre":      true,
-	"style":    true,
-	"code":     true,
-	"textarea": true,
+	"script":    true,
+	"pre":       true,
+	"style":     true,
+	"code":      true,
+	"textarea":  true,
+	"xmp":       true,
+	"listing":   true,
+	"plaintext": true,
+}
+
+// voidElements is the set of elements that cannot have content.
+var voidElements = map[string]bool{
+	"area":   true,
+	"base":   true,
+	"br":     true,
+	"col":    true,
+	"embed":  true,
+	"hr":     true,
+	"img":    true,
+	"input":  true,
+	"link":   true,
+	"meta":   true,
+	"param":  true,
+	"source": true,
+	"track":  true,
+	"wbr":    true,
+}
+
+// Options specifies options for MinifyWithOptions.
+type Options struct {
+	// RawTags is a list of additional elements where the content is copied
+	// verbatim. Use this option for custom elements where whitespace is
+	// significant.
+	RawTags []string
+
+	// CollapseBooleanAttributes specifies that values are removed from
+	// boolean attributes. Example: disabled="disabled" -> disabled.
+	CollapseBooleanAttributes bool
+
+	// RemoveRedundantAttributes specifies that attributes set to the
+	// default value are removed. Example: <script type="text/javascript">
+	// -> <script>.
+	RemoveRedundantAttributes bool
+
+	// Warn is called with a message for each start tag that is not
+	// terminated before the next tag, as in "<img src=a.png <p>". The
+	// minifier terminates the tag before the next tag.
+	Warn func(msg string)
+}
+
+// booleanAttributes is the set of HTML boolean attributes.
+var booleanAttributes = map[string]bool{
+	"allowfullscreen": true,
+	"async":           true,
+	"autofocus":       true,
+	"autoplay":        true,
+	"checked":         true,
+	"controls":        true,
+	"default":         true,
+	"defer":           true,
+	"disabled":        true,
+	"formnovalidate":  true,
+	"hidden":          true,
+	"ismap":           true,
+	"itemscope":       true,
+	"loop":            true,
+	"multiple":        true,
+	"muted":           true,
+	"nomodule":        true,
+	"novalidate":      true,
+	"open":            true,
+	"playsinline":     true,
+	"readonly":        true,
+	"required":        true,
+	"reversed":        true,
+	"selected":        true,
+}
+
+// redundantAttributes is the set of element, attribute and value triples
+// where the value is the default.
+var redundantAttributes = map[[3]string]bool{
+	{"script", "type", "text/javascript"}: true,
+	{"script", "language", "javascript"}:  true,
+	{"style", "type", "text/css"}:         true,
+	{"link", "type", "text/css"}:          true,
+	{"form", "method", "get"}:             true,
+	{"input", "type", "text"}:             true,
 }
 
 // Minify returns a mininfied version if the HTML in src.
 func Minify(src []byte) ([]byte, error) {
+	return MinifyWithOptions(src, nil)
+}
+
+// MinifyWithOptions returns a minified version of the HTML in src using the
+// specified options.
+func MinifyWithOptions(src []byte, o *Options) ([]byte, error) {
+	if o == nil {
+		o = &Options{}
+	}
+
+	isRaw := func(name []byte) bool { return rawTags[string(name)] }
+	if len(o.RawTags) > 0 {
+		m := make(map[string]bool)
+		for k := range rawTags {
+			m[k] = true
+		}
+		for _, k := range o.RawTags {
+			m[k] = true
+		}
+		isRaw = func(name []byte) bool { return m[string(name)] }
+	}
 
+	src = common.NormalizeText(src)
 	dst := make([]byte, 0, len(src))
 	z := html.NewTokenizer(bytes.NewReader(src))
 	raw := 0
+	style := false
+	script := false
+	pos := 0
 	for {
 		tt := z.Next()
+		tokenPos := pos
+		pos += len(z.Raw())
 		switch tt {
 		case html.ErrorToken:
 			err := z.Err()
@@ -31,17 +143,50 @@ func Minify(src []byte) ([]byte, error) {
 			}
 			return dst, err
 		case html.StartTagToken, html.SelfClosingTagToken:
-			name, hasAttr := z.TagName()
-			if rawTags[string(name)] {
-				raw++
+			startTag := func(name []byte) {
+				if isRaw(name) && !voidElements[string(name)] && tt == html.StartTagToken {
+					raw++
+				}
+				if string(name) == "style" {
+					style = true
+				}
+				if string(name) == "script" {
+					script = true
+				}
+				dst = append(dst, '<')
+				dst = append(dst, name...)
 			}
-			dst = append(dst, '<')
-			dst = append(dst, name...)
+			name, hasAttr := z.TagName()
+			startTag(name)
 			for hasAttr {
 				var k, v []byte
 				k, v, hasAttr = z.TagAttr()
+				if bytes.HasPrefix(k, []byte("<")) {
+					// The tokenizer parsed the next tag as an attribute.
+					if o.Warn != nil {
+						o.Warn(fmt.Sprintf("line %d: unterminated <%s> tag",
+							1+bytes.Count(src[:tokenPos], []byte("\n")), name))
+					}
+					dst = append(dst, '>')
+					name = k[1:]
+					startTag(name)
+					continue
+				}
+				if string(name) == "script" && string(k) == "type" && !jsTypes[string(v)] {
+					script = false
+				}
+				if string(k) == "style" {
+					v = bytes.TrimSuffix(minifyCSS(v), []byte(";"))
+				}
+				if o.RemoveRedundantAttributes &&
+					redundantAttributes[[3]string{string(name), string(k), string(bytes.ToLower(v))}] {
+					continue
+				}
 				dst = append(dst, ' ')
 				dst = append(dst, k...)
+				if o.CollapseBooleanAttributes && booleanAttributes[string(k)] {
+					continue
+				}
 				dst = append(dst, '=')
 				if needsQuote(v) {
 					dst = append(dst, '"')
@@ -54,17 +199,37 @@ func Minify(src []byte) ([]byte, error) {
 			dst = append(dst, '>')
 		case html.EndTagToken:
 			name, _ := z.TagName()
+			if voidElements[string(name)] {
+				// End tags are not allowed for void elements.
+				continue
+			}
 			dst = append(dst, "</"...)
 			dst = append(dst, name...)
 			dst = append(dst, '>')
-			if rawTags[string(name)] {
+			if isRaw(name) && raw > 0 {
 				raw--
 			}
+			if string(name) == "style" {
+				style = false
+			}
+			if string(name) == "script" {
+				script = false
+			}
 		case html.CommentToken:
-			// Skip
+			if keepComment(z.Text()) {
+				dst = append(dst, z.Raw()...)
+			}
 		case html.TextToken:
 			p := z.Raw()
-			if raw > 0 {
+			if style {
+				dst = append(dst, minifyCSS(p)...)
+			} else if script {
+				if js, err := minifyJS(p); err == nil {
+					dst = append(dst, js...)
+				} else {
+					dst = append(dst, p...)
+				}
+			} else if raw > 0 {
 				dst = append(dst, p...)
 			} else {
 				dst = appendMinText(dst, p)
@@ -75,6 +240,24 @@ func Minify(src []byte) ([]byte, error) {
 	}
 }
 
+// jsTypes is the set of script type attribute values for JavaScript.
+var jsTypes = map[string]bool{
+	"":                       true,
+	"module":                 true,
+	"text/javascript":        true,
+	"application/javascript": true,
+}
+
+// keepComment returns whether the comment with the given text should be
+// preserved. Conditional comments and comments starting with ! (typically
+// license banners) are preserved.
+func keepComment(text []byte) bool {
+	return bytes.HasPrefix(text, []byte("!")) ||
+		bytes.HasPrefix(text, []byte("[if ")) ||
+		bytes.HasPrefix(text, []byte("[endif]")) ||
+		bytes.HasPrefix(text, []byte("<![endif]"))
+}
+
 func appendMinText(dst []byte, src []byte) []byte {
 	emitNL, emitSpace := false, false
 
@@ -16,13 +16,14 @@ var src1 = `
   <style>
   body {
 
-    /* No change expected here. */
+    /* Comments are removed. */
+    color: red;
 
   }
   </style>
   <script>
 
-    // Don't touch anything here.
+    // Comments are removed.
 
   </script>
   <SCRIPT>
@@ -59,23 +60,9 @@ var want1 = `<!doctype html>
 <meta charset=utf-8>
 <meta name=description content="this is a quote: &#34;">
 <title>Sample document</title>
-<style>
-  body {
-
-    /* No change expected here. */
-
-  }
-  </style>
-<script>
-
-    // Don't touch anything here.
-
-  </script>
-<script>
-
-    Another script
-
-  </script>
+<style>body{color:red}</style>
+<script></script>
+<script>Another script</script>
 <body>
 <p>
 No is the time
@@ -87,7 +74,7 @@ for <b>all</b> good men.
 
         Text
     </pre>
-<img alt="" width=100 src=foo&bar.html <footer="">
+<img alt="" width=100 src=foo&bar.html><footer>
 Copyright &copy; Author
 </footer>
 </body>
@@ -98,6 +85,26 @@ var minTests = []struct {
 	src, want string
 }{
 	{src1, want1},
+	{
+		`<p>a</p><!--! License MIT --><!--[if IE]><p>IE</p><![endif]--><!-- drop -->`,
+		`<p>a</p><!--! License MIT --><!--[if IE]><p>IE</p><![endif]-->`,
+	},
+	{
+		`<p style="color: red; margin: 0 ;">x</p>`,
+		`<p style=color:red;margin:0>x</p>`,
+	},
+	{
+		"<script type=\"text/template\">\n  <p>  x </p>\n</script>",
+		"<script type=text/template>\n  <p>  x </p>\n</script>",
+	},
+	{
+		`<img src=a.png></img><br/><p>x</p>`,
+		`<img src=a.png><br><p>x</p>`,
+	},
+	{
+		"\xef\xbb\xbf<p>\r\na\r\nb</p>\r\n<pre>x\r\ny</pre>",
+		"<p>\na\nb</p>\n<pre>x\ny</pre>",
+	},
 }
 
 func TestMin(t *testing.T) {
@@ -130,3 +137,42 @@ func TestMin(t *testing.T) {
 		}
 	}
 }
+
+func TestMinUnterminatedTag(t *testing.T) {
+	src := "<p>\n<img src=x.png\n<a href=b.html>x</a>"
+	var warnings []string
+	got, err := MinifyWithOptions([]byte(src), &Options{Warn: func(msg string) { warnings = append(warnings, msg) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "<p>\n<img src=x.png><a href=b.html>x</a>"
+	if string(got) != want {
+		t.Errorf("MinifyWithOptions(%q) = %q, want %q", src, got, want)
+	}
+	if len(warnings) != 1 || warnings[0] != "line 2: unterminated <img> tag" {
+		t.Errorf("got warnings %q, want unterminated tag warning", warnings)
+	}
+}
+
+func TestMinRawTags(t *testing.T) {
+	src := "<x-code>  a\n  b </x-code>"
+	got, err := MinifyWithOptions([]byte(src), &Options{RawTags: []string{"x-code"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != src {
+		t.Errorf("MinifyWithOptions(%q) = %q, want %q", src, got, src)
+	}
+}
+
+func TestMinAttributes(t *testing.T) {
+	src := `<script type="text/javascript" defer="defer"></script><input type="text" disabled=""><input type="checkbox" checked>`
+	want := `<script defer></script><input disabled><input type=checkbox checked>`
+	got, err := MinifyWithOptions([]byte(src), &Options{CollapseBooleanAttributes: true, RemoveRedundantAttributes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("MinifyWithOptions(%q) = %q, want %q", src, got, want)
+	}
+}
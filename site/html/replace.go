@@ -0,0 +1,90 @@
+package html
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// WordReplacer replaces whole words in text.
+type WordReplacer struct {
+	re  *regexp.Regexp
+	new map[string]string
+}
+
+// NewWordReplacer returns a WordReplacer from a list of old, new string
+// pairs. An old string that starts or ends with an ASCII letter, digit or _
+// only matches at a word boundary on that side. Replacements are performed in
+// the order they appear in the text, without overlapping matches, and
+// comparisons are done in argument order.
+func NewWordReplacer(oldnew ...string) *WordReplacer {
+	r := &WordReplacer{new: make(map[string]string)}
+	var patterns []string
+	for i := 0; i+1 < len(oldnew); i += 2 {
+		old := oldnew[i]
+		if old == "" {
+			continue
+		}
+		if _, ok := r.new[old]; ok {
+			continue
+		}
+		r.new[old] = oldnew[i+1]
+		p := regexp.QuoteMeta(old)
+		if c, _ := utf8.DecodeRuneInString(old); isWordRune(c) {
+			p = `\b` + p
+		}
+		if c, _ := utf8.DecodeLastRuneInString(old); isWordRune(c) {
+			p = p + `\b`
+		}
+		patterns = append(patterns, p)
+	}
+	r.re = regexp.MustCompile(strings.Join(patterns, "|"))
+	return r
+}
+
+func isWordRune(r rune) bool {
+	return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
+
+// Replace returns a copy of s with all replacements performed.
+func (r *WordReplacer) Replace(s string) string {
+	return r.re.ReplaceAllStringFunc(s, func(m string) string { return r.new[m] })
+}
+
+// ReplaceText applies replacer r to the text in src. Text in the elements
+// skipped by Typography is not changed. The replacement strings are HTML.
+func ReplaceText(src []byte, rawTags []string, r *WordReplacer) ([]byte, error) {
+	raw := textRawTags(rawTags)
+	var dst bytes.Buffer
+	z := html.NewTokenizer(bytes.NewReader(src))
+	depth := 0 // depth in raw elements
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			err := z.Err()
+			if err == io.EOF {
+				err = nil
+			}
+			return dst.Bytes(), err
+		case html.TextToken:
+			if depth == 0 {
+				dst.WriteString(r.Replace(string(z.Raw())))
+				continue
+			}
+		case html.StartTagToken:
+			if name, _ := z.TagName(); raw[string(name)] {
+				depth++
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); raw[string(name)] && depth > 0 {
+				depth--
+			}
+		}
+		dst.Write(z.Raw())
+	}
+}
@@ -0,0 +1,22 @@
+package html
+
+import "testing"
+
+var wordReplacerTests = []struct {
+	in, out string
+}{
+	{`TM HTML TMs (TM)`, `<sup>TM</sup> HTML TMs (<sup>TM</sup>)`},
+	{`a:smile:b :smile:`, `a😄b 😄`},
+	{`C++ and C`, `C<sup>++</sup> and C`},
+	{`c++`, `c++`},
+	{`TM_ MTM`, `TM_ MTM`},
+}
+
+func TestWordReplacer(t *testing.T) {
+	r := NewWordReplacer("TM", "<sup>TM</sup>", ":smile:", "😄", "C++", "C<sup>++</sup>", "TM", "x")
+	for _, tt := range wordReplacerTests {
+		if out := r.Replace(tt.in); out != tt.out {
+			t.Errorf("Replace(%q) = %q, want %q", tt.in, out, tt.out)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package html
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Attribute is an HTML attribute.
+type Attribute struct {
+	Key string
+	Val string
+}
+
+// Tag is an HTML start tag passed to the function argument of Rewrite.
+type Tag struct {
+	Name string
+	Attr []Attribute
+
+	// After is HTML inserted after the tag.
+	After string
+
+	changed bool
+}
+
+// Get returns the value of the attribute with the given key.
+func (t *Tag) Get(key string) (string, bool) {
+	for _, a := range t.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// Set sets the value of the attribute with the given key. The attribute is
+// appended to the tag if not already present.
+func (t *Tag) Set(key string, val string) {
+	t.changed = true
+	for i := range t.Attr {
+		if t.Attr[i].Key == key {
+			t.Attr[i].Val = val
+			return
+		}
+	}
+	t.Attr = append(t.Attr, Attribute{Key: key, Val: val})
+}
+
+// Rewrite calls fn for each start tag in src. Tags modified by fn are
+// replaced in the result. All other content is copied verbatim.
+func Rewrite(src []byte, fn func(t *Tag) error) ([]byte, error) {
+	dst := make([]byte, 0, len(src))
+	z := html.NewTokenizer(bytes.NewReader(src))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			err := z.Err()
+			if err == io.EOF {
+				err = nil
+			}
+			return dst, err
+		case html.StartTagToken, html.SelfClosingTagToken:
+			raw := append([]byte(nil), z.Raw()...)
+			name, hasAttr := z.TagName()
+			t := Tag{Name: string(name)}
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				t.Attr = append(t.Attr, Attribute{Key: string(k), Val: string(v)})
+			}
+			// The tokenizer reports <a href=x/> as self-closing and
+			// includes the / in the attribute value.
+			selfClosing := tt == html.SelfClosingTagToken
+			if selfClosing && len(t.Attr) > 0 && strings.HasSuffix(t.Attr[len(t.Attr)-1].Val, "/") &&
+				!strings.ContainsRune("\"' \t\n\r\f", rune(raw[len(raw)-3])) {
+				selfClosing = false
+			}
+			if err := fn(&t); err != nil {
+				return nil, err
+			}
+			if !t.changed {
+				dst = append(dst, raw...)
+				dst = append(dst, t.After...)
+				continue
+			}
+			dst = append(dst, '<')
+			dst = append(dst, t.Name...)
+			for _, a := range t.Attr {
+				dst = append(dst, ' ')
+				dst = append(dst, a.Key...)
+				dst = append(dst, '=')
+				// A / at the end of an unquoted value is read as part of
+				// the value or as the end of a self-closing tag.
+				if needsQuote([]byte(a.Val)) || strings.HasSuffix(a.Val, "/") {
+					dst = append(dst, '"')
+					dst = append(dst, html.EscapeString(a.Val)...)
+					dst = append(dst, '"')
+				} else {
+					dst = append(dst, a.Val...)
+				}
+			}
+			if selfClosing {
+				dst = append(dst, '/')
+			}
+			dst = append(dst, '>')
+			dst = append(dst, t.After...)
+		default:
+			dst = append(dst, z.Raw()...)
+		}
+	}
+}
+
+// Inline returns the content of the inline script and style elements in src.
+func Inline(src []byte) (scripts [][]byte, styles [][]byte, err error) {
+	z := html.NewTokenizer(bytes.NewReader(src))
+	var text *[][]byte
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			err := z.Err()
+			if err == io.EOF {
+				err = nil
+			}
+			return scripts, styles, err
+		case html.StartTagToken:
+			text = nil
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "script":
+				text = &scripts
+				for hasAttr {
+					var k []byte
+					k, _, hasAttr = z.TagAttr()
+					if string(k) == "src" {
+						text = nil
+					}
+				}
+			case "style":
+				text = &styles
+			}
+			if text != nil {
+				// Record empty elements.
+				*text = append(*text, nil)
+			}
+		case html.TextToken:
+			if text != nil {
+				i := len(*text) - 1
+				(*text)[i] = append((*text)[i], z.Raw()...)
+			}
+		default:
+			text = nil
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package html
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	src := `<p class=a>x</p><script src=a.js></script><script>if (a<b) {}</script><br/>`
+	want := `<p class=a>x</p><script src=a.js data-x="a b"></script><script>if (a<b) {}</script><br/>`
+	got, err := Rewrite([]byte(src), func(t *Tag) error {
+		if t.Name == "script" {
+			if _, ok := t.Get("src"); ok {
+				t.Set("data-x", "a b")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("Rewrite(%q) = %q, want %q", src, got, want)
+	}
+}
+
+var rewriteSlashTests = []struct {
+	src, want string
+}{
+	{`<a href=b/>b</a>`, `<a href="b/" data-x=y>b</a>`},
+	{`<a href=/>b</a>`, `<a href="/" data-x=y>b</a>`},
+	{`<img src="i/"/>`, `<img src="i/" data-x=y/>`},
+	{`<img src=i />`, `<img src=i data-x=y/>`},
+	{`<br/>`, `<br data-x=y/>`},
+}
+
+func TestRewriteSlash(t *testing.T) {
+	for _, tt := range rewriteSlashTests {
+		got, err := Rewrite([]byte(tt.src), func(t *Tag) error {
+			t.Set("data-x", "y")
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Rewrite(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestInline(t *testing.T) {
+	src := `<style>p{}</style><script src=a.js></script><script>a()</script><script></script><p>x</p>`
+	scripts, styles, err := Inline([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]byte{[]byte("a()"), nil}; !reflect.DeepEqual(scripts, want) {
+		t.Errorf("scripts = %q, want %q", scripts, want)
+	}
+	if want := [][]byte{[]byte("p{}")}; !reflect.DeepEqual(styles, want) {
+		t.Errorf("styles = %q, want %q", styles, want)
+	}
+}
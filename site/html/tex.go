@@ -0,0 +1,328 @@
+package html
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// texSymbols maps TeX commands to MathML elements.
+var texSymbols = map[string]struct{ tag, text string }{
+	"alpha": {"mi", "α"}, "beta": {"mi", "β"}, "gamma": {"mi", "γ"}, "delta": {"mi", "δ"},
+	"epsilon": {"mi", "ϵ"}, "varepsilon": {"mi", "ε"}, "zeta": {"mi", "ζ"}, "eta": {"mi", "η"},
+	"theta": {"mi", "θ"}, "vartheta": {"mi", "ϑ"}, "iota": {"mi", "ι"}, "kappa": {"mi", "κ"},
+	"lambda": {"mi", "λ"}, "mu": {"mi", "μ"}, "nu": {"mi", "ν"}, "xi": {"mi", "ξ"},
+	"pi": {"mi", "π"}, "rho": {"mi", "ρ"}, "sigma": {"mi", "σ"}, "tau": {"mi", "τ"},
+	"upsilon": {"mi", "υ"}, "phi": {"mi", "ϕ"}, "varphi": {"mi", "φ"}, "chi": {"mi", "χ"},
+	"psi": {"mi", "ψ"}, "omega": {"mi", "ω"},
+	"Gamma": {"mi", "Γ"}, "Delta": {"mi", "Δ"}, "Theta": {"mi", "Θ"}, "Lambda": {"mi", "Λ"},
+	"Xi": {"mi", "Ξ"}, "Pi": {"mi", "Π"}, "Sigma": {"mi", "Σ"}, "Upsilon": {"mi", "Υ"},
+	"Phi": {"mi", "Φ"}, "Psi": {"mi", "Ψ"}, "Omega": {"mi", "Ω"},
+	"infty": {"mi", "∞"}, "partial": {"mi", "∂"}, "nabla": {"mi", "∇"}, "ell": {"mi", "ℓ"},
+	"hbar": {"mi", "ℏ"}, "emptyset": {"mi", "∅"},
+	"sin": {"mi", "sin"}, "cos": {"mi", "cos"}, "tan": {"mi", "tan"}, "log": {"mi", "log"},
+	"ln": {"mi", "ln"}, "exp": {"mi", "exp"}, "max": {"mi", "max"}, "min": {"mi", "min"},
+	"det": {"mi", "det"}, "gcd": {"mi", "gcd"},
+	"sum": {"mo", "∑"}, "prod": {"mo", "∏"}, "int": {"mo", "∫"}, "oint": {"mo", "∮"},
+	"lim": {"mo", "lim"}, "pm": {"mo", "±"}, "mp": {"mo", "∓"}, "times": {"mo", "×"},
+	"div": {"mo", "÷"}, "cdot": {"mo", "⋅"}, "circ": {"mo", "∘"}, "ast": {"mo", "∗"},
+	"leq": {"mo", "≤"}, "le": {"mo", "≤"}, "geq": {"mo", "≥"}, "ge": {"mo", "≥"},
+	"neq": {"mo", "≠"}, "ne": {"mo", "≠"}, "approx": {"mo", "≈"}, "equiv": {"mo", "≡"},
+	"sim": {"mo", "∼"}, "propto": {"mo", "∝"}, "in": {"mo", "∈"}, "notin": {"mo", "∉"},
+	"subset": {"mo", "⊂"}, "subseteq": {"mo", "⊆"}, "supset": {"mo", "⊃"}, "cup": {"mo", "∪"},
+	"cap": {"mo", "∩"}, "forall": {"mo", "∀"}, "exists": {"mo", "∃"}, "neg": {"mo", "¬"},
+	"land": {"mo", "∧"}, "lor": {"mo", "∨"}, "to": {"mo", "→"}, "rightarrow": {"mo", "→"},
+	"leftarrow": {"mo", "←"}, "Rightarrow": {"mo", "⇒"}, "Leftarrow": {"mo", "⇐"},
+	"iff": {"mo", "⟺"}, "mapsto": {"mo", "↦"}, "ldots": {"mo", "…"}, "cdots": {"mo", "⋯"},
+	"langle": {"mo", "⟨"}, "rangle": {"mo", "⟩"}, "{": {"mo", "{"}, "}": {"mo", "}"},
+	"|": {"mo", "‖"}, "%": {"mo", "%"}, "$": {"mo", "$"}, "&": {"mo", "&"}, "#": {"mo", "#"},
+}
+
+// texSpaces maps TeX spacing commands to widths in em.
+var texSpaces = map[string]string{
+	",": "0.167em", ":": "0.222em", ";": "0.278em", " ": "0.333em",
+	"quad": "1em", "qquad": "2em",
+}
+
+// texVariants maps TeX font commands to MathML math variants.
+var texVariants = map[string]string{
+	"mathrm": "normal", "mathbf": "bold", "mathit": "italic",
+	"mathbb": "double-struck", "mathcal": "script", "mathsf": "sans-serif",
+	"mathtt": "monospace",
+}
+
+// texBigOps is the set of operators that take limits above and below in
+// display math.
+var texBigOps = map[string]bool{"∑": true, "∏": true, "lim": true}
+
+// TeXToMathML converts TeX math to a MathML math element. The supported
+// subset of TeX is letters, numbers, operators, groups, superscripts,
+// subscripts, Greek letters, common symbols, functions and arrows, \frac,
+// \sqrt, \text, \left, \right, spacing commands and the font commands
+// \mathrm, \mathbf, \mathit, \mathbb, \mathcal, \mathsf and \mathtt.
+func TeXToMathML(tex string, display bool) (string, error) {
+	p := &texParser{s: tex, display: display}
+	items, err := p.list("")
+	if err != nil {
+		return "", fmt.Errorf("math %q: %v", tex, err)
+	}
+	if display {
+		return "<math display=block>" + mrow(items) + "</math>", nil
+	}
+	return "<math>" + mrow(items) + "</math>", nil
+}
+
+type texParser struct {
+	s       string
+	pos     int
+	display bool
+}
+
+func mrow(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return "<mrow>" + strings.Join(items, "") + "</mrow>"
+}
+
+func (p *texParser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// list parses items until the end of input or the closing brace if end is
+// "}".
+func (p *texParser) list(end string) ([]string, error) {
+	var items []string
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			if end != "" {
+				return nil, fmt.Errorf("missing %s", end)
+			}
+			return items, nil
+		}
+		if end != "" && strings.HasPrefix(p.s[p.pos:], end) {
+			p.pos += len(end)
+			return items, nil
+		}
+		if p.s[p.pos] == '}' {
+			return nil, fmt.Errorf("unexpected }")
+		}
+		item, err := p.scripted()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+// scripted parses an atom followed by optional superscript and subscript.
+func (p *texParser) scripted() (string, error) {
+	base, op, err := p.atom()
+	if err != nil {
+		return "", err
+	}
+	var sub, sup string
+	hasSub, hasSup := false, false
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) || (p.s[p.pos] != '^' && p.s[p.pos] != '_') {
+			break
+		}
+		c := p.s[p.pos]
+		p.pos++
+		arg, err := p.arg()
+		if err != nil {
+			return "", err
+		}
+		if c == '^' {
+			if hasSup {
+				return "", fmt.Errorf("double superscript")
+			}
+			sup, hasSup = arg, true
+		} else {
+			if hasSub {
+				return "", fmt.Errorf("double subscript")
+			}
+			sub, hasSub = arg, true
+		}
+	}
+	under, over, underover := "msub", "msup", "msubsup"
+	if p.display && texBigOps[op] {
+		under, over, underover = "munder", "mover", "munderover"
+	}
+	switch {
+	case hasSub && hasSup:
+		return "<" + underover + ">" + base + sub + sup + "</" + underover + ">", nil
+	case hasSub:
+		return "<" + under + ">" + base + sub + "</" + under + ">", nil
+	case hasSup:
+		return "<" + over + ">" + base + sup + "</" + over + ">", nil
+	}
+	return base, nil
+}
+
+// arg parses a command or script argument.
+func (p *texParser) arg() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return "", fmt.Errorf("missing argument")
+	}
+	if p.s[p.pos] == '{' {
+		p.pos++
+		items, err := p.list("}")
+		if err != nil {
+			return "", err
+		}
+		return mrow(items), nil
+	}
+	if p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		// A single digit as in x^23.
+		p.pos++
+		return "<mn>" + p.s[p.pos-1:p.pos] + "</mn>", nil
+	}
+	s, _, err := p.atom()
+	return s, err
+}
+
+// rawArg returns the text of the braced argument at the current position.
+func (p *texParser) rawArg() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) || p.s[p.pos] != '{' {
+		return "", fmt.Errorf("expected {")
+	}
+	depth := 0
+	for i := p.pos; i < len(p.s); i++ {
+		switch p.s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				s := p.s[p.pos+1 : i]
+				p.pos = i + 1
+				return s, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("missing }")
+}
+
+// atom parses a single item. The text of an operator is also returned.
+func (p *texParser) atom() (string, string, error) {
+	c := p.s[p.pos]
+	switch {
+	case c == '{':
+		s, err := p.arg()
+		return s, "", err
+	case c == '^' || c == '_':
+		return "", "", fmt.Errorf("missing base for %c", c)
+	case c == '\\':
+		return p.command()
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] == '.') {
+			p.pos++
+		}
+		return "<mn>" + p.s[start:p.pos] + "</mn>", "", nil
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		p.pos++
+		return "<mi>" + string(c) + "</mi>", "", nil
+	case c == '-':
+		p.pos++
+		return "<mo>−</mo>", "−", nil
+	case c == '\'':
+		p.pos++
+		return "<mo>′</mo>", "′", nil
+	case c < 0x80:
+		p.pos++
+		return "<mo>" + html.EscapeString(string(c)) + "</mo>", string(c), nil
+	}
+	// Copy other characters as identifiers.
+	r := []rune(p.s[p.pos:])[0]
+	p.pos += len(string(r))
+	return "<mi>" + string(r) + "</mi>", "", nil
+}
+
+// command parses a command starting with \.
+func (p *texParser) command() (string, string, error) {
+	p.pos++
+	start := p.pos
+	for p.pos < len(p.s) && (p.s[p.pos] >= 'a' && p.s[p.pos] <= 'z' || p.s[p.pos] >= 'A' && p.s[p.pos] <= 'Z') {
+		p.pos++
+	}
+	if p.pos == start && p.pos < len(p.s) {
+		p.pos++
+	}
+	name := p.s[start:p.pos]
+	if sym, ok := texSymbols[name]; ok {
+		return "<" + sym.tag + ">" + html.EscapeString(sym.text) + "</" + sym.tag + ">", sym.text, nil
+	}
+	if w, ok := texSpaces[name]; ok {
+		return "<mspace width=" + w + "></mspace>", "", nil
+	}
+	if v, ok := texVariants[name]; ok {
+		s, err := p.rawArg()
+		if err != nil {
+			return "", "", fmt.Errorf("\\%s: %v", name, err)
+		}
+		return "<mi mathvariant=" + v + ">" + html.EscapeString(s) + "</mi>", "", nil
+	}
+	switch name {
+	case "frac":
+		num, err := p.arg()
+		if err != nil {
+			return "", "", fmt.Errorf("\\frac: %v", err)
+		}
+		den, err := p.arg()
+		if err != nil {
+			return "", "", fmt.Errorf("\\frac: %v", err)
+		}
+		return "<mfrac>" + num + den + "</mfrac>", "", nil
+	case "sqrt":
+		p.skipSpace()
+		var index string
+		if p.pos < len(p.s) && p.s[p.pos] == '[' {
+			p.pos++
+			items, err := p.list("]")
+			if err != nil {
+				return "", "", fmt.Errorf("\\sqrt: %v", err)
+			}
+			index = mrow(items)
+		}
+		arg, err := p.arg()
+		if err != nil {
+			return "", "", fmt.Errorf("\\sqrt: %v", err)
+		}
+		if index != "" {
+			return "<mroot>" + arg + index + "</mroot>", "", nil
+		}
+		return "<msqrt>" + arg + "</msqrt>", "", nil
+	case "text":
+		s, err := p.rawArg()
+		if err != nil {
+			return "", "", fmt.Errorf("\\text: %v", err)
+		}
+		return "<mtext>" + html.EscapeString(s) + "</mtext>", "", nil
+	case "left", "right":
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return "", "", fmt.Errorf("\\%s: missing delimiter", name)
+		}
+		if p.s[p.pos] == '.' {
+			p.pos++
+			return "<mo></mo>", "", nil
+		}
+		s, op, err := p.atom()
+		if err != nil {
+			return "", "", err
+		}
+		if op == "" {
+			return "", "", fmt.Errorf("\\%s: invalid delimiter", name)
+		}
+		return s, op, nil
+	}
+	return "", "", fmt.Errorf("unsupported command \\%s", name)
+}
@@ -0,0 +1,177 @@
+package html
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// typographyRawTags is the set of additional elements where Typography does
+// not change text.
+var typographyRawTags = map[string]bool{
+	"kbd":  true,
+	"samp": true,
+	"var":  true,
+	"math": true,
+	"svg":  true,
+}
+
+// blockElements is the set of elements where Typography prevents widows at
+// the end of the element's text.
+var blockElements = map[string]bool{
+	"p":          true,
+	"li":         true,
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"blockquote": true,
+	"dd":         true,
+	"dt":         true,
+	"figcaption": true,
+	"td":         true,
+	"th":         true,
+}
+
+var dashReplacer = strings.NewReplacer("---", "—", "--", "–", "...", "…")
+
+// Typography applies typographic conventions to the text in src:
+//
+//	"quotes" and 'quotes' -> curly quotes
+//	it's -> apostrophe
+//	--- -> em dash, -- -> en dash
+//	... -> ellipsis
+//
+// The last two words in a block element such as p or li are joined with a
+// non-breaking space to prevent a single word on the last line. Text in the
+// raw elements for Minify, the elements in rawTags, and the kbd, samp, var,
+// math and svg elements is not changed.
+func Typography(src []byte, rawTags []string) ([]byte, error) {
+	raw := textRawTags(rawTags)
+
+	var dst bytes.Buffer
+	z := html.NewTokenizer(bytes.NewReader(src))
+	depth := 0        // depth in raw elements
+	prev := ' '       // previous rune in text, used to choose quote direction
+	lastText := -1    // offset in dst of the last text token
+	lastTextEnd := -1 // offset in dst after the last text token
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			err := z.Err()
+			if err == io.EOF {
+				err = nil
+			}
+			return dst.Bytes(), err
+		case html.TextToken:
+			if depth > 0 {
+				dst.Write(z.Raw())
+				continue
+			}
+			lastText = dst.Len()
+			dst.WriteString(smartText(string(z.Raw()), &prev))
+			lastTextEnd = dst.Len()
+			continue
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			if raw[string(name)] {
+				depth++
+			}
+			if blockElements[string(name)] {
+				prev = ' '
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if raw[string(name)] && depth > 0 {
+				depth--
+			}
+			if blockElements[string(name)] {
+				if lastTextEnd == dst.Len() {
+					preventWidow(&dst, lastText)
+				}
+				prev = ' '
+			}
+		}
+		dst.Write(z.Raw())
+	}
+}
+
+// rawTagsFor returns the set of raw elements for Minify with the additional
+// elements in extra.
+func rawTagsFor(extra []string) map[string]bool {
+	m := make(map[string]bool)
+	for k := range rawTags {
+		m[k] = true
+	}
+	for _, k := range extra {
+		m[k] = true
+	}
+	return m
+}
+
+// textRawTags returns the set of elements where text is not changed by
+// Typography and ReplaceText.
+func textRawTags(extra []string) map[string]bool {
+	m := rawTagsFor(extra)
+	for k := range typographyRawTags {
+		m[k] = true
+	}
+	return m
+}
+
+// smartText converts quotes and dashes in text. The argument prev is the
+// rune preceding text and is updated to the last rune of text.
+func smartText(text string, prev *rune) string {
+	text = dashReplacer.Replace(text)
+	var b strings.Builder
+	for i, r := range text {
+		switch r {
+		case '"':
+			if opensQuote(*prev) {
+				r = '“'
+			} else {
+				r = '”'
+			}
+		case '\'':
+			next, _ := utf8.DecodeRuneInString(text[i+1:])
+			switch {
+			case unicode.IsLetter(*prev) && unicode.IsLetter(next):
+				r = '’' // apostrophe
+			case opensQuote(*prev):
+				r = '‘'
+			default:
+				r = '’'
+			}
+		}
+		b.WriteRune(r)
+		*prev = r
+	}
+	return b.String()
+}
+
+// opensQuote returns whether a quote following rune prev opens a quotation.
+func opensQuote(prev rune) bool {
+	return unicode.IsSpace(prev) || strings.ContainsRune("([{—–“‘", prev)
+}
+
+// preventWidow replaces the last space in the text starting at offset start
+// in buf with a non-breaking space. The text must be at the end of buf.
+func preventWidow(buf *bytes.Buffer, start int) {
+	text := buf.Bytes()[start:]
+	trimmed := bytes.TrimRightFunc(text, unicode.IsSpace)
+	i := bytes.LastIndexByte(trimmed, ' ')
+	if i <= 0 || bytes.IndexByte(trimmed[:i], ' ') < 0 {
+		// Do not join the words of a two word block.
+		return
+	}
+	rest := append([]byte("&nbsp;"), text[i+1:]...)
+	buf.Truncate(start + i)
+	buf.Write(rest)
+}
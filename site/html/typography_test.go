@@ -0,0 +1,29 @@
+package html
+
+import "testing"
+
+var typographyTests = []struct {
+	in, out string
+}{
+	{`<p>"Hello," she said.`, `<p>“Hello,” she said.`},
+	{`<p>It's 'quoted' text</p>`, `<p>It’s ‘quoted’&nbsp;text</p>`},
+	{`<p>Wait... a---b 1--2 now</p>`, `<p>Wait… a—b 1–2&nbsp;now</p>`},
+	{`<p>Two words</p>`, `<p>Two words</p>`},
+	{`<p>A <em>"b"</em> c d</p>`, `<p>A <em>“b”</em> c&nbsp;d</p>`},
+	{`<p>Use <code>"x" -- y</code> here now</p>`, `<p>Use <code>"x" -- y</code> here&nbsp;now</p>`},
+	{`<pre>"x"...</pre>`, `<pre>"x"...</pre>`},
+	{`<a title="x--y">"z"</a>`, `<a title="x--y">“z”</a>`},
+}
+
+func TestTypography(t *testing.T) {
+	for _, tt := range typographyTests {
+		out, err := Typography([]byte(tt.in), nil)
+		if err != nil {
+			t.Errorf("Typography(%q) returned error %v", tt.in, err)
+			continue
+		}
+		if string(out) != tt.out {
+			t.Errorf("Typography(%q) = %q, want %q", tt.in, out, tt.out)
+		}
+	}
+}
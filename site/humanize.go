@@ -0,0 +1,84 @@
+package site
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// HumanizeBytes formats a size in bytes using SI units, for example 82 MB.
+func (utilFuncs) HumanizeBytes(size interface{}) (string, error) {
+	n, err := toInt64(size)
+	if err != nil {
+		return "", fmt.Errorf("humanizeBytes: %w", err)
+	}
+	if n < 1000 && n > -1000 {
+		return fmt.Sprintf("%d B", n), nil
+	}
+	f := float64(n)
+	units := "kMGTPE"
+	i := 0
+	for f >= 999.95 || f <= -999.95 {
+		f /= 1000
+		i++
+	}
+	return fmt.Sprintf("%.1f %cB", f, units[i-1]), nil
+}
+
+// Comma formats an integer with commas separating groups of thousands.
+func (utilFuncs) Comma(v interface{}) (string, error) {
+	n, err := toInt64(v)
+	if err != nil {
+		return "", fmt.Errorf("comma: %w", err)
+	}
+	s := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	var buf []byte
+	for i := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, s[i])
+	}
+	return sign + string(buf), nil
+}
+
+// Ordinal formats an integer as an English ordinal number, for example 22nd.
+func (utilFuncs) Ordinal(v interface{}) (string, error) {
+	n, err := toInt64(v)
+	if err != nil {
+		return "", fmt.Errorf("ordinal: %w", err)
+	}
+	m := n % 100
+	if m < 0 {
+		m = -m
+	}
+	suffix := "th"
+	if m < 11 || m > 13 {
+		switch m % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.FormatInt(n, 10) + suffix, nil
+}
+
+func toInt64(v interface{}) (int64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return int64(rv.Float()), nil
+	}
+	return 0, fmt.Errorf("expected number, got %T", v)
+}
@@ -0,0 +1,36 @@
+package site
+
+import "testing"
+
+var humanizeTests = []struct {
+	fn   func(interface{}) (string, error)
+	v    interface{}
+	want string
+}{
+	{utilFuncs{}.HumanizeBytes, 999, "999 B"},
+	{utilFuncs{}.HumanizeBytes, int64(1500), "1.5 kB"},
+	{utilFuncs{}.HumanizeBytes, 82854982, "82.9 MB"},
+	{utilFuncs{}.HumanizeBytes, 999999, "1.0 MB"},
+	{utilFuncs{}.Comma, 0, "0"},
+	{utilFuncs{}.Comma, 123, "123"},
+	{utilFuncs{}.Comma, 1234, "1,234"},
+	{utilFuncs{}.Comma, -1234567, "-1,234,567"},
+	{utilFuncs{}.Ordinal, 1, "1st"},
+	{utilFuncs{}.Ordinal, 12, "12th"},
+	{utilFuncs{}.Ordinal, 22, "22nd"},
+	{utilFuncs{}.Ordinal, 113, "113th"},
+	{utilFuncs{}.Ordinal, 103, "103rd"},
+}
+
+func TestHumanize(t *testing.T) {
+	for _, tt := range humanizeTests {
+		got, err := tt.fn(tt.v)
+		if err != nil {
+			t.Errorf("%v: returned error %v", tt.v, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package site
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+func init() {
+	mime.AddExtensionType(".ics", "text/calendar; charset=utf-8")
+}
+
+// icalFuncs are template functions for writing iCalendar files. Use a text
+// layout with the extension .ics to generate an iCalendar resource. Line
+// endings in the generated resource are converted to CRLF and long lines are
+// folded as required by RFC 5545.
+type icalFuncs struct{}
+
+// Time formats t as an iCalendar date-time in UTC.
+func (icalFuncs) Time(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// Date formats t as an iCalendar date.
+func (icalFuncs) Date(t time.Time) string {
+	return t.Format("20060102")
+}
+
+// ParseTime parses an RFC 3339 time. Use ParseTime to convert page
+// parameters to times.
+func (icalFuncs) ParseTime(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339, s)
+}
+
+var icalTextEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+// Text escapes s for use in an iCalendar text value.
+func (icalFuncs) Text(s string) string {
+	return icalTextEscaper.Replace(s)
+}
+
+// isCalendar returns whether the MIME type ct is text/calendar.
+func isCalendar(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	return err == nil && mt == "text/calendar"
+}
+
+// foldICal converts the line endings in data to CRLF and folds lines longer
+// than 75 octets. Blank lines are removed.
+func foldICal(data []byte) []byte {
+	var buf bytes.Buffer
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := 75
+		for len(line) > n {
+			// Do not split UTF-8 sequences.
+			i := n
+			for i > 0 && !utf8.RuneStart(line[i]) {
+				i--
+			}
+			buf.WriteString(line[:i])
+			buf.WriteString("\r\n ")
+			line = line[i:]
+			// Continuation lines start with a space.
+			n = 74
+		}
+		buf.WriteString(line)
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes()
+}
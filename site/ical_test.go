@@ -0,0 +1,33 @@
+package site
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFoldICal(t *testing.T) {
+	long := "DESCRIPTION:" + strings.Repeat("x", 100)
+	got := string(foldICal([]byte("BEGIN:VCALENDAR\n\n" + long + "\nEND:VCALENDAR\n")))
+	want := "BEGIN:VCALENDAR\r\n" +
+		long[:75] + "\r\n " + long[75:] + "\r\n" +
+		"END:VCALENDAR\r\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Multi-byte characters are not split.
+	long = strings.Repeat("é", 40)
+	got = string(foldICal([]byte(long)))
+	want = long[:74] + "\r\n " + long[74:] + "\r\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestICalText(t *testing.T) {
+	got := icalFuncs{}.Text("a, b; c\\d\ne")
+	const want = `a\, b\; c\\d\ne`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
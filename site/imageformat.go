@@ -0,0 +1,225 @@
+package site
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"mime"
+)
+
+// WebP and AVIF images are registered with configuration readers only. The
+// dimensions of these images can be read by ReadImage and ReadImageSrcSet,
+// but the images cannot be decoded for resizing.
+func init() {
+	image.RegisterFormat("webp", "RIFF????WEBP", decodeUnsupported("webp"), decodeWebPConfig)
+	image.RegisterFormat("avif", "????ftypavif", decodeUnsupported("avif"), decodeAVIFConfig)
+	image.RegisterFormat("avif", "????ftypavis", decodeUnsupported("avif"), decodeAVIFConfig)
+	mime.AddExtensionType(".webp", "image/webp")
+	mime.AddExtensionType(".avif", "image/avif")
+}
+
+func decodeUnsupported(format string) func(io.Reader) (image.Image, error) {
+	return func(io.Reader) (image.Image, error) {
+		return nil, errors.New(format + ": decoding not supported")
+	}
+}
+
+var errWebPHeader = errors.New("webp: invalid header")
+
+func decodeWebPConfig(r io.Reader) (image.Config, error) {
+	var h [30]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return image.Config{}, errWebPHeader
+	}
+	config := image.Config{ColorModel: color.NRGBAModel}
+	switch string(h[12:16]) {
+	case "VP8X":
+		// Extended format: 24 bit canvas width and height minus one.
+		config.Width = 1 + (int(h[24]) | int(h[25])<<8 | int(h[26])<<16)
+		config.Height = 1 + (int(h[27]) | int(h[28])<<8 | int(h[29])<<16)
+	case "VP8 ":
+		// Lossy format: key frame header with 14 bit width and height.
+		if h[23] != 0x9d || h[24] != 0x01 || h[25] != 0x2a {
+			return image.Config{}, errWebPHeader
+		}
+		config.Width = int(binary.LittleEndian.Uint16(h[26:28]) & 0x3fff)
+		config.Height = int(binary.LittleEndian.Uint16(h[28:30]) & 0x3fff)
+	case "VP8L":
+		// Lossless format: 14 bit width and height minus one.
+		if h[20] != 0x2f {
+			return image.Config{}, errWebPHeader
+		}
+		bits := binary.LittleEndian.Uint32(h[21:25])
+		config.Width = 1 + int(bits&0x3fff)
+		config.Height = 1 + int(bits>>14&0x3fff)
+	default:
+		return image.Config{}, errWebPHeader
+	}
+	return config, nil
+}
+
+var errAVIFHeader = errors.New("avif: invalid header")
+
+// bmffBoxes calls fn with the type and contents of each ISO base media file
+// format box in p.
+func bmffBoxes(p []byte, fn func(typ string, data []byte)) error {
+	for len(p) > 0 {
+		if len(p) < 8 {
+			return errAVIFHeader
+		}
+		size := uint64(binary.BigEndian.Uint32(p))
+		typ := string(p[4:8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(p))
+		case 1:
+			if len(p) < 16 {
+				return errAVIFHeader
+			}
+			size = binary.BigEndian.Uint64(p[8:])
+			header = 16
+		}
+		if size < header || size > uint64(len(p)) {
+			return errAVIFHeader
+		}
+		fn(typ, p[header:size])
+		p = p[size:]
+	}
+	return nil
+}
+
+// decodeAVIFConfig reads the dimensions of the primary image from the ispe
+// property in the meta box.
+func decodeAVIFConfig(r io.Reader) (image.Config, error) {
+	p, err := ioutil.ReadAll(io.LimitReader(r, 1<<20))
+	if err != nil {
+		return image.Config{}, err
+	}
+	var meta []byte
+	if err := bmffBoxes(p, func(typ string, data []byte) {
+		if typ == "meta" && meta == nil && len(data) >= 4 {
+			meta = data[4:] // skip version and flags
+		}
+	}); err != nil && meta == nil {
+		return image.Config{}, err
+	}
+	if meta == nil {
+		return image.Config{}, errAVIFHeader
+	}
+
+	var primary uint32
+	var properties [][]byte // ipco children, indexed from one in ipma.
+	var propTypes []string
+	associations := make(map[uint32][]int)
+	err = bmffBoxes(meta, func(typ string, data []byte) {
+		switch typ {
+		case "pitm":
+			if len(data) >= 6 && data[0] == 0 {
+				primary = uint32(binary.BigEndian.Uint16(data[4:]))
+			} else if len(data) >= 8 {
+				primary = binary.BigEndian.Uint32(data[4:])
+			}
+		case "iprp":
+			bmffBoxes(data, func(typ string, data []byte) {
+				switch typ {
+				case "ipco":
+					bmffBoxes(data, func(typ string, data []byte) {
+						propTypes = append(propTypes, typ)
+						properties = append(properties, data)
+					})
+				case "ipma":
+					parseIPMA(data, associations)
+				}
+			})
+		}
+	})
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	width, height, rotate := 0, 0, false
+	for _, i := range associations[primary] {
+		if i < 1 || i > len(properties) {
+			continue
+		}
+		data := properties[i-1]
+		switch propTypes[i-1] {
+		case "ispe":
+			if len(data) >= 12 {
+				width = int(binary.BigEndian.Uint32(data[4:]))
+				height = int(binary.BigEndian.Uint32(data[8:]))
+			}
+		case "irot":
+			rotate = len(data) >= 1 && data[0]&1 != 0
+		}
+	}
+	if width == 0 {
+		// Use the largest image if the primary item is not found.
+		for i, typ := range propTypes {
+			if data := properties[i]; typ == "ispe" && len(data) >= 12 {
+				if w := int(binary.BigEndian.Uint32(data[4:])); w > width {
+					width, height = w, int(binary.BigEndian.Uint32(data[8:]))
+				}
+			}
+		}
+	}
+	if width == 0 || height == 0 {
+		return image.Config{}, errAVIFHeader
+	}
+	if rotate {
+		width, height = height, width
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: width, Height: height}, nil
+}
+
+// parseIPMA adds the property indices for each item in the item property
+// association box data to associations.
+func parseIPMA(data []byte, associations map[uint32][]int) {
+	if len(data) < 8 {
+		return
+	}
+	version, flags := data[0], data[3]
+	r := bytes.NewReader(data[4:])
+	var count uint32
+	if binary.Read(r, binary.BigEndian, &count) != nil {
+		return
+	}
+	for ; count > 0; count-- {
+		var id uint32
+		if version < 1 {
+			var id16 uint16
+			if binary.Read(r, binary.BigEndian, &id16) != nil {
+				return
+			}
+			id = uint32(id16)
+		} else if binary.Read(r, binary.BigEndian, &id) != nil {
+			return
+		}
+		n, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		for ; n > 0; n-- {
+			var index int
+			if flags&1 != 0 {
+				var v uint16
+				if binary.Read(r, binary.BigEndian, &v) != nil {
+					return
+				}
+				index = int(v & 0x7fff)
+			} else {
+				v, err := r.ReadByte()
+				if err != nil {
+					return
+				}
+				index = int(v & 0x7f)
+			}
+			associations[id] = append(associations[id], index)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package site
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"testing"
+)
+
+func webpHeader(chunk string, data ...byte) []byte {
+	p := append([]byte("RIFF\x00\x00\x00\x00WEBP"+chunk+"\x00\x00\x00\x00"), data...)
+	return append(p, make([]byte, 32)...)
+}
+
+func box(typ string, data ...[]byte) []byte {
+	p := bytes.Join(data, nil)
+	b := make([]byte, 8, 8+len(p))
+	binary.BigEndian.PutUint32(b, uint32(8+len(p)))
+	copy(b[4:], typ)
+	return append(b, p...)
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func TestImageFormats(t *testing.T) {
+	ispe := func(w, h uint32) []byte { return box("ispe", u32(0), u32(w), u32(h)) }
+	avif := bytes.Join([][]byte{
+		box("ftyp", []byte("avif"), u32(0), []byte("avifmif1")),
+		box("meta", u32(0),
+			box("hdlr", u32(0), u32(0), []byte("pict"), make([]byte, 13)),
+			box("pitm", u32(0), []byte{0, 2}),
+			box("iprp",
+				box("ipco", ispe(64, 64), ispe(640, 480), box("irot", []byte{1})),
+				box("ipma", u32(0), u32(2), []byte{0, 1, 1, 0x81}, []byte{0, 2, 2, 0x82, 0x83}))),
+	}, nil)
+
+	for _, tt := range []struct {
+		name   string
+		data   []byte
+		format string
+		w, h   int
+	}{
+		{"vp8x", webpHeader("VP8X", 0, 0, 0, 0, 99, 0, 0, 0x2b, 0x01, 0), "webp", 100, 300},
+		{"vp8", webpHeader("VP8 ", 0, 0, 0, 0x9d, 0x01, 0x2a, 0x40, 0x01, 0xf0, 0x00), "webp", 320, 240},
+		{"vp8l", webpHeader("VP8L", 0x2f, 0x63, 0xc0, 0x0c, 0x00), "webp", 100, 52},
+		{"avif", avif, "avif", 480, 640},
+	} {
+		config, format, err := image.DecodeConfig(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if format != tt.format || config.Width != tt.w || config.Height != tt.h {
+			t.Errorf("%s: got %s %dx%d, want %s %dx%d", tt.name, format, config.Width, config.Height, tt.format, tt.w, tt.h)
+		}
+	}
+}
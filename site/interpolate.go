@@ -0,0 +1,118 @@
+package site
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/garyburd/staticsite/common/action"
+)
+
+// interpolatePat matches references in action argument values.
+var interpolatePat = regexp.MustCompile(`\$?\$\{([A-Za-z][A-Za-z0-9_:-]*)\}`)
+
+// interpolateArgs returns action a with the references in the argument
+// values replaced. The references are:
+//
+//	${title}        page field: title, subtitle, author, description, path,
+//	                slug, tags, created or updated
+//	${param:name}   page parameter
+//	${scratch:key}  page scratch value set by an earlier template action
+//	${site:name}    site parameter from the site configuration
+//
+// References with other names are not replaced so that text such as a
+// JavaScript template literal passes through unchanged. The text $${name} is
+// replaced with ${name}.
+func (s *site) interpolateArgs(p *Page, a *action.Action) (*action.Action, error) {
+	q := *a
+	q.Args = make(map[string]action.Value, len(a.Args))
+	for k, v := range a.Args {
+		v, err := s.interpolateValue(p, v)
+		if err != nil {
+			return nil, err
+		}
+		q.Args[k] = v
+	}
+	q.Values = make([]action.Value, len(a.Values))
+	for i, v := range a.Values {
+		v, err := s.interpolateValue(p, v)
+		if err != nil {
+			return nil, err
+		}
+		q.Values[i] = v
+	}
+	return &q, nil
+}
+
+// interpolateValue returns v with the references in the text replaced.
+func (s *site) interpolateValue(p *Page, v action.Value) (action.Value, error) {
+	if !strings.Contains(v.Text, "${") {
+		return v, nil
+	}
+	var err error
+	v.Text = interpolatePat.ReplaceAllStringFunc(v.Text, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		text, e := s.interpolateRef(p, ref[2:len(ref)-1])
+		if e != nil && err == nil {
+			err = fmt.Errorf("%s: %w", v.Location(p.lc), e)
+		}
+		return text
+	})
+	return v, err
+}
+
+// interpolateRef returns the text for reference name. The reference is
+// returned unchanged if name is not known.
+func (s *site) interpolateRef(p *Page, name string) (string, error) {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		key := name[i+1:]
+		switch name[:i] {
+		case "param":
+			if v, ok := p.Params[key]; ok {
+				return v, nil
+			}
+			return "", fmt.Errorf("page parameter %q not set", key)
+		case "scratch":
+			if p.Scratch.Has(key) {
+				return fmt.Sprint(p.Scratch.Get(key)), nil
+			}
+			return "", fmt.Errorf("scratch key %q not set", key)
+		case "site":
+			if v, ok := s.config.Params[key]; ok {
+				return fmt.Sprint(v), nil
+			}
+			return "", fmt.Errorf("site parameter %q not set", key)
+		}
+		return "${" + name + "}", nil
+	}
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	switch name {
+	case "title":
+		return p.Title, nil
+	case "subtitle":
+		return p.Subtitle, nil
+	case "author":
+		return p.Author, nil
+	case "description":
+		return p.Description, nil
+	case "path":
+		return p.Path, nil
+	case "slug":
+		return p.Slug, nil
+	case "tags":
+		return strings.Join(p.Tags, ","), nil
+	case "created":
+		return formatTime(p.Created), nil
+	case "updated":
+		return formatTime(p.Updated), nil
+	}
+	return "${" + name + "}", nil
+}
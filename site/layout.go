@@ -0,0 +1,143 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	htemplate "html/template"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	ttemplate "text/template"
+
+	"github.com/garyburd/staticsite/common/action"
+)
+
+// layout is a page layout. Layouts with the extension .html, .htm, .xhtml or
+// .svg are HTML templates. All other layouts are text templates. The output
+// of text templates is not escaped for HTML. Only the output of layouts with
+// the extension .html or .htm is minified.
+type layout struct {
+	name string
+	html *htemplate.Template
+	text *ttemplate.Template
+}
+
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// htmlLayoutExts is the set of extensions for layouts that are HTML
+// templates.
+var htmlLayoutExts = map[string]bool{
+	".html":  true,
+	".htm":   true,
+	".xhtml": true,
+	".svg":   true,
+}
+
+func isTextLayout(name string) bool {
+	return !htmlLayoutExts[path.Ext(name)]
+}
+
+func (s *site) loadLayout(name string) (*layout, error) {
+	l := &layout{name: name}
+	var err error
+	if isTextLayout(name) {
+		l.text, err = s.loader.LoadText(name)
+	} else {
+		l.html, err = s.loader.Load(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// lookup returns the template with the given name in the layout or nil if
+// the template is not found.
+func (l *layout) lookup(name string) executor {
+	if l.text != nil {
+		if t := l.text.Lookup(name); t != nil {
+			return t
+		}
+		return nil
+	}
+	if t := l.html.Lookup(name); t != nil {
+		return t
+	}
+	return nil
+}
+
+func (l *layout) Execute(w io.Writer, data interface{}) error {
+	if l.text != nil {
+		return l.text.Execute(w, data)
+	}
+	return l.html.Execute(w, data)
+}
+
+// layoutContentType returns the MIME type of the output of the layout with
+// the given name or "" if the output is HTML.
+func layoutContentType(name string) string {
+	ext := path.Ext(name)
+	if ext == ".html" || ext == ".htm" {
+		return ""
+	}
+	if ct := mime.TypeByExtension(ext); ct != "" {
+		return ct
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// params returns the parameter names declared for the template with the
+// given name. The names are declared as space separated text in the template
+// <name>.params:
+//
+//	{{define "img.params"}}src alt{{end}}
+//	{{define "img"}}<img src="{{.String "src"}}" alt="{{.String "alt"}}">{{end}}
+//
+// The declaration allows the action <% t:img "photo.jpg" "A photo" %> to
+// specify the arguments by position.
+func (l *layout) params(name string) ([]string, error) {
+	t := l.lookup(name + ".params")
+	if t == nil {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, nil); err != nil {
+		return nil, err
+	}
+	return strings.Fields(buf.String()), nil
+}
+
+// positionalArgs returns template action a with the positional arguments
+// set as named arguments using the parameter names declared for template
+// name. The action is returned unchanged if it does not have positional
+// arguments.
+func (s *site) positionalArgs(p *Page, l *layout, name string, a *action.Action) (*action.Action, error) {
+	if len(a.Values) == 0 {
+		return a, nil
+	}
+	params, err := l.params(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", a.Location(p.lc), s.loader.ExecError(p.layout, err))
+	}
+	if len(a.Values) > len(params) {
+		return nil, fmt.Errorf("%s: template %q declares %d parameters, found %d positional arguments",
+			a.Values[len(params)].Location(p.lc), name, len(params), len(a.Values))
+	}
+	args := make(map[string]action.Value, len(a.Args)+len(a.Values))
+	for k, v := range a.Args {
+		args[k] = v
+	}
+	for i, v := range a.Values {
+		if _, ok := args[params[i]]; ok {
+			return nil, fmt.Errorf("%s: argument %q specified by position and name", v.Location(p.lc), params[i])
+		}
+		args[params[i]] = v
+	}
+	q := *a
+	q.Args = args
+	q.Values = nil
+	return &q, nil
+}
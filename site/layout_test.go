@@ -0,0 +1,53 @@
+package site
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestActionArgs(t *testing.T) {
+	got := make(map[string]string)
+	err := Visit("testdata/actions", nil, func(r *Resource) error {
+		got[r.Path] = string(r.Data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `<img src=a.png alt=A><img src=b.png alt=""><img src=c.png alt=C>` + "\n"
+	if got["/"] != want {
+		t.Errorf("got %q, want %q", got["/"], want)
+	}
+	const wantB = `<img src=https://cdn.example.com/p.png alt="T 2 ${title} ${x}">` + "\n"
+	if got["/b/"] != wantB {
+		t.Errorf("got %q, want %q", got["/b/"], wantB)
+	}
+}
+
+func TestActionArgsErrors(t *testing.T) {
+	var buf bytes.Buffer
+	err := Visit("testdata/actionargs", &buf, func(*Resource) error { return nil })
+	if err == nil {
+		t.Error("Visit did not return error")
+	}
+	lines := strings.Split(buf.String(), "\n")
+	for name, want := range map[string]string{
+		"extra.html":     `declares 2 parameters, found 3 positional arguments`,
+		"duplicate.html": `argument "src" specified by position and name`,
+		"noparams.html":  `template "figure" declares 0 parameters`,
+		"set.html":       `positional arguments not supported by set`,
+		"param.html":     `page parameter "x" not set`,
+	} {
+		found := false
+		for _, line := range lines {
+			if strings.Contains(line, filepath.Join("page", name)) && strings.Contains(line, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: error containing %q not found in %q", name, want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/garyburd/staticsite/common/action"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Runes in the range firstPlaceholder through lastPlaceholder replace the
+// actions in a Markdown page while the page is converted to HTML. The range
+// is the supplementary private use area A.
+const (
+	firstPlaceholder rune = 0xF0000
+	lastPlaceholder  rune = 0xFFFFD
+)
+
+func isPlaceholder(r rune) bool {
+	return firstPlaceholder <= r && r <= lastPlaceholder
+}
+
+// isPlaceholderLine returns whether line contains placeholders and nothing
+// else but whitespace.
+func isPlaceholderLine(line []byte) bool {
+	found := false
+	for _, r := range string(line) {
+		switch {
+		case isPlaceholder(r):
+			found = true
+		case !unicode.IsSpace(r):
+			return false
+		}
+	}
+	return found
+}
+
+// placeholderBlockParser parses a line containing only placeholders as a
+// raw HTML block so that an action alone on a line is not wrapped in a
+// paragraph.
+type placeholderBlockParser struct{}
+
+func (placeholderBlockParser) Trigger() []byte {
+	// Lead byte of the UTF-8 encoding of the placeholder runes.
+	return []byte{0xF3}
+}
+
+func (placeholderBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	if pc.BlockOffset() < 0 || !isPlaceholderLine(line) {
+		return nil, parser.NoChildren
+	}
+	node := ast.NewHTMLBlock(ast.HTMLBlockType7)
+	node.Lines().Append(segment)
+	reader.Advance(segment.Len() - 1)
+	return node, parser.NoChildren
+}
+
+func (placeholderBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	return parser.Close
+}
+
+func (placeholderBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (placeholderBlockParser) CanInterruptParagraph() bool { return true }
+
+func (placeholderBlockParser) CanAcceptIndentedLine() bool { return false }
+
+var markdown = goldmark.New(
+	goldmark.WithParserOptions(
+		parser.WithBlockParsers(util.Prioritized(placeholderBlockParser{}, 850))),
+	goldmark.WithRendererOptions(gmhtml.WithUnsafe()))
+
+// markdownActions converts the text in the actions of a Markdown page to
+// HTML. Other actions are replaced with placeholders during the conversion
+// and are returned in the same order as the input.
+func markdownActions(actions []*action.Action, lc *action.LocationContext) ([]*action.Action, error) {
+	var src []byte
+	var others []*action.Action
+	for _, a := range actions {
+		if a.Name != action.TextAction {
+			r := firstPlaceholder + rune(len(others))
+			if r > lastPlaceholder {
+				return nil, fmt.Errorf("%s: too many actions in Markdown page", a.Location(lc))
+			}
+			var buf [utf8.UTFMax]byte
+			src = append(src, buf[:utf8.EncodeRune(buf[:], r)]...)
+			others = append(others, a)
+			continue
+		}
+		if i := bytes.IndexFunc(a.Text, isPlaceholder); i >= 0 {
+			return nil, fmt.Errorf("%s: private use character not allowed in Markdown page", lc.Position(a.Start+i))
+		}
+		src = append(src, a.Text...)
+	}
+
+	var out bytes.Buffer
+	if err := markdown.Convert(src, &out); err != nil {
+		return nil, err
+	}
+	html := out.Bytes()
+	var result []*action.Action
+	text := 0 // start of text in html
+	for i := 0; i < len(html); {
+		r, size := utf8.DecodeRune(html[i:])
+		if isPlaceholder(r) {
+			if text < i {
+				result = append(result, &action.Action{Name: action.TextAction, Text: html[text:i]})
+			}
+			result = append(result, others[r-firstPlaceholder])
+			text = i + size
+		}
+		i += size
+	}
+	if text < len(html) {
+		result = append(result, &action.Action{Name: action.TextAction, Text: html[text:]})
+	}
+	return result, nil
+}
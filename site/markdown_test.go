@@ -0,0 +1,48 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	got := make(map[string]string)
+	err := Visit("testdata/markdown", nil, func(r *Resource) error {
+		if r.Path != "/robots.txt" {
+			got[r.Path] = string(r.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"/":      "<h1>Home</h1>\n<p>Some <em>text</em> and <img src=a.png alt=A>.</p>\n<img src=b.png alt=B>\n<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n",
+		"/notes": "<p>A <a href=/post/>link</a>.</p>\n",
+		"/post/": "<h1>Post</h1>\n<h2>Heading</h2>\n<pre><code>if a &lt; b {\n</code></pre>\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMarkdownDisabled(t *testing.T) {
+	dir, cleanup := tempSite(t, "testdata/markdown")
+	defer cleanup()
+	if err := os.Remove(filepath.Join(dir, "config", "site.json")); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	err := Visit(dir, nil, func(r *Resource) error {
+		got = append(got, r.Path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/robots.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,78 @@
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// metaFileSuffix is the suffix of the sidecar file containing metadata for a
+// page. The sidecar file for page post.html is post.html.meta.json. The file
+// is a JSON object with set argument names as keys. Values are strings,
+// numbers, booleans or arrays of strings. Arrays are joined with commas.
+//
+//	{"title": "Post", "tags": ["go", "web"], "param:image": "post.jpg"}
+//
+// The sidecar file is applied after cascade actions and before set actions
+// in the page.
+const metaFileSuffix = ".meta.json"
+
+// setMetaFile sets the page metadata from the sidecar file fpath. The
+// boolean result is false if the file does not exist.
+func (p *Page) setMetaFile(fpath string) (bool, error) {
+	data, err := ioutil.ReadFile(fpath)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	var m map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil {
+		return false, fmt.Errorf("%s: %w", fpath, err)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		text, err := metaText(m[k])
+		if err != nil {
+			return false, fmt.Errorf("%s: %s: %w", fpath, k, err)
+		}
+		if err := p.setArg(k, text, fpath); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// metaText converts a sidecar file value to set argument text.
+func metaText(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return "", fmt.Errorf("array elements must be strings")
+			}
+			values[i] = s
+		}
+		return strings.Join(values, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
@@ -0,0 +1,58 @@
+package site
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOutputs(t *testing.T) {
+	for _, deferPages := range []bool{false, true} {
+		got := make(map[string]string)
+		var opts []Option
+		if deferPages {
+			opts = append(opts, WithDeferredPages())
+		}
+		err := Visit("testdata/outputs", nil, func(r *Resource) error {
+			if r.Path == "/robots.txt" {
+				return nil
+			}
+			data, err := r.data()
+			got[r.Path+" "+r.ContentType] = string(data)
+			return err
+		}, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Each output has its own scratch data.
+		want := map[string]string{
+			"/a/ ":                                   "<p>A 1\n",
+			"/a/index.txt text/plain; charset=utf-8": "A: x & y 1\n",
+			"/a/amp.html ":                           "<p>A 1\n",
+			"/a/badge.svg image/svg+xml":             "<svg><text>x &amp; y</text></svg>\n",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("defer=%v: got %q, want %q", deferPages, got, want)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	got := make(map[string]string)
+	err := Visit("testdata/print", nil, func(r *Resource) error {
+		got[r.Path] = string(r.Data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"/robots.txt": "",
+		"/a/":         `<nav>Menu</nav><p>See <a href=b/>B</a>, <a href=#x>here</a> and <a href=https://go.dev/>Go</a>.` + "\n" + `<img src="i.png?v=1" alt=I>` + "\n",
+		"/a/print/": `<body><p>See <a href="/a/b/">B</a><sup class=link-footnote>[1]</sup>, <a href=#x>here</a> and <a href=https://go.dev/>Go</a><sup class=link-footnote>[2]</sup>.` + "\n" +
+			`<img src="/a/i.png?v=1" alt=I>` + "\n" +
+			`<ol class=link-footnotes><li>https://example.com/a/b/<li>https://go.dev/</ol></body>` + "\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
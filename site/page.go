@@ -2,14 +2,19 @@ package site
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	htemplate "html/template"
+	"io/ioutil"
+	"mime"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/garyburd/staticsite/common"
 	"github.com/garyburd/staticsite/common/action"
 	"github.com/garyburd/staticsite/site/html"
 	"github.com/garyburd/staticsite/site/scratch"
@@ -23,19 +28,103 @@ type Page struct {
 	// Subtitle is the page's subtitle.
 	Subtitle string
 
+	// Author is the name of the page's author.
+	Author string
+
+	// Description is a short summary of the page.
+	Description string
+
 	// Created is the page creation time.
 	Created time.Time
 
 	// Updated is the time that the page was updated.
 	Updated time.Time
 
+	// Weight orders pages when sorting by weight. Pages with lower weights
+	// sort first.
+	Weight int
+
+	// Tags is the list of tags for the page. Specify tags in the set action
+	// as a comma separated list.
+	Tags []string
+
+	// Params is the page's parameters. Specify parameters in the set action
+	// using arguments with the prefix "param:".
+	Params map[string]string
+
+	// Slug is the path element for the page in permalink patterns. If not
+	// set, the slug is computed from the title.
+	Slug string
+
+	// Draft is true if the page is a draft. Drafts are included in the site
+	// when enabled in the site configuration.
+	Draft bool
+
+	// SitemapPriority and SitemapChangefreq are the priority and change
+	// frequency of the page in the sitemap.
+	SitemapPriority   string
+	SitemapChangefreq string
+
+	// SitemapExclude is true if the page is excluded from the sitemap. Set
+	// with sitemap="false".
+	SitemapExclude bool
+
+	// Print is true if a print variant of the page is generated with the
+	// print layout from the site configuration. Set with print="true".
+	Print bool
+
+	// NoReplacements is true if the text replacements from the replacements
+	// file are not applied to the page. Set with replacements="false".
+	NoReplacements bool
+
 	// Page path.
 	Path string
 
+	// Term is the taxonomy term for generated taxonomy pages.
+	Term string
+
+	// Pages is the list of pages with the term for generated taxonomy
+	// pages.
+	Pages []*Page
+
 	// Scratch data with page scope.
 	Scratch *scratch.Scratch
 
 	Content htemplate.HTML
+
+	// The resource for the page.
+	resource *Resource
+
+	// Path used for page queries.
+	queryPath string
+
+	// Path of the directory containing the page.
+	dir string
+
+	// True if the page is the index page for the directory.
+	isIndex bool
+
+	// Path of the page's layout and location of the layout argument.
+	layout    string
+	layoutLoc string
+
+	// MIME type of the page set with the contentType argument.
+	contentType string
+
+	// Additional outputs set with output:<path> arguments.
+	outputs []*pageOutput
+
+	// Path of the resource for an additional output. Empty for the page's
+	// main resource.
+	outputPath string
+
+	// True for the print variant of a page.
+	isPrint bool
+
+	// Actions parsed from the page file. Cleared after the page is
+	// rendered.
+	actions []*action.Action
+	lc      *action.LocationContext
 }
 
 // templateActionData is the data for executing template actions.
@@ -96,128 +185,521 @@ func (ad *templateActionData) Int(name string, def ...int) (int, error) {
 }
 
 func (p *Page) set(a *action.Action, lc *action.LocationContext) error {
+	if len(a.Values) > 0 {
+		return fmt.Errorf("%s: positional arguments not supported by %s", a.Values[0].Location(lc), a.Name)
+	}
 	for k, v := range a.Args {
-		switch k {
-		case "title":
-			p.Title = v.Text
-		case "subtitle":
-			p.Subtitle = v.Text
-		case "created":
-			var err error
-			p.Created, err = time.Parse(time.RFC3339, v.Text)
-			if err != nil {
-				return fmt.Errorf("%s: %w", v.Location(lc), err)
+		if err := p.setArg(k, v.Text, v.Location(lc)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setArg sets the page metadata for the set argument k with value text. The
+// argument location loc is used in error messages.
+func (p *Page) setArg(k string, text string, loc string) error {
+	switch k {
+	case "title":
+		p.Title = text
+	case "subtitle":
+		p.Subtitle = text
+	case "author":
+		p.Author = text
+	case "description":
+		p.Description = text
+	case "created":
+		var err error
+		p.Created, err = time.Parse(time.RFC3339, text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", loc, err)
+		}
+	case "updated":
+		var err error
+		p.Updated, err = time.Parse(time.RFC3339, text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", loc, err)
+		}
+	case "draft":
+		var err error
+		p.Draft, err = strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", loc, err)
+		}
+	case "weight":
+		var err error
+		p.Weight, err = strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", loc, err)
+		}
+	case "path":
+		if !strings.HasPrefix(text, "/") {
+			return fmt.Errorf(`%s: page path must start with "/"`, loc)
+		}
+		p.Path = text
+	case "slug":
+		if text == "" || strings.Contains(text, "/") {
+			return fmt.Errorf("%s: slug must be a non-empty path element", loc)
+		}
+		p.Slug = text
+	case "tags":
+		p.Tags = nil
+		for _, tag := range strings.Split(text, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				p.Tags = append(p.Tags, tag)
 			}
-		case "updated":
-			var err error
-			p.Created, err = time.Parse(time.RFC3339, v.Text)
-			if err != nil {
-				return fmt.Errorf("%s: %w", v.Location(lc), err)
+		}
+	case "layout":
+		p.layout = text
+		p.layoutLoc = loc
+	case "contentType":
+		if _, _, err := mime.ParseMediaType(text); err != nil {
+			return fmt.Errorf("%s: %w", loc, err)
+		}
+		p.contentType = text
+	case "sitemap":
+		include, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", loc, err)
+		}
+		p.SitemapExclude = !include
+	case "print":
+		print, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", loc, err)
+		}
+		p.Print = print
+	case "replacements":
+		replace, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", loc, err)
+		}
+		p.NoReplacements = !replace
+	case "sitemapPriority":
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil || f < 0 || f > 1 {
+			return fmt.Errorf("%s: priority must be a number between 0.0 and 1.0", loc)
+		}
+		p.SitemapPriority = text
+	case "sitemapChangefreq":
+		if !sitemapChangefreqs[text] {
+			return fmt.Errorf("%s: invalid change frequency %q", loc, text)
+		}
+		p.SitemapChangefreq = text
+	default:
+		if strings.HasPrefix(k, "output:") {
+			if err := p.setOutput(k[len("output:"):], text, loc); err != nil {
+				return fmt.Errorf("%s: %w", loc, err)
 			}
-		case "path":
-			if !strings.HasPrefix(v.Text, "/") {
-				return fmt.Errorf(`%s: page path must start with "/"`, v.Location(lc))
+			return nil
+		}
+		if strings.HasPrefix(k, "param:") {
+			if p.Params == nil {
+				p.Params = make(map[string]string)
 			}
-			p.Path = v.Text
-		case "layout":
-			// handled in caller.
-		default:
-			return fmt.Errorf("%s: unknown argument %q", v.Location(lc), k)
+			p.Params[k[len("param:"):]] = text
+			return nil
 		}
+		return fmt.Errorf("%s: unknown argument %q", loc, k)
 	}
 	return nil
 }
 
-func (s *site) processPage(r *Resource) error {
+// pageOutput is an additional output for a page. The argument
+// output:<name>="<layout>" renders the page with the layout to a resource in
+// the page's directory. The resource name is the output name with the
+// layout's extension.
+//
+//	<% set output:index="plain.txt" output:print="print.html" %>
+type pageOutput struct {
+	name      string
+	layout    string
+	layoutLoc string
+}
 
-	scratch := scratch.New()
+func (p *Page) setOutput(name string, layout string, layoutLoc string) error {
+	if name == "" {
+		return errors.New("missing output name")
+	}
+	// Copy the slice so that pages do not share outputs set by cascade
+	// actions.
+	outputs := make([]*pageOutput, 0, len(p.outputs)+1)
+	for _, o := range p.outputs {
+		if o.name != name {
+			outputs = append(outputs, o)
+		}
+	}
+	p.outputs = append(outputs, &pageOutput{name: name, layout: layout, layoutLoc: layoutLoc})
+	return nil
+}
+
+// outputPages returns a page for each additional output of page p and for
+// the print variant of p. The output pages share the metadata and actions of
+// p.
+func (s *site) outputPages(p *Page) ([]*Page, error) {
+	var pages []*Page
+	output := func(layout string, layoutLoc string, upath string) *Page {
+		q := *p
+		q.layout, q.layoutLoc, q.contentType = layout, layoutLoc, ""
+		q.outputs = nil
+		q.outputPath = upath
+		q.Scratch = scratch.New()
+		q.resource = &Resource{FilePath: p.resource.FilePath, ModTime: p.resource.ModTime}
+		pages = append(pages, &q)
+		return &q
+	}
+	for _, o := range p.outputs {
+		output(o.layout, o.layoutLoc, absPath(p.Path, o.name+path.Ext(o.layout)))
+	}
+	if p.Print {
+		if s.config.PrintLayout == "" {
+			return nil, fmt.Errorf("%s: print variant requires PrintLayout in site configuration", p.resource.FilePath)
+		}
+		layoutLoc := filepath.Join(s.dir, common.ConfigDir, "site.json") + ":1"
+		q := output(s.config.PrintLayout, layoutLoc, strings.TrimSuffix(p.Path, "/")+"/print/")
+		q.isPrint = true
+	}
+	return pages, nil
+}
+
+// resourcePath returns the path of the page's resource.
+func (p *Page) resourcePath() string {
+	if p.outputPath != "" {
+		return p.outputPath
+	}
+	return p.Path
+}
 
+// loadPage loads the page meta data from the page's set actions. The meta
+// data for all pages in a directory is loaded before the pages are rendered
+// so that the pages can query each other.
+//
+// The cascade actions are applied before the page's set actions. A nil page
+// is returned for drafts when drafts are not enabled.
+func (s *site) loadPage(r *Resource, dir string, isIndex bool, cascade []cascadeAction) (*Page, error) {
 	p := &Page{
-		Path:    r.Path,
-		Title:   path.Base(r.Path),
-		Scratch: scratch,
+		Path:      r.Path,
+		Title:     path.Base(r.Path),
+		Scratch:   scratch.New(),
+		resource:  r,
+		queryPath: r.Path,
+		dir:       dir,
+		isIndex:   isIndex,
 	}
 
-	actions, lc, err := action.ParseFile(r.FilePath)
+	// The set action overrides the updated time from git.
+	modTime, err := s.gitModTime(r.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	p.Updated = modTime
+	r.ModTime = modTime
+
+	p.actions, p.lc, err = action.ParseFile(r.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	if s.pageExt(r.FilePath) == ".md" {
+		p.actions, err = markdownActions(p.actions, p.lc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range cascade {
+		if err := p.set(c.a, c.lc); err != nil {
+			return nil, err
+		}
+	}
+
+	hasMeta, err := p.setMetaFile(r.FilePath + metaFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range p.actions {
+		if a.Name == "set" {
+			if err := p.set(a, p.lc); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Permalink patterns apply to pages without a path set by the page.
+	if !isIndex && p.Path == r.Path {
+		name := filepath.Base(r.FilePath)
+		name = strings.TrimSuffix(strings.TrimSuffix(name, s.pageExt(name)), ".index")
+		upath, ok, err := s.permalink(p, name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.FilePath, err)
+		}
+		if ok {
+			p.Path = upath
+		}
+	}
+
+	if p.Draft && !s.config.Drafts {
+		return nil, nil
+	}
+
+	if err := s.claimPagePath(p.Path, r.FilePath); err != nil {
+		return nil, err
+	}
+
+	s.addDependency(p.Path, r.FilePath)
+	for _, c := range cascade {
+		s.addDependency(p.Path, c.fpath)
+	}
+	if hasMeta {
+		s.addDependency(p.Path, r.FilePath+metaFileSuffix)
+	}
+
+	// The 'set' action can override the page's path. Use the original path in
+	// page queries.
+	s.addPage(p.queryPath, p)
+	return p, nil
+}
+
+// cascadeAction is a cascade action from an index page.
+type cascadeAction struct {
+	fpath string
+	a     *action.Action
+	lc    *action.LocationContext
+}
+
+// dirCascadeFile is the name of the file in a page directory containing
+// cascade actions for all pages in the directory, including the index page.
+// The file is not a page.
+const dirCascadeFile = "_dir.html"
+
+// loadCascade returns the cascade actions in the index page fpath. The
+// cascade action has the same arguments as the set action. The arguments
+// specify defaults for the pages in the index page's directory and
+// descendant directories.
+func loadCascade(fpath string) ([]cascadeAction, error) {
+	actions, lc, err := action.ParseFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	var result []cascadeAction
+	for _, a := range actions {
+		if a.Name != "cascade" {
+			continue
+		}
+		if v, ok := a.Args["path"]; ok {
+			return nil, fmt.Errorf("%s: path cannot be cascaded", v.Location(lc))
+		}
+		result = append(result, cascadeAction{fpath: fpath, a: a, lc: lc})
+	}
+	return result, nil
+}
+
+// renderPage executes the page's actions and layout and stores the result
+// in the page's resource.
+func (s *site) renderPage(p *Page) error {
+	data, err := s.executePage(p)
+	if err != nil {
+		return err
+	}
+
+	p.Scratch = nil
+	p.actions = nil
+
+	r := p.resource
+	r.Data = data
+	r.Size = int64(len(r.Data))
+	r.Path = p.resourcePath()
+	r.Dependencies = s.dependencies(p.Path)
+	if s.spillDir != "" && len(data) > s.spillThreshold {
+		return s.spill(r)
+	}
+	return nil
+}
+
+// spill writes the data for resource r to a file in the spill directory.
+func (s *site) spill(r *Resource) error {
+	f, err := ioutil.TempFile(s.spillDir, "page")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(r.Data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
 	if err != nil {
 		return err
 	}
+	r.FilePath = f.Name()
+	r.Data = nil
+	if r.ContentType == "" {
+		r.ContentType = "text/html; charset=utf-8"
+	}
+	return nil
+}
+
+// deferPage sets up the page's resource to execute the page when the
+// resource is opened or written.
+func (s *site) deferPage(p *Page) {
+	r := p.resource
+	r.Path = p.resourcePath()
+	r.ContentType = pageContentType(p)
+	r.render = func() ([]byte, error) {
+		p.Scratch = scratch.New()
+		data, err := s.executePage(p)
+		if err != nil {
+			return nil, err
+		}
+		r.Size = int64(len(data))
+		r.Dependencies = s.dependencies(p.Path)
+		return data, nil
+	}
+}
+
+// pageContentType returns the MIME type set by the page or the page's
+// layout. If the MIME type is not set, the page is HTML.
+func pageContentType(p *Page) string {
+	switch {
+	case p.contentType != "":
+		return p.contentType
+	case p.layout != "":
+		return layoutContentType(p.layout)
+	}
+	return ""
+}
 
-	var layout *htemplate.Template
+// executePage executes the page's actions and layout and returns the
+// generated data.
+func (s *site) executePage(p *Page) ([]byte, error) {
+	start := time.Now()
+	r := p.resource
+	lc := p.lc
+
+	var layout *layout
 	var body strings.Builder
 
-	for _, a := range actions {
+	if p.layout != "" {
+		var err error
+		layout, err = s.loadLayout(p.layout)
+		if err != nil {
+			if os.IsNotExist(err) {
+				err = fmt.Errorf("%s: %w", p.layoutLoc, err)
+			}
+			return nil, err
+		}
+		s.addDependency(p.Path, s.loader.Dependencies(p.layout)...)
+	}
+
+	numbers, cited, hasReferences, err := s.citations(p)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range p.actions {
 		switch {
 		case a.Name == action.TextAction:
 			body.Write(a.Text)
-		case a.Name == "set":
-			if err := p.set(a, lc); err != nil {
-				return err
+		case len(a.Values) > 0 && !strings.HasPrefix(a.Name, "t:"):
+			return nil, fmt.Errorf("%s: positional arguments not supported by %s",
+				a.Values[0].Location(lc), a.Name)
+		case a.Name == "set" || a.Name == "cascade":
+			// handled in loadPage and loadCascade.
+		case a.Name == "cite":
+			writeCite(&body, a, numbers)
+		case a.Name == "references":
+			writeReferences(&body, cited)
+		case a.Name == "diagram":
+			a, err := s.interpolateArgs(p, a)
+			if err != nil {
+				return nil, err
 			}
-			if v, ok := a.Args["layout"]; ok {
-				layout, err = s.loader.Load(v.Text)
-				if err != nil {
-					if os.IsNotExist(err) {
-						err = fmt.Errorf("%s: %w", v.Location(lc), err)
-					}
-					return err
-				}
+			if err := s.diagram(&body, p, a); err != nil {
+				return nil, err
 			}
 		case strings.HasPrefix(a.Name, "t:"):
 			if layout == nil {
-				return fmt.Errorf("%s: specify layout with set command before calling templates",
+				return nil, fmt.Errorf("%s: specify layout with set command before calling templates",
 					a.Location(lc))
 			}
 			name := a.Name[len("t:"):]
-			t := layout.Lookup(name)
+			t := layout.lookup(name)
 			if t == nil {
-				return fmt.Errorf("%s: template with name %q not found in layout",
+				return nil, fmt.Errorf("%s: template with name %q not found in layout",
 					a.Location(lc), name)
 			}
+			a, err := s.interpolateArgs(p, a)
+			if err != nil {
+				return nil, err
+			}
+			a, err = s.positionalArgs(p, layout, name, a)
+			if err != nil {
+				return nil, err
+			}
 			ad := templateActionData{
 				Path:    p.Path,
-				Scratch: scratch,
+				Scratch: p.Scratch,
 				lc:      lc,
 				action:  a,
 			}
 			if err := t.Execute(&body, &ad); err != nil {
 				if ad.err != nil {
-					return ad.err
+					return nil, ad.err
 				}
-				return fmt.Errorf("%s: %w", a.Location(lc), err)
+				return nil, fmt.Errorf("%s: %w", a.Location(lc), s.loader.ExecError(p.layout, err))
 			}
 		default:
-			return fmt.Errorf("%s: unknown command %q", a.Location(lc), a.Name)
+			return nil, fmt.Errorf("%s: unknown command %q", a.Location(lc), a.Name)
 		}
 	}
 
+	if !hasReferences {
+		writeReferences(&body, cited)
+	}
+
 	var buf bytes.Buffer
 	if layout == nil {
 		buf.WriteString(body.String())
 	} else {
 		p.Content = htemplate.HTML(body.String())
-		err = layout.Execute(&buf, p)
+		err := layout.Execute(&buf, p)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("%s: %w", r.FilePath, s.loader.ExecError(p.layout, err))
 		}
 	}
 
-	p.Scratch = nil
-
-	data, err := html.Minify(buf.Bytes())
-	if err != nil {
-		return fmt.Errorf("%s:1 %v", r.FilePath, err)
+	data := buf.Bytes()
+	addTime(&s.timings.Render, start)
+	r.ContentType = pageContentType(p)
+	if r.ContentType == "" || isTextHTML(r.ContentType) {
+		defer addTime(&s.timings.Minify, time.Now())
+		var err error
+		if s.config.Analytics != "" {
+			data, err = s.addAnalytics(p, data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", r.FilePath, err)
+			}
+		}
+		if !s.config.DisableMinify {
+			data, err = html.MinifyWithOptions(data, &html.Options{
+				RawTags:                   s.config.RawTags,
+				CollapseBooleanAttributes: s.config.CollapseBooleanAttributes,
+				RemoveRedundantAttributes: s.config.RemoveRedundantAttributes,
+				Warn: func(msg string) {
+					s.reportWarning(r.FilePath, "%s", msg)
+				},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%s:1 %v", r.FilePath, err)
+			}
+		}
+		data, err = s.postProcess(p, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.FilePath, err)
+		}
+		if err := s.checkPage(p, data); err != nil {
+			return nil, fmt.Errorf("%s: %w", r.FilePath, err)
+		}
+	} else if isCalendar(r.ContentType) {
+		data = foldICal(data)
 	}
-
-	r.Data = data
-	r.Size = int64(len(r.Data))
-	r.ModTime = time.Time{}
-
-	// The 'set' action can override the page's path. Use the original path in
-	// page queries.
-	queryPath := r.Path
-	r.Path = p.Path
-	s.addPage(queryPath, p)
-
-	return nil
+	return data, nil
 }
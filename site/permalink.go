@@ -0,0 +1,79 @@
+package site
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+// permalink returns the path of page p computed from the permalink pattern
+// for the page's section. The pattern for the section with the longest
+// matching directory prefix is used. The boolean result is false if no
+// pattern applies to the page.
+//
+// A pattern is a path with the following placeholders:
+//
+//	:year, :month, :day  the page's created time
+//	:slug                the page slug or the title converted to a path element
+//	:name                the page file name without the extension
+//
+// For example, "Permalinks": {"/blog/": "/blog/:year/:slug/"} places page
+// /blog/post.html created in 2020 at /blog/2020/<title slug>/.
+func (s *site) permalink(p *Page, name string) (string, bool, error) {
+	section := ""
+	for prefix := range s.config.Permalinks {
+		if strings.HasPrefix(p.dir, prefix) && len(prefix) > len(section) {
+			section = prefix
+		}
+	}
+	if section == "" {
+		return "", false, nil
+	}
+	pattern := s.config.Permalinks[section]
+	if !strings.HasPrefix(pattern, "/") {
+		return "", false, fmt.Errorf(`permalink pattern %q for %s must start with "/"`, pattern, section)
+	}
+	elems := strings.Split(pattern, "/")
+	for i, e := range elems {
+		if !strings.HasPrefix(e, ":") {
+			continue
+		}
+		switch e {
+		case ":year", ":month", ":day":
+			if p.Created.IsZero() {
+				return "", false, fmt.Errorf("permalink pattern %q requires created time", pattern)
+			}
+			switch e {
+			case ":year":
+				elems[i] = fmt.Sprintf("%04d", p.Created.Year())
+			case ":month":
+				elems[i] = fmt.Sprintf("%02d", p.Created.Month())
+			case ":day":
+				elems[i] = fmt.Sprintf("%02d", p.Created.Day())
+			}
+		case ":slug":
+			elems[i] = p.Slug
+			if elems[i] == "" {
+				elems[i] = common.Slug(p.Title)
+			}
+			if elems[i] == "" {
+				return "", false, fmt.Errorf("cannot create slug from title %q", p.Title)
+			}
+		case ":name":
+			elems[i] = name
+		default:
+			return "", false, fmt.Errorf("unknown placeholder %s in permalink pattern %q", e, pattern)
+		}
+	}
+	return path.Clean(strings.Join(elems, "/")) + trailingSlash(pattern), true, nil
+}
+
+// trailingSlash returns "/" if upath ends with a slash.
+func trailingSlash(upath string) string {
+	if strings.HasSuffix(upath, "/") && upath != "/" {
+		return "/"
+	}
+	return ""
+}
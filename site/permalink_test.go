@@ -0,0 +1,43 @@
+package site
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPermalinks(t *testing.T) {
+	got := make(map[string]string)
+	err := Visit("testdata/permalinks", nil, func(r *Resource) error {
+		if r.Path != "/robots.txt" {
+			got[r.Path] = string(r.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"/blog/":                     "<h1>Blog</h1>\n",
+		"/blog/2021/03/hello-world/": "<h1>Hello, World!</h1>\n",
+		"/blog/2021/03/hi/":          "<h1>Hello, World!</h1>\n",
+		"/fixed/":                    "<h1>Fixed</h1>\n",
+		"/archive/old/":              "<h1>Old</h1>\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPagePathCollision(t *testing.T) {
+	var buf bytes.Buffer
+	err := Visit("testdata/collision", &buf, func(*Resource) error { return nil })
+	if err == nil {
+		t.Fatal("expected error for pages with the same path")
+	}
+	const want = "page path /blog/2021/hello/ is also used by testdata/collision/page/blog/"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("got %q, want error containing %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,315 @@
+package site
+
+import (
+	"errors"
+	"image"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/garyburd/staticsite/common"
+	"github.com/garyburd/staticsite/site/html"
+)
+
+// postProcess applies the post-processing passes enabled in the site
+// configuration to the minified HTML for page p.
+func (s *site) postProcess(p *Page, data []byte) ([]byte, error) {
+	if p.isPrint {
+		var err error
+		data, err = absoluteURLs(p, data)
+		if err != nil {
+			return nil, err
+		}
+		data, err = html.LinkFootnotes(data, func(href string) string {
+			return s.printURL(p, href)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.config.Math {
+		var err error
+		data, err = html.Math(data, s.config.RawTags)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.replacer != nil && !p.NoReplacements {
+		var err error
+		data, err = html.ReplaceText(data, s.config.RawTags, s.replacer)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.config.Typography {
+		var err error
+		data, err = html.Typography(data, s.config.RawTags)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.config.Integrity || s.config.ImageAttributes || s.config.Canonical {
+		var err error
+		data, err = s.rewriteTags(p, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.config.Canonical {
+		var err error
+		data, err = s.addCanonical(p, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.config.CSP != "" {
+		// Hashes are computed last so that they match the final content.
+		return s.addCSP(p, data)
+	}
+	return data, nil
+}
+
+func (s *site) rewriteTags(p *Page, data []byte) ([]byte, error) {
+	images := 0
+	return html.Rewrite(data, func(t *html.Tag) error {
+		if s.config.Integrity {
+			if err := s.addIntegrity(p.Path, t); err != nil {
+				return err
+			}
+		}
+		if s.config.Canonical && t.Name == "a" {
+			s.normalizeHref(p.Path, t)
+		}
+		if s.config.ImageAttributes && t.Name == "img" {
+			images++
+			if err := s.addImageAttributes(p.Path, t, images > s.config.EagerImages); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// addIntegrity adds integrity and crossorigin attributes to script and
+// stylesheet link tags that reference a local static file.
+func (s *site) addIntegrity(upage string, t *html.Tag) error {
+	var key string
+	switch t.Name {
+	case "script":
+		key = "src"
+	case "link":
+		if rel, _ := t.Get("rel"); rel != "stylesheet" && rel != "preload" && rel != "modulepreload" {
+			return nil
+		}
+		key = "href"
+	default:
+		return nil
+	}
+	if _, ok := t.Get("integrity"); ok {
+		return nil
+	}
+	u, ok := t.Get(key)
+	if !ok {
+		return nil
+	}
+	upath, ok := localPath(u)
+	if !ok {
+		return nil
+	}
+	fpath := s.staticFile(upage, upath)
+	p, err := ioutil.ReadFile(fpath)
+	if os.IsNotExist(err) {
+		// Leave references to generated resources unchanged.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	t.Set("integrity", integrity(p))
+	if _, ok := t.Get("crossorigin"); !ok {
+		t.Set("crossorigin", "anonymous")
+	}
+	return nil
+}
+
+// addImageAttributes adds missing width and height attributes to an img tag
+// that references a local static file. If lazy is true, the loading and
+// decoding attributes are also added.
+func (s *site) addImageAttributes(upage string, t *html.Tag, lazy bool) error {
+	if lazy {
+		if _, ok := t.Get("loading"); !ok {
+			t.Set("loading", "lazy")
+		}
+		if _, ok := t.Get("decoding"); !ok {
+			t.Set("decoding", "async")
+		}
+	}
+	_, hasWidth := t.Get("width")
+	_, hasHeight := t.Get("height")
+	if hasWidth || hasHeight {
+		// Do not change the aspect ratio set by the author.
+		return nil
+	}
+	u, ok := t.Get("src")
+	if !ok {
+		return nil
+	}
+	upath, ok := localPath(u)
+	if !ok {
+		return nil
+	}
+	fpath := s.staticFile(upage, upath)
+	config, err := readImageConfig(fpath)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, image.ErrFormat) {
+		// Missing file or format without a registered decoder such as SVG.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	t.Set("width", strconv.Itoa(config.Width))
+	t.Set("height", strconv.Itoa(config.Height))
+	return nil
+}
+
+// normalizeHref converts an href to a local page to the canonical form of the
+// page URL: index.html is removed and a trailing slash is added to directory
+// paths.
+func (s *site) normalizeHref(upage string, t *html.Tag) {
+	u, ok := t.Get("href")
+	if !ok {
+		return
+	}
+	upath, ok := localPath(u)
+	if !ok {
+		return
+	}
+	suffix := u[len(upath):]
+	switch {
+	case upath == "index.html":
+		upath = "./"
+	case strings.HasSuffix(upath, "/index.html"):
+		upath = upath[:len(upath)-len("index.html")]
+	case !strings.HasSuffix(upath, "/") && path.Ext(upath) == "" && s.isDirectory(absPath(upage, upath)):
+		upath += "/"
+	default:
+		return
+	}
+	t.Set("href", upath+suffix)
+}
+
+// isDirectory returns whether the resource at upath is served with a
+// trailing slash.
+func (s *site) isDirectory(upath string) bool {
+	fpaths := []string{
+		s.filePath(common.PageDir, upath+".html"),
+		s.filePath(common.PageDir, upath+"/index.html"),
+		s.filePath(common.StaticDir, upath+"/index.html"),
+	}
+	if s.config.Markdown {
+		fpaths = append(fpaths,
+			s.filePath(common.PageDir, upath+".md"),
+			s.filePath(common.PageDir, upath+"/index.md"))
+	}
+	for _, fpath := range fpaths {
+		if _, err := os.Stat(fpath); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// addCanonical adds a canonical link to the head of page p if the page does
+// not already have one.
+func (s *site) addCanonical(p *Page, data []byte) ([]byte, error) {
+	found := false
+	_, err := html.Rewrite(data, func(t *html.Tag) error {
+		if rel, _ := t.Get("rel"); t.Name == "link" && rel == "canonical" {
+			found = true
+		}
+		return nil
+	})
+	if err != nil || found {
+		return data, err
+	}
+	done := false
+	return html.Rewrite(data, func(t *html.Tag) error {
+		if t.Name == "head" && !done {
+			t.After = `<link rel=canonical href="` + attrEscaper.Replace(s.config.BaseURL+p.Path) + `">`
+			done = true
+		}
+		return nil
+	})
+}
+
+// addAnalytics inserts the analytics HTML from the site configuration at the
+// start of the head element of page p.
+func (s *site) addAnalytics(p *Page, data []byte) ([]byte, error) {
+	done := false
+	data, err := html.Rewrite(data, func(t *html.Tag) error {
+		if t.Name == "head" && !done {
+			t.After = s.config.Analytics
+			done = true
+		}
+		return nil
+	})
+	if err == nil && !done {
+		s.reportWarning(p.resource.FilePath, "analytics not added to page without head tag")
+	}
+	return data, err
+}
+
+// absoluteURLs converts relative href and src attributes in the print
+// variant of page p to absolute paths. The print variant is in a
+// subdirectory of the page, so relative URLs resolve differently.
+func absoluteURLs(p *Page, data []byte) ([]byte, error) {
+	return html.Rewrite(data, func(t *html.Tag) error {
+		for _, key := range []string{"href", "src"} {
+			u, ok := t.Get(key)
+			if !ok {
+				continue
+			}
+			upath, ok := localPath(u)
+			if !ok || strings.HasPrefix(upath, "/") {
+				continue
+			}
+			t.Set(key, absPath(p.Path, upath)+u[len(upath):])
+		}
+		return nil
+	})
+}
+
+// printURL returns the URL shown in the print variant of page p for a link
+// to href. Links to fragments in the page and links that are not to web
+// pages are skipped.
+func (s *site) printURL(p *Page, href string) string {
+	if href == "" || strings.HasPrefix(href, "#") {
+		return ""
+	}
+	if strings.HasPrefix(href, "//") {
+		return "https:" + href
+	}
+	if i := strings.IndexAny(href, ":/?#"); i >= 0 && href[i] == ':' {
+		if strings.HasPrefix(href, "http:") || strings.HasPrefix(href, "https:") {
+			return href
+		}
+		return ""
+	}
+	// Resolve relative links against the original page.
+	return s.config.BaseURL + absPath(p.Path, href)
+}
+
+// localPath returns the path for URL u with the query and fragment removed.
+// The boolean result is false if u references another host.
+func localPath(u string) (string, bool) {
+	if strings.HasPrefix(u, "//") {
+		return "", false
+	}
+	if i := strings.IndexAny(u, ":/?#"); i >= 0 && u[i] == ':' {
+		return "", false
+	}
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	return u, u != ""
+}
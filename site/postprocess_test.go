@@ -0,0 +1,99 @@
+package site
+
+import (
+	"testing"
+
+	"github.com/garyburd/staticsite/site/html"
+)
+
+var localPathTests = []struct {
+	u    string
+	want string
+	ok   bool
+}{
+	{"/css/a.css", "/css/a.css", true},
+	{"a.js?v=123", "a.js", true},
+	{"../img/a.png#x", "../img/a.png", true},
+	{"https://example.com/a.js", "", false},
+	{"//example.com/a.js", "", false},
+	{"data:image/png;base64,AAAA", "", false},
+	{"#top", "", false},
+}
+
+func TestLocalPath(t *testing.T) {
+	for _, tt := range localPathTests {
+		got, ok := localPath(tt.u)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("localPath(%q) = %q, %v, want %q, %v", tt.u, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+var normalizeHrefTests = []struct {
+	href string
+	want string
+}{
+	{"/docs", "/docs/"},
+	{"/docs#intro", "/docs/#intro"},
+	{"../../docs?q=1", "../../docs/?q=1"},
+	{"/blog/index.html", "/blog/"},
+	{"index.html", "./"},
+	{"/missing", "/missing"},
+	{"/docs.pdf", "/docs.pdf"},
+	{"https://example.com/docs", "https://example.com/docs"},
+}
+
+func TestNormalizeHref(t *testing.T) {
+	s, err := newSite("testdata/canonical", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range normalizeHrefTests {
+		tag := &html.Tag{Name: "a", Attr: []html.Attribute{{Key: "href", Val: tt.href}}}
+		s.normalizeHref("/blog/post/", tag)
+		if got, _ := tag.Get("href"); got != tt.want {
+			t.Errorf("normalizeHref(%q) = %q, want %q", tt.href, got, tt.want)
+		}
+	}
+}
+
+func TestAddAnalytics(t *testing.T) {
+	s := &site{
+		config:   &config{Analytics: `<script src=/a.js></script>`},
+		warnings: make(map[string]struct{}),
+	}
+	p := &Page{resource: &Resource{FilePath: "page/index.html"}}
+	got, err := s.addAnalytics(p, []byte(`<html><head><title>x</title></head></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `<html><head><script src=/a.js></script><title>x</title></head></html>`
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := s.addAnalytics(p, []byte(`<p>x`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.warnings) != 1 {
+		t.Errorf("got %d warnings, want 1", len(s.warnings))
+	}
+}
+
+func TestImageAttributes(t *testing.T) {
+	var got string
+	err := Visit("testdata/images", nil, func(r *Resource) error {
+		if r.Path == "/" {
+			got = string(r.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The size of an SVG image is not known.
+	const want = "<img src=a.png alt=A width=3 height=2>\n<img src=b.svg alt=B>\n<img src=a.png alt=C loading=lazy decoding=async width=3 height=2>\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
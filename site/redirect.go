@@ -0,0 +1,118 @@
+package site
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+// Redirect is a rule from the redirects file.
+//
+// The redirects file config/redirects has one rule per line. Each rule is
+// the path to redirect from followed by the URL to redirect to. If the from
+// path ends with *, the rule matches all paths with the prefix before the *
+// and the text :splat in the to URL is replaced with the text matched by the
+// *. Blank lines and lines starting with # are ignored.
+//
+//	/old-page /new-page/
+//	/blog/* /posts/:splat
+type Redirect struct {
+	From string
+	To   string
+}
+
+// IsWildcard returns whether the rule matches a path prefix.
+func (r *Redirect) IsWildcard() bool {
+	return strings.HasSuffix(r.From, "*")
+}
+
+// Prefix returns the path prefix matched by a wildcard rule.
+func (r *Redirect) Prefix() string {
+	return strings.TrimSuffix(r.From, "*")
+}
+
+// Match returns the redirect URL for upath and whether the rule matches
+// upath.
+func (r *Redirect) Match(upath string) (string, bool) {
+	if !r.IsWildcard() {
+		if upath != r.From {
+			return "", false
+		}
+		return r.To, true
+	}
+	if !strings.HasPrefix(upath, r.Prefix()) {
+		return "", false
+	}
+	return strings.Replace(r.To, ":splat", upath[len(r.Prefix()):], -1), true
+}
+
+// ReadRedirects reads the redirect rules for the site in directory dir. The
+// redirects file is optional.
+func ReadRedirects(dir string) ([]*Redirect, error) {
+	fpath := filepath.Join(dir, common.ConfigDir, "redirects")
+	f, err := os.Open(fpath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var redirects []*Redirect
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected from path and to URL", fpath, line)
+		}
+		r := &Redirect{From: fields[0], To: fields[1]}
+		if !strings.HasPrefix(r.From, "/") {
+			return nil, fmt.Errorf("%s:%d: from path must start with /", fpath, line)
+		}
+		if strings.Contains(r.Prefix(), "*") {
+			return nil, fmt.Errorf("%s:%d: * allowed at end of from path only", fpath, line)
+		}
+		redirects = append(redirects, r)
+	}
+	return redirects, scanner.Err()
+}
+
+// visitRedirects calls the visit function with a resource for each rule in
+// the redirects file that matches a single path.
+func (s *site) visitRedirects() error {
+	redirects, err := ReadRedirects(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, rule := range redirects {
+		if rule.IsWildcard() {
+			continue
+		}
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, `<!doctype html><meta http-equiv=refresh content="0; url=%[1]s"><a href="%[1]s">%[1]s</a>`,
+			html.EscapeString(rule.To))
+		r := &Resource{
+			Path:     rule.From,
+			FilePath: filepath.Join(s.dir, common.ConfigDir, "redirects"),
+			Redirect: rule.To,
+			Data:     buf.Bytes(),
+		}
+		r.Size = int64(len(r.Data))
+		if err := s.visitFile(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
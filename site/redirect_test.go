@@ -0,0 +1,25 @@
+package site
+
+import "testing"
+
+var redirectMatchTests = []struct {
+	from, to, upath string
+	want            string
+	ok              bool
+}{
+	{"/old", "/new/", "/old", "/new/", true},
+	{"/old", "/new/", "/old/", "", false},
+	{"/blog/*", "/posts/:splat", "/blog/2020/x/", "/posts/2020/x/", true},
+	{"/blog/*", "/posts/", "/blog/x/", "/posts/", true},
+	{"/blog/*", "/posts/:splat", "/docs/", "", false},
+}
+
+func TestRedirectMatch(t *testing.T) {
+	for _, tt := range redirectMatchTests {
+		r := &Redirect{From: tt.from, To: tt.to}
+		got, ok := r.Match(tt.upath)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Redirect{%q, %q}.Match(%q) = %q, %v, want %q, %v", tt.from, tt.to, tt.upath, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package site
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+const defaultRemoteTTL = time.Hour
+
+type remoteFuncs struct{ site *site }
+
+type remoteCacheEntry struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// GetText fetches the resource at url and returns the response body as a
+// string. The optional ttl is a duration string specifying how long a cached
+// copy of the response on disk is used before fetching the resource again.
+func (rf remoteFuncs) GetText(url string, ttl ...string) (string, error) {
+	p, err := rf.site.getRemote(url, ttl)
+	return string(p), err
+}
+
+// GetJSON fetches the resource at url and returns the decoded JSON response
+// body. See GetText for a description of ttl.
+func (rf remoteFuncs) GetJSON(url string, ttl ...string) (interface{}, error) {
+	p, err := rf.site.getRemote(url, ttl)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(p, &v); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", url, err)
+	}
+	return v, nil
+}
+
+// getRemote returns the body of the resource at url. Each url is fetched at
+// most once per site visit.
+func (s *site) getRemote(url string, ttl []string) ([]byte, error) {
+	maxAge := defaultRemoteTTL
+	if len(ttl) > 0 {
+		var err error
+		maxAge, err = time.ParseDuration(ttl[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.remoteMu.Lock()
+	e := s.remoteCache[url]
+	if e == nil {
+		e = &remoteCacheEntry{}
+		s.remoteCache[url] = e
+	}
+	s.remoteMu.Unlock()
+
+	e.once.Do(func() {
+		e.data, e.err = s.fetchRemote(url, maxAge)
+	})
+	return e.data, e.err
+}
+
+func (s *site) fetchRemote(url string, maxAge time.Duration) ([]byte, error) {
+	fpath := filepath.Join(s.dir, common.CacheDir, "remote", fmt.Sprintf("%x", sha256.Sum256([]byte(url))))
+
+	fi, err := os.Stat(fpath)
+	if err == nil && time.Since(fi.ModTime()) < maxAge {
+		return ioutil.ReadFile(fpath)
+	}
+
+	p, fetchErr := httpGet(url)
+	if fetchErr != nil {
+		// Use stale copy of the resource if available.
+		if p, err := ioutil.ReadFile(fpath); err == nil {
+			return p, nil
+		}
+		return nil, fetchErr
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(fpath, p, 0666); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+var remoteClient = &http.Client{Timeout: 30 * time.Second}
+
+func httpGet(url string) ([]byte, error) {
+	resp, err := remoteClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Fetch downloads the resource at rawurl, adds the resource to the site and
+// returns the resource's path. Use Fetch to self-host third-party fonts,
+// scripts and stylesheets. The downloaded resource is cached in the site's
+// cache directory and is not fetched again.
+//
+// The optional integrity argument is a subresource integrity value such as
+// "sha384-...". Fetch returns an error if the resource does not match the
+// value. A resource that does not match is not cached. A cached resource
+// that does not match is fetched again.
+func (sf staticFuncs) Fetch(rawurl string, integrity ...string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("fetch %s: URL must be http or https", rawurl)
+	}
+	sum := sha256.Sum256([]byte(rawurl))
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		name = "index"
+	}
+	s := sf.site
+	cpath := filepath.Join(s.dir, common.CacheDir, "fetch", fmt.Sprintf("%x%s", sum, path.Ext(name)))
+
+	check := func(p []byte) error {
+		if len(integrity) == 0 {
+			return nil
+		}
+		return checkIntegrity(p, integrity[0])
+	}
+	key := "fetch " + rawurl
+	if len(integrity) > 0 {
+		key += " " + integrity[0]
+	}
+
+	s.remoteMu.Lock()
+	e := s.remoteCache[key]
+	if e == nil {
+		e = &remoteCacheEntry{}
+		s.remoteCache[key] = e
+	}
+	s.remoteMu.Unlock()
+
+	e.once.Do(func() {
+		e.data, e.err = ioutil.ReadFile(cpath)
+		if e.err == nil && check(e.data) == nil {
+			return
+		}
+		e.data, e.err = httpGet(rawurl)
+		if e.err != nil {
+			return
+		}
+		if err := check(e.data); err != nil {
+			e.err = fmt.Errorf("fetch %s: %w", rawurl, err)
+			return
+		}
+		if e.err = os.MkdirAll(filepath.Dir(cpath), 0777); e.err != nil {
+			return
+		}
+		e.err = ioutil.WriteFile(cpath, e.data, 0666)
+	})
+	if e.err != nil {
+		return "", e.err
+	}
+	upath := fmt.Sprintf("/_fetch/%x/%s", sum[:6], name)
+	s.addGenerated(upath, cpath)
+	return upath, nil
+}
+
+// checkIntegrity returns an error if p does not match the subresource
+// integrity value.
+func checkIntegrity(p []byte, value string) error {
+	i := strings.Index(value, "-")
+	if i < 0 {
+		return fmt.Errorf("invalid integrity value %q", value)
+	}
+	var h hash.Hash
+	switch value[:i] {
+	case "sha256":
+		h = sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported integrity hash %q", value[:i])
+	}
+	h.Write(p)
+	if got := value[:i] + "-" + base64.StdEncoding.EncodeToString(h.Sum(nil)); got != value {
+		return fmt.Errorf("integrity mismatch: got %s, want %s", got, value)
+	}
+	return nil
+}
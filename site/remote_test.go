@@ -0,0 +1,146 @@
+package site
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	requests := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		w.Write([]byte("body{}"))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 2; i++ {
+		s, err := newSite(dir, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sf := staticFuncs{s}
+		upath, err := sf.Fetch(ts.URL+"/css/font.css", integrity([]byte("body{}")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := ioutil.ReadFile(s.generated[upath])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(p) != "body{}" {
+			t.Errorf("got %q, want %q", p, "body{}")
+		}
+		if _, err := sf.Fetch(ts.URL+"/css/bad.css", integrity([]byte("other"))); err == nil {
+			t.Error("expected integrity error")
+		}
+	}
+	if requests["/css/font.css"] != 1 {
+		t.Errorf("got %d requests for font.css, want 1", requests["/css/font.css"])
+	}
+	// The resource that does not match is not cached.
+	if requests["/css/bad.css"] != 2 {
+		t.Errorf("got %d requests for bad.css, want 2", requests["/css/bad.css"])
+	}
+	fis, err := ioutil.ReadDir(filepath.Join(dir, "cache", "fetch"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 1 {
+		t.Fatalf("got %d cached files, want 1", len(fis))
+	}
+
+	// A cached resource that does not match the integrity value is fetched
+	// again.
+	s, err := newSite(dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "cache", "fetch", fis[0].Name()), []byte("stale"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (staticFuncs{s}).Fetch(ts.URL+"/css/font.css", integrity([]byte("body{}"))); err != nil {
+		t.Fatal(err)
+	}
+	if requests["/css/font.css"] != 2 {
+		t.Errorf("got %d requests for font.css, want 2", requests["/css/font.css"])
+	}
+}
+
+func TestGetRemote(t *testing.T) {
+	requests := 0
+	fail := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `{"n": %d}`, requests)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Each test is a new build of the site.
+	for i, tt := range []struct {
+		ttl  string
+		fail bool
+		want string
+	}{
+		{"1h", false, `{"n": 1}`}, // fetch
+		{"1h", false, `{"n": 1}`}, // cached copy is fresh
+		{"0s", false, `{"n": 2}`}, // cached copy is stale
+		{"0s", true, `{"n": 2}`},  // use stale copy on fetch error
+	} {
+		fail = tt.fail
+		s, err := newSite(dir, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := remoteFuncs{s}.GetText(ts.URL+"/a.json", tt.ttl)
+		if err != nil {
+			t.Errorf("%d: GetText returned error %v", i, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%d: GetText() = %q, want %q", i, got, tt.want)
+		}
+	}
+
+	// A URL is fetched once per build.
+	fail = false
+	s, err := newSite(dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		v, err := remoteFuncs{s}.GetJSON(ts.URL+"/b.json", "0s")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := v.(map[string]interface{})["n"]; got != float64(4) {
+			t.Errorf("GetJSON() n = %v, want 4", got)
+		}
+	}
+	if requests != 4 {
+		t.Errorf("got %d requests, want 4", requests)
+	}
+	if _, err := (remoteFuncs{s}).GetText(ts.URL+"/c.json", "x"); err == nil {
+		t.Error("GetText with invalid ttl did not return error")
+	}
+}
@@ -0,0 +1,60 @@
+package site
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/garyburd/staticsite/common"
+	"github.com/garyburd/staticsite/site/html"
+)
+
+// readReplacements reads the text replacements file config/replacements for
+// the site in directory dir. Each line in the file is the text to replace
+// followed by whitespace and the replacement HTML. The text to replace is
+// matched against the escaped page text; the replacement is not escaped.
+// Blank lines and lines starting with # are ignored.
+//
+//	:smile: 😄
+//	SSG static site generator
+//	TM <sup>TM</sup>
+//
+// A nil replacer is returned if the file does not exist.
+func readReplacements(dir string) (*html.WordReplacer, error) {
+	fpath := filepath.Join(dir, common.ConfigDir, "replacements")
+	f, err := os.Open(fpath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var oldnew []string
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		i := strings.IndexAny(text, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("%s:%d: expected text and replacement", fpath, line)
+		}
+		oldnew = append(oldnew,
+			template.HTMLEscapeString(text[:i]),
+			strings.TrimSpace(text[i:]))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(oldnew) == 0 {
+		return nil, nil
+	}
+	return html.NewWordReplacer(oldnew...), nil
+}
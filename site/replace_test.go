@@ -0,0 +1,28 @@
+package site
+
+import "testing"
+
+func TestReplacements(t *testing.T) {
+	s, err := newSite("testdata/replace", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		p        *Page
+		in, want string
+	}{
+		{&Page{}, `<p>A SSG :smile:<code>SSG</code>`, `<p>A static site generator 😄<code>SSG</code>`},
+		{&Page{}, `<p>Q&amp;A TM`, `<p><abbr>Q&amp;A</abbr> <sup>TM</sup>`},
+		{&Page{}, `<p>HTML SSGs TM.`, `<p>HTML SSGs <sup>TM</sup>.`},
+		{&Page{}, `<p>x:smile:y`, `<p>x😄y`},
+		{&Page{NoReplacements: true}, `<p>A SSG :smile:`, `<p>A SSG :smile:`},
+	} {
+		got, err := s.postProcess(tt.p, []byte(tt.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("postProcess(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
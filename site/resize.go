@@ -0,0 +1,180 @@
+package site
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+// resizeImage returns a version of the static image at upath scaled to fit
+// within width and height. The scaled image is cached in the site's cache
+// directory and added to the site as a generated resource. Images smaller
+// than the bounds are not scaled.
+func (s *site) resizeImage(upath string, fpath string, width int, height int) (*Image, error) {
+	config, err := readImageConfig(fpath)
+	if err != nil {
+		return nil, err
+	}
+	w, h := fitSize(config.Width, config.Height, width, height)
+	if w == config.Width && h == config.Height {
+		return &Image{Src: upath, Width: w, Height: h}, nil
+	}
+
+	ext := strings.ToLower(path.Ext(upath))
+	if ext != ".jpg" && ext != ".jpeg" {
+		ext = ".png"
+	}
+	dst := fmt.Sprintf("%s/_resized/%s-%dx%d%s",
+		path.Dir(upath), strings.TrimSuffix(path.Base(upath), path.Ext(upath)), w, h, ext)
+
+	fi, err := os.Stat(fpath)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s %d %d %d %d", fpath, fi.Size(), fi.ModTime().UnixNano(), w, h)
+	cpath := filepath.Join(s.dir, common.CacheDir, "resized", fmt.Sprintf("%x%s", sha256.Sum256([]byte(key)), ext))
+	if _, err := os.Stat(cpath); os.IsNotExist(err) {
+		if err := writeResized(cpath, fpath, w, h, ext); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	s.addGenerated(dst, cpath)
+	return &Image{Src: dst, Width: w, Height: h}, nil
+}
+
+// fitSize returns the size of an image with width w and height h scaled to
+// fit within maxWidth and maxHeight. A zero maximum is not constrained.
+func fitSize(w, h, maxWidth, maxHeight int) (int, int) {
+	scale := 1.0
+	if maxWidth > 0 && w > maxWidth {
+		scale = float64(maxWidth) / float64(w)
+	}
+	if maxHeight > 0 && h > maxHeight {
+		if s := float64(maxHeight) / float64(h); s < scale {
+			scale = s
+		}
+	}
+	if scale == 1 {
+		return w, h
+	}
+	sw, sh := int(float64(w)*scale+0.5), int(float64(h)*scale+0.5)
+	if sw < 1 {
+		sw = 1
+	}
+	if sh < 1 {
+		sh = 1
+	}
+	return sw, sh
+}
+
+func writeResized(cpath string, fpath string, w, h int, ext string) error {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return err
+	}
+	src, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", fpath, err)
+	}
+	dst := scaleImage(src, w, h)
+	var buf bytes.Buffer
+	if ext == ".png" {
+		err = png.Encode(&buf, dst)
+	} else {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cpath), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cpath, buf.Bytes(), 0666)
+}
+
+// scaleImage scales src to width w and height h by averaging the source
+// pixels covered by each destination pixel.
+func scaleImage(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := b.Min.Y + (y+1)*b.Dy()/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := b.Min.X + (x+1)*b.Dx()/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// addGenerated records a file generated by a template function. The file is
+// added to the site at upath after the pages are generated.
+func (s *site) addGenerated(upath string, fpath string) {
+	s.stateMu.Lock()
+	s.generated[upath] = fpath
+	s.stateMu.Unlock()
+}
+
+// visitGenerated visits the files generated by template functions.
+func (s *site) visitGenerated() error {
+	s.stateMu.Lock()
+	generated := make(map[string]string, len(s.generated))
+	upaths := make([]string, 0, len(s.generated))
+	for upath, fpath := range s.generated {
+		generated[upath] = fpath
+		upaths = append(upaths, upath)
+	}
+	s.stateMu.Unlock()
+	sort.Strings(upaths)
+	for _, upath := range upaths {
+		fpath := generated[upath]
+		fi, err := os.Stat(fpath)
+		if err != nil {
+			return err
+		}
+		r := &Resource{Path: upath, FilePath: fpath, ModTime: fi.ModTime(), Size: fi.Size()}
+		if err := s.visitFile(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
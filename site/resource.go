@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"sync"
 	"time"
 )
 
@@ -45,8 +46,24 @@ type Resource struct {
 	// stored on disk.
 	Data []byte
 
+	// ContentType is the MIME type of Data or of pages written to a spill
+	// file. If not set, the MIME type of Data is text/html.
+	ContentType string
+
+	// Header is additional HTTP response headers for the resource.
+	Header http.Header
+
+	// Dependencies is the sorted list of files used to generate the
+	// resource. Dependencies is set for pages only.
+	Dependencies []string
+
 	// For use by commands.
 	UpdateReason string
+
+	// render generates the data for pages visited with the
+	// WithDeferredPages option.
+	renderMu sync.Mutex
+	render   func() ([]byte, error)
 }
 
 type ReadSeekCloser interface {
@@ -60,10 +77,17 @@ func (readSeekNopClose) Close() error { return nil }
 
 // Open opens an reader on the resource's data.
 func (r *Resource) Open() (reader ReadSeekCloser, contentType string, err error) {
-	if r.Data != nil {
-		// Assume that MIME type of data loaded from the content directory is text/html.
-		ct := "text/html; charset=utf-8"
-		return readSeekNopClose{bytes.NewReader(r.Data)}, ct, nil
+	if r.Data != nil || r.render != nil {
+		data, err := r.data()
+		if err != nil {
+			return nil, "", err
+		}
+		ct := r.ContentType
+		if ct == "" {
+			// Assume that MIME type of data loaded from the content directory is text/html.
+			ct = "text/html; charset=utf-8"
+		}
+		return readSeekNopClose{bytes.NewReader(data)}, ct, nil
 	}
 
 	f, err := os.Open(r.FilePath)
@@ -71,7 +95,10 @@ func (r *Resource) Open() (reader ReadSeekCloser, contentType string, err error)
 		return nil, "", err
 	}
 
-	ct := mime.TypeByExtension(path.Ext(r.Path))
+	ct := r.ContentType
+	if ct == "" {
+		ct = mime.TypeByExtension(path.Ext(r.Path))
+	}
 	if ct == "" {
 		var buf [512]byte
 		n, _ := io.ReadFull(f, buf[:])
@@ -85,3 +112,38 @@ func (r *Resource) Open() (reader ReadSeekCloser, contentType string, err error)
 
 	return f, ct, err
 }
+
+// WriteTo writes the resource's data to w. Deferred pages are generated
+// without storing the result in the resource. WriteTo implements
+// io.WriterTo.
+func (r *Resource) WriteTo(w io.Writer) (int64, error) {
+	if r.Data == nil && r.render == nil {
+		f, err := os.Open(r.FilePath)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		return io.Copy(w, f)
+	}
+	data, err := r.data()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+func (r *Resource) data() ([]byte, error) {
+	if r.Data != nil {
+		return r.Data, nil
+	}
+	r.renderMu.Lock()
+	defer r.renderMu.Unlock()
+	return r.render()
+}
+
+// isTextHTML returns whether the MIME type ct is text/html.
+func isTextHTML(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	return err == nil && mt == "text/html"
+}
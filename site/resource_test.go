@@ -0,0 +1,61 @@
+package site
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func visitData(t *testing.T, options ...Option) map[string]string {
+	t.Helper()
+	data := make(map[string]string)
+	err := Visit("testdata/canonical", ioutil.Discard, func(r *Resource) error {
+		var buf bytes.Buffer
+		if _, err := r.WriteTo(&buf); err != nil {
+			return err
+		}
+		data[r.Path] = buf.String()
+		return nil
+	}, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDeferredPages(t *testing.T) {
+	want := visitData(t)
+	got := visitData(t, WithDeferredPages())
+	if len(want) == 0 {
+		t.Fatal("no resources")
+	}
+	for p, w := range want {
+		if got[p] != w {
+			t.Errorf("%s: got %q, want %q", p, got[p], w)
+		}
+	}
+}
+
+func TestSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	want := visitData(t)
+	got := visitData(t, WithSpill(dir, 0))
+	for p, w := range want {
+		if got[p] != w {
+			t.Errorf("%s: got %q, want %q", p, got[p], w)
+		}
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Error("no spill files")
+	}
+}
@@ -1,9 +1,16 @@
 package scratch
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
 
+// Scratch is a key-value store for use by templates. It is safe to call
+// Scratch methods concurrently.
 type Scratch struct {
-	m map[string]interface{}
+	mu sync.Mutex
+	m  map[string]interface{}
 }
 
 func New() *Scratch {
@@ -12,6 +19,8 @@ func New() *Scratch {
 
 // Set sets the value for a key.
 func (d *Scratch) Set(key string, value interface{}) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	d.m[key] = value
 	return ""
 }
@@ -19,17 +28,78 @@ func (d *Scratch) Set(key string, value interface{}) string {
 // Get gets the value for a key. Get returns nil if the there is no value for
 // the key.
 func (d *Scratch) Get(key string) interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	return d.m[key]
 }
 
+// GetDefault gets the value for a key. GetDefault returns def if there is no
+// value for the key.
+func (d *Scratch) GetDefault(key string, def interface{}) interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.m[key]
+	if !ok {
+		return def
+	}
+	return v
+}
+
+// GetString gets the string value for a key. GetString returns "" if there is
+// no value for the key. The function fails if the value is not a string.
+func (d *Scratch) GetString(key string) (string, error) {
+	v := d.Get(key)
+	if v == nil {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("value for %q is not a string, value is %T", key, v)
+	}
+	return s, nil
+}
+
+// GetInt gets the int value for a key. GetInt returns 0 if there is no value
+// for the key. The function fails if the value is not an int.
+func (d *Scratch) GetInt(key string) (int, error) {
+	v := d.Get(key)
+	if v == nil {
+		return 0, nil
+	}
+	n, ok := v.(int)
+	if !ok {
+		return 0, fmt.Errorf("value for %q is not an int, value is %T", key, v)
+	}
+	return n, nil
+}
+
+// GetSlice gets the slice value for a key. GetSlice returns nil if there is
+// no value for the key. The function fails if the value is not a slice of
+// interface{}.
+func (d *Scratch) GetSlice(key string) ([]interface{}, error) {
+	v := d.Get(key)
+	if v == nil {
+		return nil, nil
+	}
+	s, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value for %q is not a slice, value is %T", key, v)
+	}
+	return s, nil
+}
+
 // Has returns whether there is a value for key.
 func (d *Scratch) Has(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	_, ok := d.m[key]
 	return ok
 }
 
 // Delete deletes the value for key.
 func (d *Scratch) Delete(key string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	delete(d.m, key)
 	return ""
 }
@@ -37,6 +107,8 @@ func (d *Scratch) Delete(key string) string {
 // Append appends the value to the slice for key. The function fails if the
 // current value for key is not a slice of interface{}.
 func (d *Scratch) Append(key string, value interface{}) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	v, ok := d.m[key]
 	if !ok {
 		d.m[key] = []interface{}{value}
@@ -49,3 +121,67 @@ func (d *Scratch) Append(key string, value interface{}) (string, error) {
 	d.m[key] = append(s, value)
 	return "", nil
 }
+
+// Add adds delta to the number for key and returns the empty string. If
+// there is no value for key, the value is set to delta. The function fails
+// if the current value for key is not an int.
+func (d *Scratch) Add(key string, delta int) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.m[key]
+	if !ok {
+		d.m[key] = delta
+		return "", nil
+	}
+	n, ok := v.(int)
+	if !ok {
+		return "", fmt.Errorf("add to value that is not an int, value is %T", v)
+	}
+	d.m[key] = n + delta
+	return "", nil
+}
+
+// SetInMap sets the value for key in the map for mapKey. The map is created if
+// it does not exist. The function fails if the current value for mapKey is not
+// a map.
+func (d *Scratch) SetInMap(mapKey string, key string, value interface{}) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.m[mapKey]
+	if !ok {
+		d.m[mapKey] = map[string]interface{}{key: value}
+		return "", nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("set in value that is not a map, value is %T", v)
+	}
+	m[key] = value
+	return "", nil
+}
+
+// GetSortedMapValues returns the values in the map for mapKey sorted by key.
+// GetSortedMapValues returns nil if there is no value for mapKey. The
+// function fails if the value for mapKey is not a map.
+func (d *Scratch) GetSortedMapValues(mapKey string) ([]interface{}, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.m[mapKey]
+	if !ok {
+		return nil, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value for %q is not a map, value is %T", mapKey, v)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]interface{}, len(keys))
+	for i, k := range keys {
+		values[i] = m[k]
+	}
+	return values, nil
+}
@@ -0,0 +1,93 @@
+package scratch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetDefault(t *testing.T) {
+	d := New()
+	d.Set("a", 1)
+	d.Set("b", nil)
+	for _, tt := range []struct {
+		key  string
+		want interface{}
+	}{
+		{"a", 1},
+		{"b", nil},
+		{"c", "default"},
+	} {
+		if got := d.GetDefault(tt.key, "default"); got != tt.want {
+			t.Errorf("GetDefault(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+var typedGetterTests = []struct {
+	method string
+	key    string
+	want   interface{}
+	err    bool
+}{
+	{"GetString", "s", "x", false},
+	{"GetString", "n", "", true},
+	{"GetString", "missing", "", false},
+	{"GetInt", "n", 2, false},
+	{"GetInt", "s", 0, true},
+	{"GetInt", "missing", 0, false},
+	{"GetSlice", "l", []interface{}{"a", 1}, false},
+	{"GetSlice", "s", []interface{}(nil), true},
+	{"GetSlice", "missing", []interface{}(nil), false},
+}
+
+func TestTypedGetters(t *testing.T) {
+	d := New()
+	d.Set("s", "x")
+	d.Set("n", 2)
+	d.Set("l", []interface{}{"a", 1})
+	for _, tt := range typedGetterTests {
+		var got interface{}
+		var err error
+		switch tt.method {
+		case "GetString":
+			got, err = d.GetString(tt.key)
+		case "GetInt":
+			got, err = d.GetInt(tt.key)
+		case "GetSlice":
+			got, err = d.GetSlice(tt.key)
+		}
+		if (err != nil) != tt.err {
+			t.Errorf("%s(%q) returned error %v, want error %v", tt.method, tt.key, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s(%q) = %#v, want %#v", tt.method, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMap(t *testing.T) {
+	d := New()
+	for _, kv := range [][2]string{{"b", "B"}, {"a", "A"}, {"c", "C"}, {"a", "A2"}} {
+		if _, err := d.SetInMap("m", kv[0], kv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := d.GetSortedMapValues("m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{"A2", "B", "C"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSortedMapValues() = %v, want %v", got, want)
+	}
+	if got, err := d.GetSortedMapValues("missing"); got != nil || err != nil {
+		t.Errorf("GetSortedMapValues(missing) = %v, %v, want nil, nil", got, err)
+	}
+	d.Set("s", "x")
+	if _, err := d.SetInMap("s", "a", 1); err == nil {
+		t.Error("SetInMap on string value did not return error")
+	}
+	if _, err := d.GetSortedMapValues("s"); err == nil {
+		t.Error("GetSortedMapValues on string value did not return error")
+	}
+}
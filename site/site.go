@@ -21,10 +21,15 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/garyburd/staticsite/common"
+	"github.com/garyburd/staticsite/site/html"
+	"github.com/garyburd/staticsite/site/scratch"
 	"github.com/garyburd/staticsite/site/template"
 )
 
@@ -32,6 +37,60 @@ type site struct {
 	// File system directory for the site.
 	dir string
 
+	// Site configuration.
+	config *config
+
+	// Name of the environment. See WithEnv.
+	env string
+
+	// Execute pages when opened. See WithDeferredPages.
+	deferPages bool
+
+	// Write page data larger than spillThreshold to files in spillDir. See
+	// WithSpill.
+	spillDir       string
+	spillThreshold int
+
+	// Time spent in each phase. See WithTimings.
+	timings *Timings
+
+	// Stop the walk after this many errors. See WithMaxErrors.
+	maxErrors int
+
+	// Run the pre-build and post-build hooks. See WithHooks.
+	hooks bool
+
+	// Called after the pre-build hooks run. See WithBuildStart.
+	buildStart func()
+
+	// Key is text of reported warnings. The warnings are written to errOut
+	// at the end of the walk. Protected by stateMu.
+	warnings         map[string]struct{}
+	warningsWritten  bool
+	warningsAsErrors bool
+
+	// Files generated by template functions. The key is the path on the
+	// site. Protected by stateMu.
+	generated map[string]string
+
+	// Pages included in the sitemap. Protected by stateMu.
+	sitemapPages []*Page
+
+	// Static files and references from pages for the unused file check.
+	// See WithUnusedWarnings. Protected by stateMu.
+	unusedWarnings bool
+	staticFiles    map[string]string
+	references     map[string]struct{}
+
+	// Runs the static directory walk concurrently with the page walk.
+	walker *walker
+
+	// Rules from the headers file.
+	headerRules []*headerRule
+
+	// Replacer for the replacements file or nil if there are no replacements.
+	replacer *html.WordReplacer
+
 	// Template loader.
 	loader *template.Loader
 
@@ -41,35 +100,225 @@ type site struct {
 	// Destination for error messages.
 	errOut io.Writer
 
+	// Protects visitFn calls and reportedErrors. The static and page walks
+	// run concurrently.
+	visitMu sync.Mutex
+
+	// Protects state updated while rendering pages. Deferred pages are
+	// rendered by visitFn while visitMu is held.
+	stateMu sync.Mutex
+
 	// Visit function for walk.
 	visitFn func(*Resource) error // Visit function for walk.
 
+	// Scratch data with site scope.
+	scratch *scratch.Scratch
+
 	// Previously loaded pages.
 	pagesMu sync.RWMutex
 	pages   map[string]*Page
 
+	// Page file for each page path. Used to detect pages with the same
+	// path. Protected by pagesMu.
+	pagePaths map[string]string
+
 	fileHashesMu sync.Mutex
 	fileHashes   map[string]string
+
+	// Bundles created by static.Bundle.
+	bundlesMu sync.Mutex
+	bundles   map[string]*bundleEntry
+
+	// Average colors of images. The key is the file path.
+	imageColorsMu sync.Mutex
+	imageColors   map[string]string
+
+	// Remote resources fetched by templates.
+	remoteMu    sync.Mutex
+	remoteCache map[string]*remoteCacheEntry
+
+	// Output of commands run by templates.
+	execMu    sync.Mutex
+	execCache map[string]*execCacheEntry
+
+	// Modification times from git history.
+	gitOnce     sync.Once
+	gitModTimes map[string]time.Time
+	gitErr      error
+
+	// References for cite actions.
+	refsOnce sync.Once
+	refs     map[string]*Reference
+	refsErr  error
+
+	// Files used to render each page. The key is the page path.
+	depsMu sync.Mutex
+	deps   map[string]map[string]struct{}
 }
 
-func newSite(dir string, errOut io.Writer, visitFn func(*Resource) error) *site {
+// Option specifies an option for Visit.
+type Option func(*options)
+
+type options struct {
+	funcs            map[string]interface{}
+	deferPages       bool
+	spillDir         string
+	spillThreshold   int
+	env              string
+	timings          *Timings
+	maxErrors        int
+	warningsAsErrors bool
+	unusedWarnings   bool
+	hooks            bool
+	buildStart       func()
+}
+
+// WithFuncs returns an option that adds funcs to the template functions
+// available to layouts. The functions in funcs override built-in functions
+// with the same name. Multiple WithFuncs options are merged.
+func WithFuncs(funcs map[string]interface{}) Option {
+	return func(o *options) {
+		if o.funcs == nil {
+			o.funcs = make(map[string]interface{})
+		}
+		for k, v := range funcs {
+			o.funcs[k] = v
+		}
+	}
+}
+
+// WithDeferredPages returns an option that defers execution of pages until
+// the page resource is opened or written. The generated data is not stored
+// in Resource.Data. The resource Size, Header and Dependencies fields are
+// set when the page is executed. Errors executing the page are returned
+// from Open and WriteTo instead of reported by Visit.
+//
+// Use this option to reduce memory when the visit function writes each
+// resource once.
+func WithDeferredPages() Option {
+	return func(o *options) {
+		o.deferPages = true
+	}
+}
+
+// WithSpill returns an option that writes page data larger than threshold
+// bytes to files in directory dir. The resource FilePath field is set to the
+// file and the Data field is nil. Use this option to reduce memory when the
+// visit function holds all resources. The caller is responsible for removing
+// the directory when the resources are no longer used.
+func WithSpill(dir string, threshold int) Option {
+	return func(o *options) {
+		o.spillDir = dir
+		o.spillThreshold = threshold
+	}
+}
+
+// WithMaxErrors returns an option that stops generating the site after n
+// errors are reported. Errors in pages do not stop the walk by default so
+// that all errors are reported in one build.
+func WithMaxErrors(n int) Option {
+	return func(o *options) {
+		o.maxErrors = n
+	}
+}
+
+// WithBuildStart returns an option that calls fn after the pre-build hooks
+// run and before the site files are read. Use this option to record the
+// state of the files used in the build without the files written by the
+// hooks.
+func WithBuildStart(fn func()) Option {
+	return func(o *options) {
+		o.buildStart = fn
+	}
+}
+
+// WithEnv returns an option that sets the environment name. The fields in
+// the configuration file config/site.<env>.json override the fields in
+// config/site.json. Templates access the name using site.Env.
+func WithEnv(env string) Option {
+	return func(o *options) {
+		o.env = env
+	}
+}
+
+func newSite(dir string, errOut io.Writer, visitFn func(*Resource) error, opts ...Option) (*site, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	if dir == "" {
 		dir = "."
 	}
 	s := &site{
-		dir:            filepath.Clean(dir),
-		visitFn:        visitFn,
-		errOut:         errOut,
-		reportedErrors: make(map[string]struct{}),
-		pages:          make(map[string]*Page),
-		fileHashes:     make(map[string]string),
+		dir:              filepath.Clean(dir),
+		visitFn:          visitFn,
+		deferPages:       o.deferPages,
+		spillDir:         o.spillDir,
+		spillThreshold:   o.spillThreshold,
+		timings:          o.timings,
+		maxErrors:        o.maxErrors,
+		buildStart:       o.buildStart,
+		warnings:         make(map[string]struct{}),
+		warningsAsErrors: o.warningsAsErrors,
+		unusedWarnings:   o.unusedWarnings && !o.deferPages,
+		hooks:            o.hooks,
+		staticFiles:      make(map[string]string),
+		generated:        make(map[string]string),
+		references:       make(map[string]struct{}),
+		env:              o.env,
+		errOut:           errOut,
+		reportedErrors:   make(map[string]struct{}),
+		scratch:          scratch.New(),
+		pages:            make(map[string]*Page),
+		pagePaths:        make(map[string]string),
+		fileHashes:       make(map[string]string),
+		imageColors:      make(map[string]string),
+		bundles:          make(map[string]*bundleEntry),
+		remoteCache:      make(map[string]*remoteCacheEntry),
+		execCache:        make(map[string]*execCacheEntry),
+		deps:             make(map[string]map[string]struct{}),
+		walker:           newWalker(runtime.NumCPU()),
+	}
+	if s.timings == nil {
+		s.timings = new(Timings)
 	}
 	var err error
-	s.loader, err = template.NewLoader(filepath.Join(s.dir, common.LayoutDir), s.templateFuncs())
+	s.config, err = readConfig(s.dir, o.env)
+	if err != nil {
+		return nil, err
+	}
+	s.headerRules, err = readHeaderRules(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	s.replacer, err = readReplacements(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var loaderOptions []template.Option
+	if s.config.ExtendedFuncs {
+		loaderOptions = append(loaderOptions, template.WithExtendedFuncs())
+	}
+	if s.config.Strict {
+		loaderOptions = append(loaderOptions, template.WithStrict())
+	}
+	if s.config.Theme != "" {
+		fdir := s.themeDir(common.LayoutDir)
+		if _, err := os.Stat(filepath.Dir(fdir)); err != nil {
+			return nil, fmt.Errorf("theme %s: %w", s.config.Theme, err)
+		}
+		loaderOptions = append(loaderOptions, template.WithFallbackDirs(fdir))
+	}
+	funcs := s.templateFuncs()
+	for k, v := range o.funcs {
+		funcs[k] = v
+	}
+	s.loader, err = template.NewLoader(filepath.Join(s.dir, common.LayoutDir), funcs, loaderOptions...)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return s
+	return s, nil
 }
 
 func (s *site) addPage(queryPath string, p *Page) {
@@ -78,6 +327,18 @@ func (s *site) addPage(queryPath string, p *Page) {
 	s.pagesMu.Unlock()
 }
 
+// claimPagePath records that page file fpath is rendered to path upath. An
+// error is returned if another page is rendered to the same path.
+func (s *site) claimPagePath(upath string, fpath string) error {
+	s.pagesMu.Lock()
+	defer s.pagesMu.Unlock()
+	if other, ok := s.pagePaths[upath]; ok && other != fpath {
+		return fmt.Errorf("%s: page path %s is also used by %s", fpath, upath, other)
+	}
+	s.pagePaths[upath] = fpath
+	return nil
+}
+
 func (s *site) getPage(upath string) *Page {
 	s.pagesMu.RLock()
 	p := s.pages[upath]
@@ -91,7 +352,7 @@ func (s *site) globPages(upattern string) ([]*Page, error) {
 
 	var pages []*Page
 	for upath, page := range s.pages {
-		matched, err := path.Match(upattern, upath)
+		matched, err := matchPath(upattern, upath)
 		if err != nil {
 			return nil, err
 		}
@@ -100,9 +361,126 @@ func (s *site) globPages(upattern string) ([]*Page, error) {
 		}
 		pages = append(pages, page)
 	}
+	// Sort by path so that query results do not depend on map iteration
+	// order.
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Path < pages[j].Path })
 	return pages, nil
 }
 
+// findPage returns the page with the given path. Unlike getPage, findPage
+// finds pages by the path set with the set action.
+func (s *site) findPage(upath string) *Page {
+	s.pagesMu.RLock()
+	defer s.pagesMu.RUnlock()
+	for _, p := range s.pages {
+		if p.Path == upath {
+			return p
+		}
+	}
+	return nil
+}
+
+// sectionPages returns the pages in directory dir, excluding the directory's
+// index page.
+func (s *site) sectionPages(dir string) []*Page {
+	s.pagesMu.RLock()
+	defer s.pagesMu.RUnlock()
+	var pages []*Page
+	for _, p := range s.pages {
+		if p.dir == dir && !p.isIndex {
+			pages = append(pages, p)
+		}
+	}
+	return pages
+}
+
+// matchPath reports whether name matches the shell pattern. In addition to
+// the syntax supported by path.Match, the pattern element ** matches zero or
+// more path elements.
+func matchPath(pattern, name string) (bool, error) {
+	if !strings.Contains(pattern, "**") {
+		return path.Match(pattern, name)
+	}
+	return matchElements(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElements(pattern, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				matched, err := matchElements(pattern[1:], name[i:])
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+			return false, nil
+		}
+		if len(name) == 0 {
+			return false, nil
+		}
+		matched, err := path.Match(pattern[0], name[0])
+		if err != nil || !matched {
+			return false, err
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0, nil
+}
+
+// addDependency records that the page at upage depends on the files fpaths.
+func (s *site) addDependency(upage string, fpaths ...string) {
+	s.depsMu.Lock()
+	defer s.depsMu.Unlock()
+	m := s.deps[upage]
+	if m == nil {
+		m = make(map[string]struct{})
+		s.deps[upage] = m
+	}
+	for _, fpath := range fpaths {
+		m[fpath] = struct{}{}
+	}
+}
+
+// addPageDependency records that the page at upage depends on pages.
+func (s *site) addPageDependency(upage string, pages ...*Page) {
+	fpaths := make([]string, len(pages))
+	for i, p := range pages {
+		fpaths[i] = p.resource.FilePath
+	}
+	s.addDependency(upage, fpaths...)
+}
+
+// dependencies returns the sorted list of files that the page at upage
+// depends on.
+func (s *site) dependencies(upage string) []string {
+	s.depsMu.Lock()
+	defer s.depsMu.Unlock()
+	fpaths := make([]string, 0, len(s.deps[upage]))
+	for fpath := range s.deps[upage] {
+		fpaths = append(fpaths, fpath)
+	}
+	sort.Strings(fpaths)
+	return fpaths
+}
+
+// staticFile returns the file path for the static file upath and records the
+// file as a dependency of the page at upage. If page bundles are enabled,
+// files not found in the static directory are read from the page directory.
+func (s *site) staticFile(upage string, upath string) string {
+	fpath := s.filePath(common.StaticDir, absPath(upage, upath))
+	if s.config.PageBundles {
+		if _, err := os.Stat(fpath); err != nil {
+			if bpath := s.filePath(common.PageDir, absPath(upage, upath)); !s.isPageExt(bpath) {
+				if _, err := os.Stat(bpath); err == nil {
+					fpath = bpath
+				}
+			}
+		}
+	}
+	s.addDependency(upage, fpath)
+	return fpath
+}
+
 func (s *site) getFileHash(fpath string) (string, error) {
 	s.fileHashesMu.Lock()
 	hash := s.fileHashes[fpath]
@@ -128,33 +506,86 @@ func (s *site) getFileHash(fpath string) (string, error) {
 	return hash, nil
 }
 
-func (s *site) filePath(fdir string, upath string) string {
-	return filepath.Join(s.dir, fdir, filepath.FromSlash(upath))
+// roots returns the file system directories for the site directory fdir in
+// override order. The site's own directory is first, followed by the mounts
+// for the directory in reverse order of configuration and the theme
+// directory.
+func (s *site) roots(fdir string) []string {
+	roots := []string{filepath.Join(s.dir, fdir)}
+	var mounts []string
+	switch fdir {
+	case common.StaticDir:
+		mounts = s.config.StaticMounts
+	case common.PageDir:
+		mounts = s.config.PageMounts
+	}
+	for i := len(mounts) - 1; i >= 0; i-- {
+		roots = append(roots, filepath.Join(s.dir, filepath.FromSlash(mounts[i])))
+	}
+	if s.config.Theme != "" && fdir == common.StaticDir {
+		if _, err := os.Stat(s.themeDir(fdir)); err == nil {
+			roots = append(roots, s.themeDir(fdir))
+		}
+	}
+	return roots
 }
 
-func (s *site) fileGlob(fdir string, upattern string) (fpaths []string, upaths []string, err error) {
-	fpattern := s.filePath(fdir, upattern)
-	if err != nil {
-		return nil, nil, err
-	}
+// themeDir returns the path of the site directory fdir in the theme.
+func (s *site) themeDir(fdir string) string {
+	return filepath.Join(s.dir, common.ThemeDir, s.config.Theme, fdir)
+}
 
-	fpaths, err = filepath.Glob(fpattern)
-	if err != nil {
-		return nil, nil, err
+// filePath returns the file path for upath in the site directory fdir. If
+// fdir has mounts, the path of the first existing file in the overlay is
+// returned.
+func (s *site) filePath(fdir string, upath string) string {
+	roots := s.roots(fdir)
+	if len(roots) > 1 {
+		for _, root := range roots {
+			fpath := filepath.Join(root, filepath.FromSlash(upath))
+			if _, err := os.Stat(fpath); err == nil {
+				return fpath
+			}
+		}
 	}
+	return filepath.Join(roots[0], filepath.FromSlash(upath))
+}
 
-	upaths = make([]string, len(fpaths))
-	base := filepath.Join(s.dir, fdir)
-	for i, fpath := range fpaths {
-		p, err := filepath.Rel(base, fpath)
+func (s *site) fileGlob(fdir string, upattern string) (fpaths []string, upaths []string, err error) {
+	seen := make(map[string]bool)
+	for _, root := range s.roots(fdir) {
+		matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(upattern)))
 		if err != nil {
 			return nil, nil, err
 		}
-		upaths[i] = "/" + filepath.ToSlash(p)
+		for _, fpath := range matches {
+			p, err := filepath.Rel(root, fpath)
+			if err != nil {
+				return nil, nil, err
+			}
+			upath := "/" + filepath.ToSlash(p)
+			if seen[upath] {
+				continue
+			}
+			seen[upath] = true
+			fpaths = append(fpaths, fpath)
+			upaths = append(upaths, upath)
+		}
 	}
+	sort.Sort(byUpath{fpaths, upaths})
 	return fpaths, upaths, nil
 }
 
+// byUpath sorts the result of fileGlob by path.
+type byUpath struct{ fpaths, upaths []string }
+
+func (b byUpath) Len() int           { return len(b.upaths) }
+func (b byUpath) Less(i, j int) bool { return b.upaths[i] < b.upaths[j] }
+func (b byUpath) Swap(i, j int) {
+	b.fpaths[i], b.fpaths[j] = b.fpaths[j], b.fpaths[i]
+	b.upaths[i], b.upaths[j] = b.upaths[j], b.upaths[i]
+}
+
 func shortPath(upage string, p string) string {
 	if upage == "" {
 		return p
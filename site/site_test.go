@@ -0,0 +1,192 @@
+package site
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var matchPathTests = []struct {
+	pattern, name string
+	want          bool
+}{
+	{"/blog/*/", "/blog/post/", true},
+	{"/blog/*/", "/blog/2020/post/", false},
+	{"/blog/**", "/blog/2020/01/post/", true},
+	{"/blog/**", "/blog/", true},
+	{"/blog/**/", "/blog/2020/01/post/", true},
+	{"/blog/**/", "/blog/2020/01/post", false},
+	{"/blog/**/*.index", "/blog/2020/x.index", true},
+	{"/blog/**/*.index", "/blog/x.index", true},
+	{"/blog/**/*.index", "/docs/x.index", false},
+}
+
+// tempSite copies the site in directory src to a temporary directory. Use
+// this function for sites that write to the cache directory. Call the
+// returned function to remove the directory.
+func tempSite(t *testing.T, src string) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = filepath.Walk(src, func(fpath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, fpath)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, rel)
+		if fi.IsDir() {
+			return os.MkdirAll(dst, 0777)
+		}
+		p, err := ioutil.ReadFile(fpath)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(dst, p, 0666)
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestMatchPath(t *testing.T) {
+	for _, tt := range matchPathTests {
+		got, err := matchPath(tt.pattern, tt.name)
+		if err != nil {
+			t.Errorf("matchPath(%q, %q) returned error %v", tt.pattern, tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMounts(t *testing.T) {
+	got := make(map[string]string)
+	err := Visit("testdata/mounts", ioutil.Discard, func(r *Resource) error {
+		if r.Data == nil {
+			p, err := ioutil.ReadFile(r.FilePath)
+			if err != nil {
+				return err
+			}
+			got[r.Path] = string(p)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"/a.txt":     "site\n",
+		"/b.txt":     "two\n",
+		"/sub/c.txt": "one\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTheme(t *testing.T) {
+	got := make(map[string]string)
+	err := Visit("testdata/theme", ioutil.Discard, func(r *Resource) error {
+		p := r.Data
+		if p == nil {
+			var err error
+			p, err = ioutil.ReadFile(r.FilePath)
+			if err != nil {
+				return err
+			}
+		}
+		got[r.Path] = string(p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"/":          "<title>/</title>\n<p>Hello</p>\n",
+		"/style.css": "p{color:red}\n",
+		"/base.css":  "body{}\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGlobPagesOrder(t *testing.T) {
+	s := &site{pages: make(map[string]*Page)}
+	for _, p := range []string{"/b/", "/a/", "/c/", "/a/x/"} {
+		s.pages[p] = &Page{Path: p}
+	}
+	pages, err := s.globPages("/**")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range pages {
+		got = append(got, p.Path)
+	}
+	want := []string{"/a/", "/a/x/", "/b/", "/c/"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBuildStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	os.Setenv("HOOKTEST_OUT", out)
+	defer os.Unsetenv("HOOKTEST_OUT")
+
+	started, visited := false, false
+	err = Visit("testdata/hooks", nil, func(r *Resource) error {
+		if !started {
+			t.Errorf("%s visited before build start", r.Path)
+		}
+		visited = true
+		return nil
+	}, WithBuildStart(func() {
+		if _, err := os.Stat(out); err != nil {
+			t.Errorf("build started before pre-build hook: %v", err)
+		}
+		started = true
+	}), WithHooks(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !started || !visited {
+		t.Errorf("started = %v, visited = %v, want true, true", started, visited)
+	}
+}
+
+func TestHooksDisabled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	os.Setenv("HOOKTEST_OUT", out)
+	defer os.Unsetenv("HOOKTEST_OUT")
+
+	err = Visit("testdata/hooks", nil, func(r *Resource) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("pre-build hook run without WithHooks, stat error %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package site
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+// maxSitemapURLs is the maximum number of URLs in a sitemap file.
+var maxSitemapURLs = 50000
+
+var sitemapChangefreqs = map[string]bool{
+	"always":  true,
+	"hourly":  true,
+	"daily":   true,
+	"weekly":  true,
+	"monthly": true,
+	"yearly":  true,
+	"never":   true,
+}
+
+// recordSitemapPage records page p for the sitemap.
+func (s *site) recordSitemapPage(p *Page) {
+	if !s.config.Sitemap || p.SitemapExclude {
+		return
+	}
+	if ct := pageContentType(p); ct != "" && !isTextHTML(ct) {
+		return
+	}
+	s.stateMu.Lock()
+	s.sitemapPages = append(s.sitemapPages, p)
+	s.stateMu.Unlock()
+}
+
+// visitSitemap generates the sitemap files for the recorded pages.
+func (s *site) visitSitemap() error {
+	if !s.config.Sitemap {
+		return nil
+	}
+	if s.config.BaseURL == "" {
+		return errors.New("BaseURL must be set to generate sitemap")
+	}
+	pages := s.sitemapPages
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Path < pages[j].Path })
+
+	if len(pages) <= maxSitemapURLs {
+		return s.visitSitemapFile("/sitemap.xml", s.urlSet(pages))
+	}
+
+	var index bytes.Buffer
+	index.WriteString(xml.Header)
+	index.WriteString(`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n")
+	for i := 0; i*maxSitemapURLs < len(pages); i++ {
+		end := (i + 1) * maxSitemapURLs
+		if end > len(pages) {
+			end = len(pages)
+		}
+		upath := fmt.Sprintf("/sitemap-%d.xml", i+1)
+		if err := s.visitSitemapFile(upath, s.urlSet(pages[i*maxSitemapURLs:end])); err != nil {
+			return err
+		}
+		index.WriteString("<sitemap><loc>")
+		xml.EscapeText(&index, []byte(s.config.BaseURL+upath))
+		index.WriteString("</loc></sitemap>\n")
+	}
+	index.WriteString("</sitemapindex>\n")
+	return s.visitSitemapFile("/sitemap.xml", index.Bytes())
+}
+
+// urlSet returns a sitemap file for pages.
+func (s *site) urlSet(pages []*Page) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n")
+	for _, p := range pages {
+		buf.WriteString("<url><loc>")
+		xml.EscapeText(&buf, []byte(s.config.BaseURL+p.Path))
+		buf.WriteString("</loc>")
+		lastmod := p.Updated
+		if lastmod.IsZero() {
+			lastmod = p.Created
+		}
+		if !lastmod.IsZero() {
+			fmt.Fprintf(&buf, "<lastmod>%s</lastmod>", lastmod.UTC().Format(time.RFC3339))
+		}
+		if p.SitemapChangefreq != "" {
+			fmt.Fprintf(&buf, "<changefreq>%s</changefreq>", p.SitemapChangefreq)
+		}
+		if p.SitemapPriority != "" {
+			fmt.Fprintf(&buf, "<priority>%s</priority>", p.SitemapPriority)
+		}
+		buf.WriteString("</url>\n")
+	}
+	buf.WriteString("</urlset>\n")
+	return buf.Bytes()
+}
+
+func (s *site) visitSitemapFile(upath string, data []byte) error {
+	r := &Resource{
+		Path:        upath,
+		FilePath:    filepath.Join(s.dir, common.ConfigDir, "site.json"),
+		Data:        data,
+		ContentType: "application/xml",
+	}
+	r.Size = int64(len(r.Data))
+	return s.visitFile(r)
+}
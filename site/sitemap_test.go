@@ -0,0 +1,48 @@
+package site
+
+import (
+	"testing"
+)
+
+func visitSitemap(t *testing.T) map[string]string {
+	t.Helper()
+	got := make(map[string]string)
+	err := Visit("testdata/sitemap", nil, func(r *Resource) error {
+		if r.ContentType == "application/xml" {
+			got[r.Path] = string(r.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestSitemap(t *testing.T) {
+	got := visitSitemap(t)
+	const want = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>https://example.com/</loc><lastmod>2020-01-02T03:04:05Z</lastmod><changefreq>daily</changefreq><priority>1.0</priority></url>
+<url><loc>https://example.com/about/</loc></url>
+</urlset>
+`
+	if len(got) != 1 || got["/sitemap.xml"] != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSitemapIndex(t *testing.T) {
+	defer func(n int) { maxSitemapURLs = n }(maxSitemapURLs)
+	maxSitemapURLs = 1
+	got := visitSitemap(t)
+	const want = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<sitemap><loc>https://example.com/sitemap-1.xml</loc></sitemap>
+<sitemap><loc>https://example.com/sitemap-2.xml</loc></sitemap>
+</sitemapindex>
+`
+	if len(got) != 3 || got["/sitemap.xml"] != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
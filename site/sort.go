@@ -0,0 +1,185 @@
+package site
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortBy returns a copy of slice sorted by the value at fieldPath in each
+// element. The field path is a dot separated list of struct field names and
+// map keys. An empty field path sorts by the element value. The optional
+// order is "asc" (the default) or "desc".
+func (utilFuncs) SortBy(slice interface{}, fieldPath string, order ...string) (interface{}, error) {
+	v := reflect.ValueOf(slice)
+	if v.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("sortBy: expected slice, got %T", slice)
+	}
+
+	desc := false
+	if len(order) > 0 {
+		switch order[0] {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			return nil, fmt.Errorf("sortBy: unknown order %q", order[0])
+		}
+	}
+
+	var names []string
+	if fieldPath != "" {
+		names = strings.Split(fieldPath, ".")
+	}
+
+	keys := make([]reflect.Value, v.Len())
+	for i := range keys {
+		k, err := fieldValue(v.Index(i), names)
+		if err != nil {
+			return nil, fmt.Errorf("sortBy: %w", err)
+		}
+		keys[i] = k
+	}
+
+	result := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(result, v)
+	swap := reflect.Swapper(result.Interface())
+
+	var err error
+	sort.Stable(&valueSorter{
+		keys: keys,
+		swap: swap,
+		less: func(a, b reflect.Value) bool {
+			c, cerr := compareValues(a, b)
+			if cerr != nil && err == nil {
+				err = cerr
+			}
+			if desc {
+				return c > 0
+			}
+			return c < 0
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sortBy: %w", err)
+	}
+	return result.Interface(), nil
+}
+
+type valueSorter struct {
+	keys []reflect.Value
+	swap func(i, j int)
+	less func(a, b reflect.Value) bool
+}
+
+func (vs *valueSorter) Len() int           { return len(vs.keys) }
+func (vs *valueSorter) Less(i, j int) bool { return vs.less(vs.keys[i], vs.keys[j]) }
+func (vs *valueSorter) Swap(i, j int) {
+	vs.keys[i], vs.keys[j] = vs.keys[j], vs.keys[i]
+	vs.swap(i, j)
+}
+
+// fieldValue returns the value at the path names in v.
+func fieldValue(v reflect.Value, names []string) (reflect.Value, error) {
+	for _, name := range names {
+		v = indirect(v)
+		switch v.Kind() {
+		case reflect.Struct:
+			f := v.FieldByName(name)
+			if !f.IsValid() {
+				return reflect.Value{}, fmt.Errorf("field %q not found in %s", name, v.Type())
+			}
+			v = f
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, fmt.Errorf("map key type %s is not string", v.Type().Key())
+			}
+			v = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		case reflect.Invalid:
+			return reflect.Value{}, nil
+		default:
+			return reflect.Value{}, fmt.Errorf("cannot get %q from %s", name, v.Type())
+		}
+	}
+	return indirect(v), nil
+}
+
+// indirect dereferences pointers and interfaces in v.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// compareValues returns -1, 0 or 1 depending on whether a is less than, equal
+// to or greater than b. Missing values sort before all other values.
+func compareValues(a, b reflect.Value) (int, error) {
+	switch {
+	case !a.IsValid() && !b.IsValid():
+		return 0, nil
+	case !a.IsValid():
+		return -1, nil
+	case !b.IsValid():
+		return 1, nil
+	}
+
+	switch {
+	case a.Type() == timeType && b.Type() == timeType:
+		ta, tb := a.Interface().(time.Time), b.Interface().(time.Time)
+		switch {
+		case ta.Before(tb):
+			return -1, nil
+		case ta.After(tb):
+			return 1, nil
+		}
+		return 0, nil
+	case isNumber(a.Kind()) && isNumber(b.Kind()):
+		fa, fb := toFloat(a), toFloat(b)
+		switch {
+		case fa < fb:
+			return -1, nil
+		case fa > fb:
+			return 1, nil
+		}
+		return 0, nil
+	case a.Kind() == reflect.String && b.Kind() == reflect.String:
+		return strings.Compare(a.String(), b.String()), nil
+	case a.Kind() == reflect.Bool && b.Kind() == reflect.Bool:
+		switch {
+		case !a.Bool() && b.Bool():
+			return -1, nil
+		case a.Bool() && !b.Bool():
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("cannot compare %s and %s", a.Type(), b.Type())
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
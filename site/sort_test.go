@@ -0,0 +1,45 @@
+package site
+
+import (
+	"reflect"
+	"testing"
+)
+
+type sortItem struct {
+	Name string
+	Meta map[string]interface{}
+}
+
+func TestSortBy(t *testing.T) {
+	items := []*sortItem{
+		{Name: "b", Meta: map[string]interface{}{"n": 2.0}},
+		{Name: "c", Meta: map[string]interface{}{"n": 1}},
+		{Name: "a", Meta: map[string]interface{}{"n": 3}},
+	}
+
+	var uf utilFuncs
+
+	got, err := uf.SortBy(items, "Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []*sortItem{items[2], items[0], items[1]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SortBy(Name) = %v, want %v", got, want)
+	}
+
+	got, err = uf.SortBy(items, "Meta.n", "desc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []*sortItem{items[2], items[0], items[1]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SortBy(Meta.n, desc) = %v, want %v", got, want)
+	}
+
+	got, err = uf.SortBy([]interface{}{3, 1, 2}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SortBy(values) = %v, want %v", got, want)
+	}
+}
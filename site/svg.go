@@ -0,0 +1,80 @@
+package site
+
+import (
+	"fmt"
+	htemplate "html/template"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// svgProlog matches the XML declaration, document type declaration and
+// comments in an SVG file.
+var svgProlog = regexp.MustCompile(`(?s)<\?xml.*?\?>|<!DOCTYPE[^>]*>|<!--.*?-->`)
+
+// InlineSVG returns the contents of the static SVG file upath for inclusion in
+// an HTML page. The XML declaration, document type declaration and comments
+// are removed. The optional arguments are attribute name and value pairs to
+// set on the root svg element. A class attribute value is appended to the
+// element's existing classes.
+//
+//	{{static.InlineSVG .Path "/icons/menu.svg" "class" "icon" "aria-hidden" "true"}}
+func (sf staticFuncs) InlineSVG(upage string, upath string, attrs ...string) (htemplate.HTML, error) {
+	if len(attrs)%2 != 0 {
+		return "", fmt.Errorf("inlineSVG %s: odd number of attribute arguments", upath)
+	}
+	p, err := ioutil.ReadFile(sf.site.staticFile(upage, upath))
+	if err != nil {
+		return "", err
+	}
+	svg, err := inlineSVG(string(p), attrs)
+	if err != nil {
+		return "", fmt.Errorf("inlineSVG %s: %w", upath, err)
+	}
+	return htemplate.HTML(svg), nil
+}
+
+func inlineSVG(svg string, attrs []string) (string, error) {
+	svg = strings.TrimSpace(svgProlog.ReplaceAllString(svg, ""))
+	start := strings.Index(svg, "<svg")
+	if start < 0 {
+		return "", fmt.Errorf("svg element not found")
+	}
+	end := -1
+	var quote byte
+	for i := start; i < len(svg) && end < 0; i++ {
+		switch c := svg[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			end = i
+		}
+	}
+	if end < 0 {
+		return "", fmt.Errorf("svg element not terminated")
+	}
+	tag := strings.TrimRight(svg[start:end], "/ \t\r\n")
+	selfClosing := strings.HasSuffix(svg[start:end], "/")
+	for i := 0; i < len(attrs); i += 2 {
+		key, val := attrs[i], attrEscaper.Replace(attrs[i+1])
+		re := regexp.MustCompile(`\s` + regexp.QuoteMeta(key) + `\s*=\s*("[^"]*"|'[^']*')`)
+		m := re.FindStringSubmatchIndex(tag)
+		switch {
+		case m == nil:
+			tag += " " + key + `="` + val + `"`
+		case key == "class":
+			old := tag[m[2]+1 : m[3]-1]
+			tag = tag[:m[2]] + `"` + strings.TrimSpace(old+" "+val) + `"` + tag[m[3]:]
+		default:
+			tag = tag[:m[2]] + `"` + val + `"` + tag[m[3]:]
+		}
+	}
+	if selfClosing {
+		tag += "/"
+	}
+	return tag + svg[end:], nil
+}
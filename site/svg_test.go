@@ -0,0 +1,41 @@
+package site
+
+import "testing"
+
+var inlineSVGTests = []struct {
+	svg   string
+	attrs []string
+	want  string
+}{
+	{
+		"<?xml version=\"1.0\"?>\n<!DOCTYPE svg PUBLIC \"-//W3C//DTD SVG 1.1//EN\" \"x.dtd\">\n<!-- icon -->\n<svg viewBox=\"0 0 1 1\"><path d=\"M0 0\"/></svg>\n",
+		nil,
+		`<svg viewBox="0 0 1 1"><path d="M0 0"/></svg>`,
+	},
+	{
+		`<svg class="a" viewBox="0 0 1 1"></svg>`,
+		[]string{"class", "icon", "aria-hidden", "true"},
+		`<svg class="a icon" viewBox="0 0 1 1" aria-hidden="true"></svg>`,
+	},
+	{
+		`<svg width='10' title="a>b"/>`,
+		[]string{"width", "20", "role", `x"y`},
+		`<svg width="20" title="a>b" role="x&#34;y"/>`,
+	},
+}
+
+func TestInlineSVG(t *testing.T) {
+	for _, tt := range inlineSVGTests {
+		got, err := inlineSVG(tt.svg, tt.attrs)
+		if err != nil {
+			t.Errorf("inlineSVG(%q, %q) returned error %v", tt.svg, tt.attrs, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("inlineSVG(%q, %q) = %q, want %q", tt.svg, tt.attrs, got, tt.want)
+		}
+	}
+	if _, err := inlineSVG(`<p>`, nil); err == nil {
+		t.Error("expected error for file without svg element")
+	}
+}
@@ -0,0 +1,138 @@
+package site
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/garyburd/staticsite/common"
+)
+
+// taxonomy is the configuration for a taxonomy. A page is generated for each
+// term in the taxonomy at Path/<term>/ using Layout. The Term field of the
+// generated page is the term and the Pages field is the pages with the term
+// sorted by creation time, newest first. Terms with the same slug, such as
+// "Go" and "go", share a page.
+type taxonomy struct {
+	// Path is the path prefix of the term pages. Example: /tags/.
+	Path string
+
+	// Param is the name of the page parameter containing the comma
+	// separated list of terms. If not set, the terms are the page tags.
+	Param string
+
+	// Layout is the layout for the term pages.
+	Layout string
+
+	// FeedLayout is the optional layout for a feed for each term. The feed
+	// is generated at Path/<term>/feed.xml.
+	FeedLayout string
+}
+
+// terms returns the terms for page p.
+func (t *taxonomy) terms(p *Page) []string {
+	if t.Param == "" {
+		return p.Tags
+	}
+	var terms []string
+	for _, term := range strings.Split(p.Params[t.Param], ",") {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// visitTaxonomies generates and visits the term pages for the taxonomies in
+// the site configuration.
+func (s *site) visitTaxonomies() error {
+	for _, t := range s.config.Taxonomies {
+		s.pagesMu.RLock()
+		termPages := make(map[string][]*Page)
+		for _, p := range s.pages {
+			for _, term := range t.terms(p) {
+				termPages[term] = append(termPages[term], p)
+			}
+		}
+		s.pagesMu.RUnlock()
+
+		terms := make([]string, 0, len(termPages))
+		for term := range termPages {
+			terms = append(terms, term)
+		}
+		sort.Strings(terms)
+
+		// Merge the terms with the same slug. The term for the merged
+		// terms is the first of the terms in sorted order.
+		var slugs []string
+		slugTerms := make(map[string]string)
+		slugPages := make(map[string][]*Page)
+		for _, term := range terms {
+			sortTermPages(termPages[term])
+			slug := common.Slug(term)
+			if slug == "" {
+				err := fmt.Errorf("%s: taxonomy term %q does not contain a letter or digit", termPages[term][0].resource.FilePath, term)
+				if err := s.reportError(err); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, ok := slugTerms[slug]; !ok {
+				slugs = append(slugs, slug)
+				slugTerms[slug] = term
+			}
+			slugPages[slug] = append(slugPages[slug], termPages[term]...)
+		}
+
+		prefix := strings.TrimSuffix(t.Path, "/") + "/"
+		for _, slug := range slugs {
+			term := slugTerms[slug]
+			pages := slugPages[slug]
+			sortTermPages(pages)
+			pages = uniquePages(pages)
+			upath := prefix + slug + "/"
+			if err := s.visitPage(s.newTermPage(upath, t.Layout, term, pages)); err != nil {
+				return err
+			}
+			if t.FeedLayout != "" {
+				if err := s.visitPage(s.newTermPage(upath+"feed.xml", t.FeedLayout, term, pages)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sortTermPages sorts the pages for a term by creation time, newest first.
+func sortTermPages(pages []*Page) {
+	sort.Slice(pages, func(i, j int) bool {
+		if !pages[i].Created.Equal(pages[j].Created) {
+			return pages[i].Created.After(pages[j].Created)
+		}
+		return pages[i].Path < pages[j].Path
+	})
+}
+
+// uniquePages removes adjacent duplicates from sorted pages.
+func uniquePages(pages []*Page) []*Page {
+	result := pages[:0]
+	for i, p := range pages {
+		if i == 0 || p != pages[i-1] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// newTermPage returns a generated page for a taxonomy term.
+func (s *site) newTermPage(upath string, layout string, term string, pages []*Page) *Page {
+	p := s.newGeneratedPage(upath, layout)
+	p.Title = term
+	p.Term = term
+	p.Pages = pages
+	for _, tp := range pages {
+		s.addDependency(upath, tp.resource.FilePath)
+	}
+	return p
+}
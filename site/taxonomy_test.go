@@ -0,0 +1,59 @@
+package site
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTaxonomy(t *testing.T) {
+	var buf bytes.Buffer
+	got := make(map[string]string)
+	err := Visit("testdata/taxonomy", &buf, func(r *Resource) error {
+		got[r.Path] = string(r.Data)
+		return nil
+	}, WithMaxErrors(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+	want := map[string]string{
+		"/robots.txt":            "",
+		"/posts/a/":              "",
+		"/posts/b/":              "",
+		"/tags/go/":              "<h1>Go</h1><a href=/posts/b/>B</a><a href=/posts/a/>A</a>\n",
+		"/tags/go/feed.xml":      "<feed><entry>/posts/b/</entry><entry>/posts/a/</entry></feed>\n",
+		"/tags/web-dev/":         "<h1>Web Dev</h1><a href=/posts/a/>A</a>\n",
+		"/tags/web-dev/feed.xml": "<feed><entry>/posts/a/</entry></feed>\n",
+		"/categories/notes/":     "<h1>notes</h1><a href=/posts/a/>A</a>\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTaxonomyErrors(t *testing.T) {
+	var buf bytes.Buffer
+	got := make(map[string]bool)
+	err := Visit("testdata/taxonomyerrors", &buf, func(r *Resource) error {
+		got[r.Path] = true
+		return nil
+	})
+	if err == nil {
+		t.Error("Visit did not return error")
+	}
+	want := filepath.Join("page", "b.html") + `: taxonomy term "++" does not contain a letter or digit`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("error %q not found in %q", want, buf.String())
+	}
+	if strings.Contains(buf.String(), "C++") {
+		t.Errorf("terms with the same slug reported in %q", buf.String())
+	}
+	if !got["/tags/c/"] || !got["/tags/go/"] {
+		t.Errorf("term pages not visited, got %v", got)
+	}
+}
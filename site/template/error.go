@@ -0,0 +1,52 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	htemplate "html/template"
+	"regexp"
+	"strings"
+)
+
+// execError is an error from executing a template with the location of the
+// error in a template file.
+type execError struct {
+	loc string
+	msg string
+	err error
+}
+
+func (e *execError) Error() string { return e.loc + ": " + e.msg }
+
+func (e *execError) Unwrap() error { return e.err }
+
+// errorLocationPat matches the template file location at the start of
+// errors from the text/template and html/template packages.
+var errorLocationPat = regexp.MustCompile(`^(?:html/)?template: ?(.+?:\d+(?::\d+)?): `)
+
+// ExecError returns an error for err from executing the template loaded from
+// path. The message starts with the location of the error in the template
+// file or in a file imported by the template. The file path for path is used
+// when err does not have a location. References to the internal name of the
+// main template are removed from the message.
+func (l *Loader) ExecError(path string, err error) error {
+	msg := err.Error()
+	loc := l.filePath(path)
+	var he *htemplate.Error
+	if m := errorLocationPat.FindStringSubmatchIndex(msg); m != nil {
+		loc = msg[m[2]:m[3]]
+		msg = msg[m[1]:]
+	} else if errors.As(err, &he) && he.Node == nil {
+		// Escaper errors without a node have the template name and line.
+		if he.Name != mainName {
+			loc = fmt.Sprintf("%s: template %q", loc, he.Name)
+		} else if he.Line > 0 {
+			loc = fmt.Sprintf("%s:%d", loc, he.Line)
+		}
+		msg = he.Description
+	} else {
+		msg = strings.TrimPrefix(strings.TrimPrefix(msg, "html/"), "template: ")
+	}
+	msg = strings.Replace(msg, `executing "`+mainName+`" `, "", 1)
+	return &execError{loc: loc, msg: msg, err: err}
+}
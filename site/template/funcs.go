@@ -0,0 +1,174 @@
+package template
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// extendedFuncs is a curated set of general purpose template functions. The
+// names and semantics follow the Sprig library where practical.
+var extendedFuncs = map[string]interface{}{
+	"default":  defaultValue,
+	"empty":    empty,
+	"coalesce": coalesce,
+	"ternary":  ternary,
+
+	"add": func(a, b int) int { return a + b },
+	"sub": func(a, b int) int { return a - b },
+	"mul": func(a, b int) int { return a * b },
+	"div": func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("div: division by zero")
+		}
+		return a / b, nil
+	},
+	"mod": func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("mod: division by zero")
+		}
+		return a % b, nil
+	},
+	"max": func(a int, rest ...int) int {
+		for _, b := range rest {
+			if b > a {
+				a = b
+			}
+		}
+		return a
+	},
+	"min": func(a int, rest ...int) int {
+		for _, b := range rest {
+			if b < a {
+				a = b
+			}
+		}
+		return a
+	},
+
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	"trim":      strings.TrimSpace,
+	"contains":  func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix": func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix": func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"replace":   func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"split":     func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":      func(sep string, elems []string) string { return strings.Join(elems, sep) },
+
+	"list":   func(values ...interface{}) []interface{} { return values },
+	"dict":   dict,
+	"hasKey": func(m map[string]interface{}, key string) bool { _, ok := m[key]; return ok },
+	"keys":   keys,
+	"first":  first,
+	"last":   last,
+
+	"toJSON": toJSON,
+}
+
+// defaultValue returns value if value is not empty, otherwise def. Use in a
+// pipeline: {{.Title | default "Untitled"}}.
+func defaultValue(def interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 || empty(value[0]) {
+		return def
+	}
+	return value[0]
+}
+
+// empty returns whether v is the zero value for its type or an empty
+// collection.
+func empty(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	}
+	return reflect.DeepEqual(v, reflect.Zero(rv.Type()).Interface())
+}
+
+// coalesce returns the first argument that is not empty.
+func coalesce(values ...interface{}) interface{} {
+	for _, v := range values {
+		if !empty(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+// ternary returns a if cond is true, otherwise b. Use in a pipeline:
+// {{.Draft | ternary "draft" "published"}}.
+func ternary(a, b interface{}, cond bool) interface{} {
+	if cond {
+		return a
+	}
+	return b
+}
+
+func dict(values ...interface{}) (map[string]interface{}, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("dict: must have even number of arguments")
+	}
+	m := make(map[string]interface{}, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: key %v is not a string", values[i])
+		}
+		m[key] = values[i+1]
+	}
+	return m, nil
+}
+
+// keys returns the sorted keys of m.
+func keys(m map[string]interface{}) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func first(list interface{}) (interface{}, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("first: expected slice, got %T", list)
+	}
+	if v.Len() == 0 {
+		return nil, nil
+	}
+	return v.Index(0).Interface(), nil
+}
+
+func last(list interface{}) (interface{}, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("last: expected slice, got %T", list)
+	}
+	if v.Len() == 0 {
+		return nil, nil
+	}
+	return v.Index(v.Len() - 1).Interface(), nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	p, err := json.Marshal(v)
+	return string(p), err
+}
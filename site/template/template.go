@@ -1,13 +1,18 @@
 package template
 
 import (
+	"bytes"
 	"fmt"
 	htemplate "html/template"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"regexp"
 	"sync"
 	ttemplate "text/template"
 	"text/template/parse"
+
+	"github.com/garyburd/staticsite/common"
 )
 
 const (
@@ -15,40 +20,102 @@ const (
 	metaName = "_"
 )
 
-// Loader loads HTML templates from files on disk.
+// Loader loads HTML and text templates from files on disk.
 type Loader struct {
-	dir      string
-	funcs    map[string]interface{}
-	template *htemplate.Template
+	dir          string
+	fallbackDirs []string
+	funcs        map[string]interface{}
+	template     *htemplate.Template
+	textTemplate *ttemplate.Template
 
 	treesMu    sync.Mutex
 	treesCache map[string]*treesCacheEntry
 
-	templateMu    sync.Mutex
-	templateCache map[string]*templateCacheEntry
+	templateMu        sync.Mutex
+	templateCache     map[string]*templateCacheEntry
+	textTemplateCache map[string]*templateCacheEntry
 }
 
 type treesCacheEntry struct {
 	once  sync.Once
 	trees map[string]*parse.Tree
+	deps  []string
 	err   error
 }
 
 type templateCacheEntry struct {
-	once     sync.Once
-	template *htemplate.Template
-	err      error
+	once         sync.Once
+	template     *htemplate.Template
+	textTemplate *ttemplate.Template
+	deps         []string
+	err          error
+}
+
+// Option specifies an option for a loader.
+type Option func(*loaderOptions)
+
+type loaderOptions struct {
+	extendedFuncs bool
+	strict        bool
+	fallbackDirs  []string
+}
+
+// WithStrict returns an option that makes template execution fail when a
+// template references a missing map key. By default, a missing map key prints
+// "<no value>".
+func WithStrict() Option {
+	return func(o *loaderOptions) { o.strict = true }
+}
+
+// WithFallbackDirs returns an option that loads files not found in the
+// loader's directory from the directories dirs. The directories are searched
+// in order.
+func WithFallbackDirs(dirs ...string) Option {
+	return func(o *loaderOptions) { o.fallbackDirs = append(o.fallbackDirs, dirs...) }
+}
+
+// WithExtendedFuncs returns an option that adds a curated set of general
+// purpose functions to the loader. The functions include default, empty,
+// coalesce, ternary, add, sub, mul, div, mod, max, min, upper, lower, trim,
+// contains, hasPrefix, hasSuffix, replace, split, join, list, dict, hasKey,
+// keys, first, last and toJSON. Functions passed to NewLoader override
+// functions in the extended set.
+func WithExtendedFuncs() Option {
+	return func(o *loaderOptions) { o.extendedFuncs = true }
 }
 
 // NewLoader creates a template loader.  The loader loads files from directory
 // dir using template functions funcs.
-func NewLoader(dir string, funcs map[string]interface{}) (*Loader, error) {
+func NewLoader(dir string, funcs map[string]interface{}, options ...Option) (*Loader, error) {
+	var o loaderOptions
+	for _, option := range options {
+		option(&o)
+	}
+
+	if o.extendedFuncs {
+		m := make(map[string]interface{})
+		for k, v := range extendedFuncs {
+			m[k] = v
+		}
+		for k, v := range funcs {
+			m[k] = v
+		}
+		funcs = m
+	}
+
 	l := Loader{
-		dir:           dir,
-		funcs:         make(map[string]interface{}),
-		treesCache:    make(map[string]*treesCacheEntry),
-		templateCache: make(map[string]*templateCacheEntry),
-		template:      htemplate.New(mainName).Funcs(funcs),
+		dir:               dir,
+		fallbackDirs:      o.fallbackDirs,
+		funcs:             make(map[string]interface{}),
+		treesCache:        make(map[string]*treesCacheEntry),
+		templateCache:     make(map[string]*templateCacheEntry),
+		textTemplateCache: make(map[string]*templateCacheEntry),
+		template:          htemplate.New(mainName).Funcs(funcs),
+		textTemplate:      ttemplate.New(mainName).Funcs(funcs),
+	}
+	if o.strict {
+		l.template.Option("missingkey=error")
+		l.textTemplate.Option("missingkey=error")
 	}
 
 	// Create funcs for tree parse.
@@ -92,24 +159,75 @@ var textTemplateBuiltinFuncs = []string{
 // Load loads the template from path where path is relative to the loader's
 // directory.
 func (l *Loader) Load(path string) (*htemplate.Template, error) {
+	fpath := l.filePath(path)
+	e := l.getTemplateCacheEntry(l.templateCache, fpath)
+	e.once.Do(func() {
+		e.template, e.deps, e.err = l.loadTemplate(fpath)
+	})
+	return e.template, e.err
+}
+
+// LoadText loads the template from path as a text template. Text templates do
+// not escape output for HTML. The path is relative to the loader's directory.
+func (l *Loader) LoadText(path string) (*ttemplate.Template, error) {
+	fpath := l.filePath(path)
+	e := l.getTemplateCacheEntry(l.textTemplateCache, fpath)
+	e.once.Do(func() {
+		e.textTemplate, e.deps, e.err = l.loadTextTemplate(fpath)
+	})
+	return e.textTemplate, e.err
+}
+
+// filePath returns the file path for path. If the file does not exist in the
+// loader's directory, the path in the first fallback directory containing the
+// file is returned.
+func (l *Loader) filePath(path string) string {
 	fpath := filepath.Join(l.dir, filepath.FromSlash(path))
+	if len(l.fallbackDirs) == 0 {
+		return fpath
+	}
+	if _, err := os.Stat(fpath); err == nil {
+		return fpath
+	}
+	for _, dir := range l.fallbackDirs {
+		p := filepath.Join(dir, filepath.FromSlash(path))
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return fpath
+}
 
+func (l *Loader) getTemplateCacheEntry(cache map[string]*templateCacheEntry, fpath string) *templateCacheEntry {
 	l.templateMu.Lock()
-	e := l.templateCache[fpath]
+	defer l.templateMu.Unlock()
+	e := cache[fpath]
 	if e == nil {
 		e = &templateCacheEntry{}
-		l.templateCache[fpath] = e
+		cache[fpath] = e
 	}
-	l.templateMu.Unlock()
-
-	e.once.Do(func() {
-		e.template, e.err = l.loadTemplate(fpath)
-	})
+	return e
+}
 
-	return e.template, e.err
+// Dependencies returns the paths of the files read to load the template at
+// path. The result includes the template file, imported files and included
+// files. Call Dependencies after the template is loaded with Load or
+// LoadText.
+func (l *Loader) Dependencies(path string) []string {
+	fpath := l.filePath(path)
+	l.templateMu.Lock()
+	e := l.templateCache[fpath]
+	if e == nil {
+		e = l.textTemplateCache[fpath]
+	}
+	l.templateMu.Unlock()
+	if e == nil {
+		return nil
+	}
+	return append([]string(nil), e.deps...)
 }
 
-func (l *Loader) loadTemplate(fpath string) (*htemplate.Template, error) {
+func (l *Loader) loadTemplate(fpath string) (*htemplate.Template, []string, error) {
 
 	// Optimize for the case where the trees in fpath are not used in other
 	// templates.
@@ -117,21 +235,37 @@ func (l *Loader) loadTemplate(fpath string) (*htemplate.Template, error) {
 	//  - To allow direct use of the trees in the compiled template, do
 	//    copy imported trees into the trees for this path.
 
-	trees, err := l.loadTrees(fpath, true, map[string]struct{}{})
+	trees, deps, err := l.loadTrees(fpath, true, map[string]struct{}{})
 	if err != nil {
-		return nil, err
+		return nil, deps, err
 	}
 
 	t := htemplate.Must(l.template.Clone())
 	for name, tree := range trees {
 		if _, err := t.AddParseTree(name, tree); err != nil {
-			return nil, err
+			return nil, deps, err
+		}
+	}
+	return t.Lookup(mainName), deps, nil
+}
+
+func (l *Loader) loadTextTemplate(fpath string) (*ttemplate.Template, []string, error) {
+	// See the comments in loadTemplate.
+	trees, deps, err := l.loadTrees(fpath, true, map[string]struct{}{})
+	if err != nil {
+		return nil, deps, err
+	}
+
+	t := ttemplate.Must(l.textTemplate.Clone())
+	for name, tree := range trees {
+		if _, err := t.AddParseTree(name, tree); err != nil {
+			return nil, deps, err
 		}
 	}
-	return t.Lookup(mainName), nil
+	return t.Lookup(mainName), deps, nil
 }
 
-func (l *Loader) getTrees(fpath string, inflight map[string]struct{}) (map[string]*parse.Tree, error) {
+func (l *Loader) getTrees(fpath string, inflight map[string]struct{}) (map[string]*parse.Tree, []string, error) {
 	l.treesMu.Lock()
 	e := l.treesCache[fpath]
 	if e == nil {
@@ -141,26 +275,30 @@ func (l *Loader) getTrees(fpath string, inflight map[string]struct{}) (map[strin
 	l.treesMu.Unlock()
 
 	e.once.Do(func() {
-		e.trees, e.err = l.loadTrees(fpath, false, inflight)
+		e.trees, e.deps, e.err = l.loadTrees(fpath, false, inflight)
 	})
 
-	return e.trees, e.err
+	return e.trees, e.deps, e.err
 }
 
-func (l *Loader) loadTrees(fpath string, copyImports bool, inflight map[string]struct{}) (map[string]*parse.Tree, error) {
+func (l *Loader) loadTrees(fpath string, copyImports bool, inflight map[string]struct{}) (map[string]*parse.Tree, []string, error) {
+	deps := []string{fpath}
+
 	if _, ok := inflight[fpath]; ok {
-		return nil, fmt.Errorf("template import cycle: %s", fpath)
+		return nil, deps, fmt.Errorf("template import cycle: %s", fpath)
 	}
 	inflight[fpath] = struct{}{}
 
 	p, err := ioutil.ReadFile(fpath)
 	if err != nil {
-		return nil, err
+		return nil, deps, err
 	}
+	p = common.NormalizeText(p)
 
-	trees, err := parse.Parse(fpath, string(p), "", "", l.funcs)
+	text, leftDelim, rightDelim := parseDelims(string(p))
+	trees, err := parse.Parse(fpath, text, leftDelim, rightDelim, l.funcs)
 	if err != nil {
-		return nil, err
+		return nil, deps, err
 	}
 
 	main := trees[fpath]
@@ -176,22 +314,43 @@ func (l *Loader) loadTrees(fpath string, copyImports bool, inflight map[string]s
 			loader:      l,
 			inflight:    inflight,
 			copyImports: copyImports,
+			deps:        deps,
 		}
 		t, err := ttemplate.New(metaName).AddParseTree(metaName, tree)
 		if err != nil {
-			return nil, err
+			return nil, deps, err
 		}
 		err = t.Execute(ioutil.Discard, m)
+		deps = m.deps
 		if err != nil {
 			if m.err != nil {
 				// Jump over layers of template execution errors.
 				err = m.err
 			}
-			return nil, err
+			return nil, deps, err
 		}
 	}
 
-	return trees, err
+	return trees, deps, err
+}
+
+var delimsPat = regexp.MustCompile(`^\{\{/\*\s*delims\s+(\S+)\s+(\S+)\s*\*/\}\}`)
+
+// parseDelims returns the template delimiters declared in the first line of
+// text using the directive
+//
+//	{{/* delims [[ ]] */}}
+//
+// The directive is replaced with a comment using the declared delimiters so
+// that line numbers in error messages are unchanged. The empty string is
+// returned for the delimiters when the directive is not present.
+func parseDelims(text string) (string, string, string) {
+	m := delimsPat.FindStringSubmatch(text)
+	if m == nil {
+		return text, "", ""
+	}
+	leftDelim, rightDelim := m[1], m[2]
+	return leftDelim + "/* */ -" + rightDelim + text[len(m[0]):], leftDelim, rightDelim
 }
 
 type meta struct {
@@ -200,6 +359,9 @@ type meta struct {
 	inflight    map[string]struct{}
 	copyImports bool
 
+	// Files read to load the template.
+	deps []string
+
 	err error
 }
 
@@ -207,8 +369,9 @@ type meta struct {
 // The path is relative to the loader's directory. Templates in the current
 // file override imported templates.
 func (m *meta) Import(path string) (string, error) {
-	fpath := filepath.Join(m.loader.dir, filepath.FromSlash(path))
-	trees, err := m.loader.getTrees(fpath, m.inflight)
+	fpath := m.loader.filePath(path)
+	trees, deps, err := m.loader.getTrees(fpath, m.inflight)
+	m.deps = append(m.deps, deps...)
 	if err != nil {
 		m.err = err
 		return "", err
@@ -233,19 +396,14 @@ func (m *meta) Include(path string, name string) (string, error) {
 		name = path
 	}
 
-	fpath := filepath.Join(m.loader.dir, filepath.FromSlash(path))
+	fpath := m.loader.filePath(path)
+	m.deps = append(m.deps, fpath)
 	p, err := ioutil.ReadFile(fpath)
 	if err != nil {
 		return "", err
 	}
 
-	if i := len(p) - 1; i >= 0 && p[i] == '\n' {
-		p = p[:i]
-		i--
-		if i >= 0 && p[i] == '\r' {
-			p = p[:i]
-		}
-	}
+	p = bytes.TrimSuffix(common.NormalizeText(p), []byte{'\n'})
 
 	tree := &parse.Tree{
 		Name:      name,
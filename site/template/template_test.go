@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"io/ioutil"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -12,6 +14,7 @@ var templateTests = []struct {
 	wantParseError bool
 }{
 	{name: "example.html"},
+	{name: "delims.html"},
 	{name: "cycle1.html", wantParseError: true},
 }
 
@@ -52,3 +55,107 @@ func TestTemplate(t *testing.T) {
 		}
 	}
 }
+
+func TestDependencies(t *testing.T) {
+	l, err := NewLoader("testdata/in", nil)
+	if err != nil {
+		t.Fatalf("NewManager returned error %v", err)
+	}
+	if _, err := l.Load("example.html"); err != nil {
+		t.Fatalf("Load returned error %v", err)
+	}
+	got := l.Dependencies("example.html")
+	want := []string{
+		filepath.FromSlash("testdata/in/example.html"),
+		filepath.FromSlash("testdata/in/layout/layout.html"),
+		filepath.FromSlash("testdata/in/layout/layout.css"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Dependencies() = %v, want %v", got, want)
+	}
+}
+
+func TestExtendedFuncs(t *testing.T) {
+	l, err := NewLoader("testdata/in", nil, WithExtendedFuncs())
+	if err != nil {
+		t.Fatalf("NewManager returned error %v", err)
+	}
+	templ, err := l.Load("extended.html")
+	if err != nil {
+		t.Fatalf("Load returned error %v", err)
+	}
+	var got bytes.Buffer
+	if err := templ.Execute(&got, data); err != nil {
+		t.Fatalf("Execute returned error %v", err)
+	}
+	want, err := ioutil.ReadFile("testdata/out/extended.html")
+	if err != nil {
+		t.Fatalf("ReadFile returned error %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("Execute got:\n%s\nwant:\n%s", got.Bytes(), want)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	l, err := NewLoader("testdata/in", nil, WithStrict())
+	if err != nil {
+		t.Fatalf("NewManager returned error %v", err)
+	}
+	templ, err := l.Load("missing.html")
+	if err != nil {
+		t.Fatalf("Load returned error %v", err)
+	}
+	if err := templ.Execute(ioutil.Discard, data); err == nil {
+		t.Errorf("Execute did not return error for missing key")
+	}
+}
+
+func TestLoadText(t *testing.T) {
+	l, err := NewLoader("testdata/in", nil)
+	if err != nil {
+		t.Fatalf("NewManager returned error %v", err)
+	}
+	templ, err := l.LoadText("text.xml")
+	if err != nil {
+		t.Fatalf("LoadText returned error %v", err)
+	}
+	var got bytes.Buffer
+	if err := templ.Execute(&got, data); err != nil {
+		t.Fatalf("Execute returned error %v", err)
+	}
+	want, err := ioutil.ReadFile("testdata/out/text.xml")
+	if err != nil {
+		t.Fatalf("ReadFile returned error %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("Execute got:\n%s\nwant:\n%s", got.Bytes(), want)
+	}
+}
+
+func TestExecError(t *testing.T) {
+	l, err := NewLoader("testdata/in", nil)
+	if err != nil {
+		t.Fatalf("NewManager returned error %v", err)
+	}
+	for _, tt := range []struct {
+		name, want string
+	}{
+		{"execerror.html", "testdata/in/execerror.html:2:2: at <index .Title 1 2>: error calling index: "},
+		{"escapeerror.html", "testdata/in/escapeerror.html: ends in a non-text context: "},
+	} {
+		templ, err := l.Load(tt.name)
+		if err != nil {
+			t.Fatalf("Load returned error %v", err)
+		}
+		err = templ.Execute(ioutil.Discard, map[string]string{"Title": "x"})
+		if err == nil {
+			t.Errorf("%s: Execute did not return error", tt.name)
+			continue
+		}
+		got := l.ExecError(tt.name, err).Error()
+		if !strings.HasPrefix(got, filepath.FromSlash(tt.want)) {
+			t.Errorf("%s: got %q, want prefix %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package example
+
+import "fmt" // OMIT
+
+func hello() {
+	fmt.Println("hello") // OMIT
+	fmt.Println("world")
+}
+
+func goodbye() {}
@@ -0,0 +1,34 @@
+package site
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Timings is the time spent in the phases of generating a site. Pages are
+// executed concurrently with the walk of the static directory and deferred
+// pages are executed after Visit returns. Because of this, the phase times
+// can overlap.
+type Timings struct {
+	// Walk is the total time spent in Visit.
+	Walk time.Duration
+
+	// Render is the time spent executing page actions and layouts.
+	Render time.Duration
+
+	// Minify is the time spent minifying and post-processing pages.
+	Minify time.Duration
+}
+
+// WithTimings returns an option that records the time spent in each phase
+// to t.
+func WithTimings(t *Timings) Option {
+	return func(o *options) {
+		o.timings = t
+	}
+}
+
+// addTime adds the time since start to d.
+func addTime(d *time.Duration, start time.Time) {
+	atomic.AddInt64((*int64)(d), int64(time.Since(start)))
+}
@@ -0,0 +1,153 @@
+package site
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/garyburd/staticsite/common"
+	"github.com/garyburd/staticsite/site/html"
+)
+
+// WithUnusedWarnings returns an option that reports warnings for static
+// files and layout templates that are not referenced by any generated page
+// if enable is true. Static files are referenced by URL in the HTML of a
+// page, by url() in a referenced stylesheet or by a template function. The
+// option has no effect with WithDeferredPages.
+func WithUnusedWarnings(enable bool) Option {
+	return func(o *options) {
+		o.unusedWarnings = enable
+	}
+}
+
+// urlAttributes is the set of attributes containing URLs.
+var urlAttributes = map[string]bool{
+	"href":   true,
+	"src":    true,
+	"poster": true,
+	"srcset": true,
+}
+
+// recordReferences records the local URLs in tag t on the page at upage.
+func (s *site) recordReferences(upage string, t *html.Tag) {
+	for _, a := range t.Attr {
+		if !urlAttributes[a.Key] {
+			continue
+		}
+		urls := []string{a.Val}
+		if a.Key == "srcset" {
+			urls = urls[:0]
+			for _, candidate := range strings.Split(a.Val, ",") {
+				if f := strings.Fields(candidate); len(f) > 0 {
+					urls = append(urls, f[0])
+				}
+			}
+		}
+		for _, u := range urls {
+			s.addReference(upage, u)
+		}
+	}
+}
+
+// addReference records a reference to URL u from the resource at ubase.
+func (s *site) addReference(ubase string, u string) {
+	upath, ok := localPath(u)
+	if !ok {
+		return
+	}
+	if p, err := url.PathUnescape(upath); err == nil {
+		upath = p
+	}
+	upath = absPath(ubase, upath)
+	if strings.HasSuffix(upath, "/index.html") {
+		upath = upath[:len(upath)-len("index.html")]
+	}
+	s.stateMu.Lock()
+	s.references[upath] = struct{}{}
+	s.stateMu.Unlock()
+}
+
+// recordStaticFile records a static file for the unused file check. The file
+// is in the static directory, a static mount or the theme.
+func (s *site) recordStaticFile(r *Resource) {
+	if !s.unusedWarnings {
+		return
+	}
+	s.stateMu.Lock()
+	s.staticFiles[r.Path] = r.FilePath
+	s.stateMu.Unlock()
+}
+
+// isStaticRoot returns whether the static file at upath is used without a
+// reference from a page.
+func isStaticRoot(upath string) bool {
+	switch {
+	case strings.HasSuffix(upath, "/"),
+		strings.HasSuffix(upath, ".html"),
+		strings.HasPrefix(upath, "/.well-known/"),
+		upath == "/robots.txt",
+		upath == "/favicon.ico",
+		upath == "/CNAME":
+		return true
+	}
+	return false
+}
+
+var cssURL = regexp.MustCompile(`url\(\s*['"]?([^'")\s]+)`)
+
+// warnUnused reports warnings for unused static files and layout templates.
+func (s *site) warnUnused() error {
+	used := make(map[string]bool)
+	for _, deps := range s.deps {
+		for fpath := range deps {
+			used[fpath] = true
+		}
+	}
+
+	// Add references from stylesheets.
+	for upath, fpath := range s.staticFiles {
+		if filepath.Ext(fpath) != ".css" {
+			continue
+		}
+		p, err := ioutil.ReadFile(fpath)
+		if err != nil {
+			return err
+		}
+		for _, m := range cssURL.FindAllSubmatch(p, -1) {
+			s.addReference(upath, string(m[1]))
+		}
+	}
+
+	var unused []string
+	for upath, fpath := range s.staticFiles {
+		if _, ok := s.references[upath]; !ok && !used[fpath] && !isStaticRoot(upath) {
+			unused = append(unused, fpath)
+		}
+	}
+
+	ldir := filepath.Join(s.dir, common.LayoutDir)
+	err := filepath.Walk(ldir, func(fpath string, fi os.FileInfo, err error) error {
+		if os.IsNotExist(err) && fpath == ldir {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !fi.IsDir() && !used[fpath] {
+			unused = append(unused, fpath)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(unused)
+	for _, fpath := range unused {
+		s.reportWarning(fpath, "not used by any page")
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package site
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUnusedWarnings(t *testing.T) {
+	var buf bytes.Buffer
+	err := Visit("testdata/unused", &buf, func(*Resource) error { return nil }, WithUnusedWarnings(true))
+	if err != nil {
+		t.Fatal(err, buf.String())
+	}
+	const want = "testdata/unused/assets/c.png: warning: not used by any page\n" +
+		"testdata/unused/layout/old.html: warning: not used by any page\n" +
+		"testdata/unused/static/img/b.png: warning: not used by any page\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
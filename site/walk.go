@@ -21,23 +21,113 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/garyburd/staticsite/common"
 )
 
-func isPageExt(name string) bool {
-	return strings.HasSuffix(name, ".html")
+// pageExt returns the page extension of the file name or "" if the file is
+// not a page. Files with the extension .md are Markdown pages when Markdown is
+// enabled in the site configuration.
+func (s *site) pageExt(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".html"):
+		return ".html"
+	case s.config.Markdown && strings.HasSuffix(name, ".md"):
+		return ".md"
+	}
+	return ""
 }
 
-func (s *site) visitDirectory(fpath string, upath string, isPageDir bool) error {
-	d, err := os.Open(fpath)
-	if err != nil {
-		return err
+func (s *site) isPageExt(name string) bool {
+	return s.pageExt(name) != ""
+}
+
+// isIndexPageName returns whether name is the file name of a directory's
+// index page.
+func (s *site) isIndexPageName(name string) bool {
+	ext := s.pageExt(name)
+	return ext != "" && name == "index"+ext
+}
+
+// visitDirectory visits the directory at upath. The directory is the overlay
+// of the file system directories fdirs. Files in earlier directories override
+// files in later directories. The ancestors argument is the real paths of the
+// ancestor directories and is used to detect symbolic link cycles.
+//
+// Files and subdirectories in the static directory are visited concurrently
+// using the site walker.
+func (s *site) visitDirectory(fdirs []string, upath string, isPageDir bool, cascade []cascadeAction, ancestors []string) error {
+	var names []string
+	seen := make(map[string]bool)
+	for _, fdir := range fdirs {
+		if s.config.FollowSymlinks {
+			// Detect cycles created by symbolic links to ancestor directories.
+			real, err := filepath.EvalSymlinks(fdir)
+			if err != nil {
+				return err
+			}
+			if containsString(ancestors, real) {
+				return fmt.Errorf("%s: symbolic link cycle", fdir)
+			}
+			ancestors = append(ancestors[:len(ancestors):len(ancestors)], real)
+		}
+
+		d, err := os.Open(fdir)
+		if err != nil {
+			return err
+		}
+		dirNames, err := d.Readdirnames(-1)
+		d.Close()
+		if err != nil {
+			return err
+		}
+		for _, name := range dirNames {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
 	}
-	names, err := d.Readdirnames(-1)
-	d.Close()
-	if err != nil {
-		return err
+
+	// The cascade actions in the _dir.html file apply to all pages in the
+	// directory and descendant directories. The cascade actions in the index
+	// page apply to the other pages in the directory and descendant
+	// directories.
+	if isPageDir && seen[dirCascadeFile] {
+		fpath, _, _, err := s.overlayFile(fdirs, dirCascadeFile)
+		if err != nil {
+			return err
+		}
+		c, err := loadCascade(fpath)
+		if err != nil {
+			if err := s.reportError(err); err != nil {
+				return err
+			}
+		} else {
+			cascade = append(cascade[:len(cascade):len(cascade)], c...)
+		}
+	}
+	parentCascade := cascade
+	if isPageDir {
+		for _, name := range names {
+			if !s.isIndexPageName(name) {
+				continue
+			}
+			fpath, _, _, err := s.overlayFile(fdirs, name)
+			if err != nil {
+				return err
+			}
+			c, err := loadCascade(fpath)
+			if err != nil {
+				if err := s.reportError(err); err != nil {
+					return err
+				}
+				continue
+			}
+			cascade = append(cascade[:len(cascade):len(cascade)], c...)
+		}
 	}
 
 	var indexPage *Resource
@@ -45,39 +135,33 @@ func (s *site) visitDirectory(fpath string, upath string, isPageDir bool) error
 	var pages []*Resource
 
 	for _, name := range names {
-		if name == ".DS_Store" {
+		if name == ".DS_Store" || (isPageDir && name == dirCascadeFile) {
 			continue
 		}
 
-		filePath := fpath + string(filepath.Separator) + name
-		fileInfo, err := os.Lstat(filePath)
+		if !isPageDir {
+			name, ancestors := name, ancestors
+			s.walker.do(func() error {
+				return s.visitStaticFile(fdirs, upath, name, ancestors)
+			})
+			continue
+		}
+
+		filePath, fileInfo, subdirs, err := s.overlayFile(fdirs, name)
 		if err != nil {
 			return err
 		}
 
 		if fileInfo.IsDir() {
-			if err := s.visitDirectory(filePath, upath+"/"+name, isPageDir); err != nil {
+			if err := s.visitDirectory(subdirs, upath+"/"+name, isPageDir, cascade, ancestors); err != nil {
 				return err
 			}
 			continue
 		}
 
-		r := &Resource{
-			FilePath: filePath,
-			ModTime:  fileInfo.ModTime(),
-			Size:     fileInfo.Size(),
-		}
-
-		if !isPageDir {
-			if name == "index.html" {
-				r.Path = upath + "/"
-			} else {
-				r.Path = upath + "/" + name
-			}
-			if err := s.visitFile(r); err != nil {
-				return err
-			}
-			continue
+		r, err := s.newFileResource(filePath, fileInfo)
+		if err != nil {
+			return err
 		}
 
 		// Hold pages until after child directories are visited so that pages
@@ -87,15 +171,27 @@ func (s *site) visitDirectory(fpath string, upath string, isPageDir bool) error
 		//
 		// Group according to allowed page queries.
 
-		if name == "index.html" {
+		ext := s.pageExt(name)
+		if s.isIndexPageName(name) {
 			r.Path = upath + "/"
+			if indexPage != nil {
+				if err := s.reportError(fmt.Errorf("%s: page path %s is also used by %s", r.FilePath, r.Path, indexPage.FilePath)); err != nil {
+					return err
+				}
+				continue
+			}
 			indexPage = r
-		} else if strings.HasSuffix(name, ".index.html") {
-			r.Path = upath + "/" + name[:len(name)-len(".index.html")]
+		} else if ext != "" && strings.HasSuffix(name, ".index"+ext) {
+			r.Path = upath + "/" + name[:len(name)-len(".index"+ext)]
 			indexPages = append(indexPages, r)
-		} else if strings.HasSuffix(name, ".html") {
-			r.Path = upath + "/" + name[:len(name)-len(".html")] + "/"
+		} else if ext != "" {
+			r.Path = upath + "/" + name[:len(name)-len(ext)] + "/"
 			pages = append(pages, r)
+		} else if s.config.PageBundles && !strings.HasPrefix(name, ".") && !strings.HasSuffix(name, metaFileSuffix) {
+			r.Path = upath + "/" + name
+			if err := s.visitFile(r); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -104,42 +200,293 @@ func (s *site) visitDirectory(fpath string, upath string, isPageDir bool) error
 		pages = append(pages, indexPage)
 	}
 
+	loaded := make([]*Page, 0, len(pages))
 	for _, r := range pages {
-		err := s.processPage(r)
+		c := cascade
+		if r == indexPage {
+			c = parentCascade
+		}
+		p, err := s.loadPage(r, upath+"/", r == indexPage, c)
 		if err != nil {
-			m := strings.TrimPrefix(err.Error(), "template: ")
-			if _, ok := s.reportedErrors[m]; !ok {
-				s.reportedErrors[m] = struct{}{}
-				fmt.Fprintln(s.errOut, m)
+			// Report the error and continue with the other pages so that
+			// all errors in the site are reported in one build.
+			if err := s.reportError(err); err != nil {
+				return err
 			}
-			return nil
+			continue
+		}
+		if p == nil {
+			continue
+		}
+		loaded = append(loaded, p)
+	}
+
+	for _, p := range loaded {
+		if err := s.visitPage(p); err != nil {
+			return err
 		}
-		if err := s.visitFile(r); err != nil {
+	}
+	return nil
+}
+
+// visitPage renders or defers page p and the page's additional outputs and
+// visits the resources. Errors rendering the pages are reported.
+func (s *site) visitPage(p *Page) error {
+	outputs, err := s.outputPages(p)
+	if err != nil {
+		return s.reportError(err)
+	}
+	for i, q := range append([]*Page{p}, outputs...) {
+		if s.deferPages {
+			s.deferPage(q)
+		} else if err := s.renderPage(q); err != nil {
+			return s.reportError(err)
+		}
+		if i == 0 {
+			s.recordSitemapPage(p)
+		}
+		if err := s.visitFile(q.resource); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// visitStaticFile visits the file or directory with the given name in the
+// static directory at upath.
+func (s *site) visitStaticFile(fdirs []string, upath string, name string, ancestors []string) error {
+	filePath, fileInfo, subdirs, err := s.overlayFile(fdirs, name)
+	if err != nil {
+		return err
+	}
+	if fileInfo.IsDir() {
+		return s.visitDirectory(subdirs, upath+"/"+name, false, nil, ancestors)
+	}
+	r, err := s.newFileResource(filePath, fileInfo)
+	if err != nil {
+		return err
+	}
+	if name == "index.html" {
+		r.Path = upath + "/"
+	} else {
+		r.Path = upath + "/" + name
+	}
+	s.recordStaticFile(r)
+	return s.visitFile(r)
+}
+
+// newFileResource returns a resource for the file at fpath.
+func (s *site) newFileResource(fpath string, fi os.FileInfo) (*Resource, error) {
+	r := &Resource{
+		FilePath: fpath,
+		ModTime:  fi.ModTime(),
+		Size:     fi.Size(),
+	}
+	if t, err := s.gitModTime(fpath); err != nil {
+		return nil, err
+	} else if !t.IsZero() {
+		r.ModTime = t
+	}
+	return r, nil
+}
+
+// walker runs functions using a bounded number of goroutines.
+type walker struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+func newWalker(n int) *walker {
+	return &walker{sem: make(chan struct{}, n)}
+}
+
+// do runs fn in a new goroutine if the bound allows. Otherwise, fn runs in
+// the calling goroutine. Running fn in the calling goroutine when the bound
+// is reached prevents deadlock in recursive walks. The first error returned
+// from fn is returned by wait.
+func (w *walker) do(fn func() error) {
+	w.mu.Lock()
+	failed := w.err != nil
+	w.mu.Unlock()
+	if failed {
+		return
+	}
+	select {
+	case w.sem <- struct{}{}:
+		w.wg.Add(1)
+		go func() {
+			defer func() {
+				<-w.sem
+				w.wg.Done()
+			}()
+			w.setErr(fn())
+		}()
+	default:
+		w.setErr(fn())
+	}
+}
+
+func (w *walker) setErr(err error) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+}
+
+// wait waits for the goroutines started by do and returns the first error.
+func (w *walker) wait() error {
+	w.wg.Wait()
+	return w.err
+}
+
+// overlayFile returns the path and file info for the file with the given
+// name in the overlay of directories fdirs. If the file is a directory,
+// overlayFile also returns the directories with the name in fdirs.
+func (s *site) overlayFile(fdirs []string, name string) (string, os.FileInfo, []string, error) {
+	var (
+		fpath   string
+		fi      os.FileInfo
+		subdirs []string
+	)
+	for _, fdir := range fdirs {
+		p := fdir + string(filepath.Separator) + name
+		info, err := s.stat(p)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", nil, nil, err
+		}
+		if fi == nil {
+			fpath, fi = p, info
+		}
+		if fi.IsDir() && info.IsDir() {
+			subdirs = append(subdirs, p)
+		}
+	}
+	if fi == nil {
+		return "", nil, nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return fpath, fi, subdirs, nil
+}
+
+// stat returns the file info for fpath. Symbolic links are followed if
+// enabled in the site configuration.
+func (s *site) stat(fpath string) (os.FileInfo, error) {
+	fi, err := os.Lstat(fpath)
+	if err == nil && fi.Mode()&os.ModeSymlink != 0 && s.config.FollowSymlinks {
+		fi, err = os.Stat(fpath)
+	}
+	return fi, err
+}
+
+// errTooManyErrors is returned from Visit when the number of reported errors
+// reaches the limit set with WithMaxErrors.
+var errTooManyErrors = errors.New("too many errors")
+
+// reportError writes err to the site's error output. Duplicate errors are
+// reported once. If the number of reported errors reaches the limit set with
+// WithMaxErrors, errTooManyErrors is returned.
+func (s *site) reportError(err error) error {
+	m := strings.TrimPrefix(err.Error(), "template: ")
+	s.visitMu.Lock()
+	defer s.visitMu.Unlock()
+	if _, ok := s.reportedErrors[m]; !ok {
+		s.reportedErrors[m] = struct{}{}
+		fmt.Fprintln(s.errOut, m)
+	}
+	if s.maxErrors > 0 && len(s.reportedErrors) >= s.maxErrors {
+		return errTooManyErrors
+	}
+	return nil
+}
+
 func (s *site) visitFile(r *Resource) error {
+	if err := s.addHeaders(r); err != nil {
+		return err
+	}
+	s.visitMu.Lock()
+	defer s.visitMu.Unlock()
 	if common.Verbose {
 		fmt.Printf("File %s -> %s\n", r.FilePath, r.Path)
 	}
 	return s.visitFn(r)
 }
 
-func Visit(dir string, errOut io.Writer, fn func(*Resource) error) error {
-	s := newSite(dir, errOut, fn)
-	err := s.visitDirectory(filepath.Join(s.dir, common.StaticDir), "", false)
+// Visit generates the site in directory dir and calls fn for each resource
+// on the site. Errors in pages are written to errOut. The static and page
+// directories are walked concurrently, but calls to fn are serialized. The
+// order of the calls is not specified.
+func Visit(dir string, errOut io.Writer, fn func(*Resource) error, options ...Option) error {
+	s, err := newSite(dir, errOut, fn, options...)
 	if err != nil {
 		return err
 	}
-	err = s.visitDirectory(filepath.Join(s.dir, common.PageDir), "", true)
+	defer addTime(&s.timings.Walk, time.Now())
+
+	if s.hooks {
+		if err := s.runHooks(s.config.PreBuild, s.hookEnv()); err != nil {
+			return err
+		}
+	}
+	if s.buildStart != nil {
+		s.buildStart()
+	}
+
+	var changed []string
+	start := time.Now()
+	if s.hooks && len(s.config.PostBuild) > 0 {
+		lastBuild := s.lastBuildTime()
+		s.visitFn = func(r *Resource) error {
+			if modifiedSince(r, lastBuild) {
+				changed = append(changed, r.Path)
+			}
+			return fn(r)
+		}
+	}
+
+	s.walker.do(func() error {
+		return s.visitDirectory(s.roots(common.StaticDir), "", false, nil, nil)
+	})
+	err = s.visitDirectory(s.roots(common.PageDir), "", true, nil, nil)
+	if werr := s.walker.wait(); err == nil {
+		err = werr
+	}
 	if err != nil {
 		return err
 	}
+	if err := s.visitTaxonomies(); err != nil {
+		return err
+	}
+	if err := s.visitNotFound(); err != nil {
+		return err
+	}
+	if err := s.visitGenerated(); err != nil {
+		return err
+	}
+	if err := s.visitRedirects(); err != nil {
+		return err
+	}
+	if err := s.visitSitemap(); err != nil {
+		return err
+	}
+	if s.unusedWarnings {
+		if err := s.warnUnused(); err != nil {
+			return err
+		}
+	}
+	nwarnings := s.writeWarnings()
 	if len(s.reportedErrors) > 0 {
-		return errors.New("errors reported")
+		return fmt.Errorf("%d errors reported", len(s.reportedErrors))
+	}
+	if s.warningsAsErrors && nwarnings > 0 {
+		return fmt.Errorf("%d warnings reported", nwarnings)
+	}
+	if s.hooks && len(s.config.PostBuild) > 0 {
+		return s.runPostBuildHooks(changed, start)
 	}
 	return nil
 }
@@ -0,0 +1,68 @@
+package site
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/garyburd/staticsite/site/html"
+)
+
+// WithWarningsAsErrors returns an option that causes Visit to fail if
+// warnings are reported and enable is true.
+func WithWarningsAsErrors(enable bool) Option {
+	return func(o *options) {
+		o.warningsAsErrors = enable
+	}
+}
+
+// reportWarning records a non-fatal finding for the file fpath. Warnings are
+// written to the error output after the site is generated. Warnings reported
+// after Visit returns, for example from deferred pages, are written
+// immediately.
+func (s *site) reportWarning(fpath string, format string, args ...interface{}) {
+	m := fmt.Sprintf("%s: warning: %s", fpath, fmt.Sprintf(format, args...))
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if _, ok := s.warnings[m]; ok {
+		return
+	}
+	s.warnings[m] = struct{}{}
+	if s.warningsWritten {
+		fmt.Fprintln(s.errOut, m)
+	}
+}
+
+// writeWarnings writes the sorted warnings to the error output and returns
+// the number of warnings.
+func (s *site) writeWarnings() int {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	warnings := make([]string, 0, len(s.warnings))
+	for m := range s.warnings {
+		warnings = append(warnings, m)
+	}
+	sort.Strings(warnings)
+	for _, m := range warnings {
+		fmt.Fprintln(s.errOut, m)
+	}
+	s.warningsWritten = true
+	return len(warnings)
+}
+
+// checkPage reports warnings for the generated HTML of page p.
+func (s *site) checkPage(p *Page, data []byte) error {
+	_, err := html.Rewrite(data, func(t *html.Tag) error {
+		if s.unusedWarnings {
+			s.recordReferences(p.Path, t)
+		}
+		if t.Name != "img" {
+			return nil
+		}
+		if _, ok := t.Get("alt"); !ok {
+			src, _ := t.Get("src")
+			s.reportWarning(p.resource.FilePath, "img %s missing alt attribute", src)
+		}
+		return nil
+	})
+	return err
+}
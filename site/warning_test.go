@@ -0,0 +1,23 @@
+package site
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCheckPage(t *testing.T) {
+	var buf bytes.Buffer
+	s := &site{errOut: &buf, warnings: make(map[string]struct{})}
+	p := &Page{resource: &Resource{FilePath: "page/index.html"}}
+	err := s.checkPage(p, []byte(`<img src=a.png><img src=b.png alt=""><img src=a.png>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.writeWarnings(); n != 1 {
+		t.Errorf("got %d warnings, want 1", n)
+	}
+	const want = "page/index.html: warning: img a.png missing alt attribute\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2011 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package sitetest
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// selectorGroup is a comma separated list of selectors.
+type selectorGroup [][]*compound
+
+// compound is a sequence of simple selectors and the combinator relating
+// the compound to the previous compound in the selector.
+type compound struct {
+	combinator byte // ' ' for descendant, '>' for child
+	tag        string
+	id         string
+	classes    []string
+	attrs      []attrSelector
+}
+
+type attrSelector struct {
+	key string
+	op  string // "", "=", "~=", "^=", "$=" or "*="
+	val string
+}
+
+func parseSelector(s string) (selectorGroup, error) {
+	var group selectorGroup
+	for _, part := range strings.Split(s, ",") {
+		sel, err := parseComplex(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("selector %q: %w", s, err)
+		}
+		group = append(group, sel)
+	}
+	return group, nil
+}
+
+func parseComplex(s string) ([]*compound, error) {
+	var sel []*compound
+	combinator := byte(' ')
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case c == '>':
+			if len(sel) == 0 || combinator == '>' {
+				return nil, fmt.Errorf("unexpected >")
+			}
+			combinator = '>'
+			i++
+			continue
+		}
+		c := &compound{combinator: combinator}
+		combinator = ' '
+		n, err := c.parse(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		sel = append(sel, c)
+	}
+	if len(sel) == 0 || combinator == '>' {
+		return nil, fmt.Errorf("missing selector")
+	}
+	return sel, nil
+}
+
+// parse parses the compound selector at the start of s and returns the
+// number of bytes consumed.
+func (c *compound) parse(s string) (int, error) {
+	i := 0
+	if i < len(s) && s[i] == '*' {
+		i++
+	} else {
+		n := identLength(s[i:])
+		c.tag = strings.ToLower(s[i : i+n])
+		i += n
+	}
+	for i < len(s) {
+		switch s[i] {
+		case '#', '.':
+			n := identLength(s[i+1:])
+			if n == 0 {
+				return 0, fmt.Errorf("missing name after %c", s[i])
+			}
+			if s[i] == '#' {
+				c.id = s[i+1 : i+1+n]
+			} else {
+				c.classes = append(c.classes, s[i+1:i+1+n])
+			}
+			i += 1 + n
+		case '[':
+			j := strings.IndexByte(s[i:], ']')
+			if j < 0 {
+				return 0, fmt.Errorf("missing ]")
+			}
+			a, err := parseAttrSelector(s[i+1 : i+j])
+			if err != nil {
+				return 0, err
+			}
+			c.attrs = append(c.attrs, a)
+			i += j + 1
+		case ' ', '\t', '\n', '>':
+			return i, nil
+		default:
+			return 0, fmt.Errorf("unexpected %q", s[i])
+		}
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("missing selector")
+	}
+	return i, nil
+}
+
+func parseAttrSelector(s string) (attrSelector, error) {
+	var a attrSelector
+	i := strings.IndexByte(s, '=')
+	if i < 0 {
+		a.key = strings.TrimSpace(s)
+	} else {
+		a.key = s[:i]
+		a.op = "="
+		if i > 0 && strings.IndexByte("~^$*", s[i-1]) >= 0 {
+			a.key = s[:i-1]
+			a.op = s[i-1 : i+1]
+		}
+		a.key = strings.TrimSpace(a.key)
+		a.val = strings.TrimSpace(s[i+1:])
+		if len(a.val) >= 2 && (a.val[0] == '"' || a.val[0] == '\'') && a.val[len(a.val)-1] == a.val[0] {
+			a.val = a.val[1 : len(a.val)-1]
+		}
+	}
+	if a.key == "" || identLength(a.key) != len(a.key) {
+		return a, fmt.Errorf("invalid attribute selector [%s]", s)
+	}
+	a.key = strings.ToLower(a.key)
+	return a, nil
+}
+
+func identLength(s string) int {
+	n := 0
+	for n < len(s) {
+		c := s[n]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c >= 0x80) {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func (g selectorGroup) match(n *html.Node) bool {
+	for _, sel := range g {
+		if matchComplex(sel, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchComplex returns whether element n matches the last compound in sel
+// and the ancestors of n match the remaining compounds.
+func matchComplex(sel []*compound, n *html.Node) bool {
+	last := sel[len(sel)-1]
+	if !last.match(n) {
+		return false
+	}
+	if len(sel) == 1 {
+		return true
+	}
+	for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		if matchComplex(sel[:len(sel)-1], p) {
+			return true
+		}
+		if last.combinator == '>' {
+			break
+		}
+	}
+	return false
+}
+
+func (c *compound) match(n *html.Node) bool {
+	if n.Type != html.ElementNode || (c.tag != "" && n.Data != c.tag) {
+		return false
+	}
+	if c.id != "" {
+		if id, _ := Attr(n, "id"); id != c.id {
+			return false
+		}
+	}
+	if len(c.classes) > 0 {
+		class, _ := Attr(n, "class")
+		classes := strings.Fields(class)
+		for _, want := range c.classes {
+			if !containsString(classes, want) {
+				return false
+			}
+		}
+	}
+	for _, a := range c.attrs {
+		val, ok := Attr(n, a.key)
+		if !ok {
+			return false
+		}
+		switch a.op {
+		case "=":
+			ok = val == a.val
+		case "~=":
+			ok = containsString(strings.Fields(val), a.val)
+		case "^=":
+			ok = a.val != "" && strings.HasPrefix(val, a.val)
+		case "$=":
+			ok = a.val != "" && strings.HasSuffix(val, a.val)
+		case "*=":
+			ok = a.val != "" && strings.Contains(val, a.val)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
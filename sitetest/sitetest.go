@@ -0,0 +1,310 @@
+// Copyright 2011 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package sitetest provides helpers for testing sites.
+//
+// Build a site in a test and make assertions on the generated resources:
+//
+//	func TestSite(t *testing.T) {
+//		s := sitetest.Build(t, os.DirFS("."))
+//		s.AssertText(t, "/", "h1", "Home")
+//		s.AssertCount(t, "/blog/", "article.post", 3)
+//		s.AssertGolden(t, "/about/", "testdata/about.html")
+//	}
+//
+// The site is read from an fs.FS such as the result of os.DirFS or an
+// embed.FS. Run the tests with the flag -sitetest.update to write the golden
+// files.
+package sitetest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/garyburd/staticsite/site"
+	"golang.org/x/net/html"
+)
+
+var update = flag.Bool("sitetest.update", false, "Write golden files with the generated resources.")
+
+// Site is a generated site.
+type Site struct {
+	resources map[string]*site.Resource
+	data      map[string][]byte
+
+	// Output is the warnings written by the build.
+	Output string
+}
+
+// Build generates the site in fsys. The test fails if the build reports an
+// error. The files are copied to a temporary directory for the build. The
+// FilePath and Dependencies fields of the resources are set to the slash
+// separated paths relative to the site.
+func Build(t testing.TB, fsys fs.FS, options ...site.Option) *Site {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "sitetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := copyFiles(fsys, dir); err != nil {
+		t.Fatal(err)
+	}
+	return build(t, dir, options)
+}
+
+// BuildFiles generates a site from a map of slash separated file paths to
+// file contents. See Build for details.
+func BuildFiles(t testing.TB, files map[string]string, options ...site.Option) *Site {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "sitetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, data := range files {
+		fpath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fpath, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return build(t, dir, options)
+}
+
+func build(t testing.TB, dir string, options []site.Option) *Site {
+	t.Helper()
+	s := &Site{
+		resources: make(map[string]*site.Resource),
+		data:      make(map[string][]byte),
+	}
+	var out bytes.Buffer
+	err := site.Visit(dir, &out, func(r *site.Resource) error {
+		var buf bytes.Buffer
+		if _, err := r.WriteTo(&buf); err != nil {
+			return err
+		}
+		s.data[r.Path] = buf.Bytes()
+		r.FilePath = relativePath(dir, r.FilePath)
+		for i, fpath := range r.Dependencies {
+			r.Dependencies[i] = relativePath(dir, fpath)
+		}
+		s.resources[r.Path] = r
+		return nil
+	}, options...)
+	s.Output = strings.Replace(out.String(), dir+string(filepath.Separator), "", -1)
+	if err != nil {
+		t.Fatalf("build failed: %v\n%s", err, s.Output)
+	}
+	return s
+}
+
+func relativePath(dir, fpath string) string {
+	rel, err := filepath.Rel(dir, fpath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return fpath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// copyFiles copies the files in fsys to directory dst.
+func copyFiles(fsys fs.FS, dst string) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fpath := filepath.Join(dst, filepath.FromSlash(name))
+		if d.IsDir() {
+			return os.MkdirAll(fpath, 0777)
+		}
+		r, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		w, err := os.Create(fpath)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, r)
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	})
+}
+
+// Paths returns the sorted paths of the site's resources.
+func (s *Site) Paths() []string {
+	var paths []string
+	for upath := range s.resources {
+		paths = append(paths, upath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Resource returns the resource at upath or nil if the resource does not
+// exist.
+func (s *Site) Resource(upath string) *site.Resource {
+	return s.resources[upath]
+}
+
+// Data returns the generated data for the resource at upath. The test fails
+// if the resource does not exist.
+func (s *Site) Data(t testing.TB, upath string) []byte {
+	t.Helper()
+	data, ok := s.data[upath]
+	if !ok {
+		t.Fatalf("resource %s not found", upath)
+	}
+	return data
+}
+
+// Query returns the elements matching selector in the HTML resource at
+// upath. The selector is a comma separated list of CSS selectors with type,
+// universal, ID, class and attribute selectors and the descendant and child
+// combinators. The attribute selectors are [attr], [attr=value],
+// [attr~=value], [attr^=value], [attr$=value] and [attr*=value].
+func (s *Site) Query(t testing.TB, upath string, selector string) []*html.Node {
+	t.Helper()
+	sel, err := parseSelector(selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := html.Parse(bytes.NewReader(s.Data(t, upath)))
+	if err != nil {
+		t.Fatalf("%s: %v", upath, err)
+	}
+	var nodes []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && sel.match(n) {
+			nodes = append(nodes, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return nodes
+}
+
+// AssertCount checks that the HTML resource at upath has n elements matching
+// selector.
+func (s *Site) AssertCount(t testing.TB, upath string, selector string, n int) {
+	t.Helper()
+	if nodes := s.Query(t, upath, selector); len(nodes) != n {
+		t.Errorf("%s: found %d elements matching %q, want %d", upath, len(nodes), selector, n)
+	}
+}
+
+// AssertText checks that the text of the first element matching selector in
+// the HTML resource at upath is want. Runs of whitespace in the text are
+// replaced with a single space and leading and trailing whitespace is
+// removed.
+func (s *Site) AssertText(t testing.TB, upath string, selector string, want string) {
+	t.Helper()
+	nodes := s.Query(t, upath, selector)
+	if len(nodes) == 0 {
+		t.Errorf("%s: no element matches %q", upath, selector)
+		return
+	}
+	if got := Text(nodes[0]); got != want {
+		t.Errorf("%s: text of %q is %q, want %q", upath, selector, got, want)
+	}
+}
+
+// AssertGolden checks that the data for the resource at upath is equal to
+// the contents of the golden file fpath. If the -sitetest.update flag is
+// set, the golden file is written with the data.
+func (s *Site) AssertGolden(t testing.TB, upath string, fpath string) {
+	t.Helper()
+	data := s.Data(t, upath)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fpath, data, 0666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		t.Fatalf("%v (run the test with -sitetest.update to create the file)", err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("%s differs from %s:\n%s", upath, fpath, diffLines(string(want), string(data)))
+	}
+}
+
+// diffLines returns a description of the first difference between the
+// lines of want and got.
+func diffLines(want, got string) string {
+	wl := strings.Split(want, "\n")
+	gl := strings.Split(got, "\n")
+	for i := 0; i < len(wl) || i < len(gl); i++ {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g || i >= len(wl) || i >= len(gl) {
+			return fmt.Sprintf("line %d:\n got %q\nwant %q", i+1, g, w)
+		}
+	}
+	return ""
+}
+
+// Text returns the text of n and its descendants. Runs of whitespace in the
+// text are replaced with a single space and leading and trailing whitespace
+// is removed.
+func Text(n *html.Node) string {
+	var buf strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// Attr returns the value of the attribute key on n.
+func Attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
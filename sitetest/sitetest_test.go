@@ -0,0 +1,69 @@
+package sitetest
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// recorder records the errors reported by a test helper.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestBuild(t *testing.T) {
+	s := Build(t, os.DirFS("testdata/site"))
+	if got, want := s.Paths(), []string{"/", "/blog/a/", "/robots.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Paths() = %v, want %v", got, want)
+	}
+	if got, want := s.Resource("/blog/a/").FilePath, "page/blog/a.md"; got != want {
+		t.Errorf("FilePath = %q, want %q", got, want)
+	}
+	s.AssertText(t, "/", "h1", "Hello, World")
+	s.AssertText(t, "/blog/a/", "main > h1", "Post A")
+	s.AssertText(t, "/blog/a/", "title", "A")
+	s.AssertCount(t, "/", "ul.posts li", 2)
+	s.AssertCount(t, "/", "a.nav", 2)
+	s.AssertCount(t, "/", "a.nav.home[href='/']", 1)
+	s.AssertCount(t, "/", "nav > a[href^=/blog/], #main p", 1)
+	s.AssertCount(t, "/", "body > a", 0)
+	s.AssertGolden(t, "/", "testdata/index.golden.html")
+
+	if *update {
+		return
+	}
+	r := &recorder{TB: t}
+	s.AssertText(r, "/", "h1", "Goodbye")
+	s.AssertCount(r, "/", "li", 1)
+	s.AssertGolden(r, "/blog/a/", "testdata/index.golden.html")
+	if len(r.errors) != 3 {
+		t.Errorf("got errors %q, want 3 errors", r.errors)
+	}
+}
+
+func TestBuildFiles(t *testing.T) {
+	s := BuildFiles(t, map[string]string{
+		"page/index.html":   `<p class="x y">Hi</p>`,
+		"static/robots.txt": "",
+	})
+	s.AssertText(t, "/", "p[class~=y]", "Hi")
+	if got := string(s.Data(t, "/")); got != "<p class=\"x y\">Hi</p>" {
+		t.Errorf("Data = %q", got)
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	for _, s := range []string{"", "a >", "> a", "a > > b", "a[", "a[=x]", "a..b", "a,", "a!"} {
+		if _, err := parseSelector(s); err == nil {
+			t.Errorf("parseSelector(%q) did not return an error", s)
+		}
+	}
+}